@@ -0,0 +1,216 @@
+package memvfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// NamespaceRules selects and renames the databases copied by ExportArchive
+// and ImportArchive, so that part of one environment can be cloned into
+// another without renaming files afterwards.
+type NamespaceRules struct {
+	// Include and Exclude hold path.Match patterns matched against the
+	// names before any mapping. A name is selected if it matches an Include
+	// pattern, or Include is empty, and matches no Exclude pattern.
+	Include []string
+	Exclude []string
+
+	// Mappings rename the selected names; the first mapping whose From is
+	// a prefix of a name applies. An empty From matches every name.
+	Mappings []PrefixMapping
+}
+
+// PrefixMapping replaces the prefix From of a name with To: {"prod/", ""}
+// strips prod/, {"", "restore-2024/"} adds restore-2024/ and
+// {"prod/", "staging/"} does both at once.
+type PrefixMapping struct {
+	From, To string
+}
+
+// apply reports whether r selects name and what it maps it to.
+func (r NamespaceRules) apply(name string) (string, bool, error) {
+	selected := len(r.Include) == 0
+	for _, pattern := range r.Include {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return "", false, err
+		}
+		selected = selected || ok
+	}
+	for _, pattern := range r.Exclude {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return "", false, err
+		}
+		selected = selected && !ok
+	}
+	if !selected {
+		return "", false, nil
+	}
+	for _, m := range r.Mappings {
+		if rest, ok := strings.CutPrefix(name, m.From); ok {
+			name = m.To + rest
+			break
+		}
+	}
+	if name == "" {
+		return "", false, errors.New("mapped to an empty name")
+	}
+	return name, true, nil
+}
+
+// mapper returns a function applying r that fails when two names map to
+// the same one.
+func (r NamespaceRules) mapper() func(name string) (string, bool, error) {
+	seen := make(map[string]string)
+	return func(name string) (string, bool, error) {
+		mapped, ok, err := r.apply(name)
+		if err != nil || !ok {
+			return "", false, err
+		}
+		if other, dup := seen[mapped]; dup {
+			return "", false, fmt.Errorf("%s and %s both map to %s", other, name, mapped)
+		}
+		seen[mapped] = name
+		return mapped, true, nil
+	}
+}
+
+// paxUUID is the PAX record in which ExportArchive keeps a file's UUID.
+const paxUUID = "MEMVFS.uuid"
+
+// ExportArchive writes the main databases of the store selected by rules to
+// w as a tar archive, each under its mapped name with its UUID in a PAX
+// record, and returns the names written. Each database is a consistent
+// image taken while it is frozen, as by WriteFileTo, and redacted if the
+// store was created WithExportRedaction; the archive as a whole is not a
+// point-in-time copy.
+func (v *MemVFS) ExportArchive(w io.Writer, rules NamespaceRules) ([]string, error) {
+	mapName := rules.mapper()
+	tw := tar.NewWriter(w)
+	var names []string
+	for _, info := range v.ListFiles("") {
+		if info.Role != RoleMainDB {
+			continue
+		}
+		mapped, ok, err := mapName(info.Name)
+		if err != nil {
+			return names, fmt.Errorf("%s: %w", v.LogName(info.Name), err)
+		}
+		if !ok {
+			continue
+		}
+		data, err := v.image(info.Name)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err == nil {
+			data, err = v.redact(info.Name, data)
+		}
+		if err != nil {
+			return names, fmt.Errorf("%s: %w", v.LogName(info.Name), err)
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     mapped,
+			Size:     int64(len(data)),
+			Mode:     0o644,
+			ModTime:  info.ModTime,
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				paxUUID: info.UUID.String(),
+			},
+		})
+		if err != nil {
+			return names, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return names, err
+		}
+		names = append(names, mapped)
+	}
+	return names, tw.Close()
+}
+
+// ImportArchive reads a tar archive written by ExportArchive from r and
+// stores the databases rules selects as main databases under their mapped
+// names, like Put, returning the names stored. The import is all or
+// nothing: nothing is stored if reading the archive fails, a validator set
+// with WithValidators rejects a database or a connection is using one of
+// the names.
+//
+// Each database gets back the UUID it was exported with, unless another
+// file of the store already has it, as when an archive is imported next to
+// its originals: the copy then keeps the UUID of the file it replaces, or
+// a new one.
+func (v *MemVFS) ImportArchive(r io.Reader, rules NamespaceRules) ([]string, error) {
+	type file struct {
+		name string
+		uuid UUID
+		data []byte
+	}
+	mapName := rules.mapper()
+	tr := tar.NewReader(r)
+	var files []file
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		mapped, ok, err := mapName(hdr.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		var id UUID
+		if s, ok := hdr.PAXRecords[paxUUID]; ok {
+			if id, err = ParseUUID(s); err != nil {
+				return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.Validate(mapped, data); err != nil {
+			return nil, err
+		}
+		files = append(files, file{mapped, id, data})
+	}
+
+	err := v.Batch(func(tx *AdminTx) error {
+		for _, f := range files {
+			if err := tx.put(f.name, f.data, true); err != nil {
+				return err
+			}
+			if f.uuid == (UUID{}) {
+				continue
+			}
+			if err := tx.SetUUID(f.name, f.uuid); err != nil && !errors.Is(err, ErrUUIDInUse) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.name
+	}
+	return names, nil
+}
@@ -0,0 +1,84 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestArchive(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:test-archive-src.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (data TEXT); INSERT INTO demo VALUES ('v1')`); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	image, err := v.GetFile("test-archive-src.db")
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+
+	src := memvfs.New()
+	for _, name := range []string{"prod/users.db", "prod/orders.db", "prod/cache.db", "dev/users.db"} {
+		if err := src.PutFile(name, image); err != nil {
+			t.Fatalf("PutFile error: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	names, err := src.ExportArchive(&buf, memvfs.NamespaceRules{
+		Include:  []string{"prod/*"},
+		Exclude:  []string{"*/cache.db"},
+		Mappings: []memvfs.PrefixMapping{{From: "prod/", To: ""}},
+	})
+	if err != nil {
+		t.Fatalf("ExportArchive error: %v", err)
+	}
+	if want := []string{"orders.db", "users.db"}; !slices.Equal(names, want) {
+		t.Fatalf("Exported %v, want %v", names, want)
+	}
+
+	dst := memvfs.New()
+	names, err = dst.ImportArchive(bytes.NewReader(buf.Bytes()), memvfs.NamespaceRules{
+		Exclude:  []string{"orders.db"},
+		Mappings: []memvfs.PrefixMapping{{From: "", To: "restore-2024/"}},
+	})
+	if err != nil {
+		t.Fatalf("ImportArchive error: %v", err)
+	}
+	if want := []string{"restore-2024/users.db"}; !slices.Equal(names, want) {
+		t.Fatalf("Imported %v, want %v", names, want)
+	}
+	if got, err := dst.GetFile("restore-2024/users.db"); err != nil || !bytes.Equal(got, image) {
+		t.Errorf("Imported file differs from the exported one: %v", err)
+	}
+	srcInfo, _ := src.Stat("prod/users.db")
+	if info, err := dst.Stat("restore-2024/users.db"); err != nil || info.UUID != srcInfo.UUID {
+		t.Errorf("Expected the import to keep UUID %v, got %v, %v", srcInfo.UUID, info.UUID, err)
+	}
+	if files := dst.ListFiles(""); len(files) != 1 {
+		t.Errorf("Expected 1 file in the destination, got %d", len(files))
+	}
+
+	// Names mapped onto each other are refused rather than overwritten.
+	_, err = dst.ImportArchive(bytes.NewReader(buf.Bytes()), memvfs.NamespaceRules{
+		Mappings: []memvfs.PrefixMapping{{From: "orders", To: "users"}},
+	})
+	if err == nil {
+		t.Errorf("Expected an error importing two files under one name")
+	}
+
+	// A rejected database leaves the destination unchanged.
+	strict := memvfs.New(memvfs.WithValidators(memvfs.ValidateMaxSize(0)))
+	if _, err := strict.ImportArchive(bytes.NewReader(buf.Bytes()), memvfs.NamespaceRules{}); err == nil {
+		t.Errorf("Expected the validator to reject the import")
+	}
+	if files := strict.ListFiles(""); len(files) != 0 {
+		t.Errorf("Expected a rejected import to store nothing, found %d files", len(files))
+	}
+}
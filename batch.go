@@ -0,0 +1,237 @@
+package memvfs
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// AdminTx stages administrative changes for Batch.
+type AdminTx struct {
+	v *MemVFS
+
+	// staged holds the pending state of every name touched, nil meaning
+	// deleted. puts holds new contents for existing entries.
+	staged map[string]*entry
+	puts   map[*entry][]byte
+	uuids  map[*entry]UUID
+}
+
+// Batch runs fn and applies the changes it stages on tx all at once: Open
+// and Access observe either none or all of them. If fn returns an error,
+// nothing is applied.
+//
+// fn runs with the store locked and must not call other methods of v.
+func (v *MemVFS) Batch(fn func(tx *AdminTx) error) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	tx := &AdminTx{
+		v:      v,
+		staged: make(map[string]*entry),
+		puts:   make(map[*entry][]byte),
+		uuids:  make(map[*entry]UUID),
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	// Encrypt the files to be sealed first, as that can fail and nothing
+	// may be applied then.
+	sealed := make(map[*entry]*encryptedSource)
+	sealAs := func(e *entry, data []byte) error {
+		if _, ok := sealed[e]; ok || !v.sealable(e) {
+			return nil
+		}
+		src, err := newEncryptedSource(v.encryptKey, data)
+		if err != nil {
+			return err
+		}
+		sealed[e] = src
+		return nil
+	}
+	for e, data := range tx.puts {
+		if err := sealAs(e, data); err != nil {
+			return err
+		}
+	}
+	for _, e := range tx.staged {
+		if e == nil || !v.sealable(e) {
+			continue
+		}
+		if err := v.thaw(e); err != nil {
+			return err
+		}
+		if err := sealAs(e, e.data); err != nil {
+			return err
+		}
+	}
+
+	for e, data := range tx.puts {
+		e.update(data)
+	}
+	for e, id := range tx.uuids {
+		e.uuid = id
+	}
+	// dropped holds the entries deleted or replaced, by name, some of which
+	// may live on under another name.
+	dropped := make(map[*entry]string)
+	for name, e := range tx.staged {
+		old, ok := v.files[name]
+		if ok && old != e {
+			dropped[old] = name
+		}
+		if e == nil {
+			delete(v.files, name)
+			continue
+		}
+		if ok && old != e {
+			// Idle connections keep their handles across a Put.
+			for f := range old.handles {
+				e.handles[f] = struct{}{}
+			}
+			clear(old.handles)
+		}
+		v.files[name] = e
+	}
+	for e, src := range sealed {
+		v.sealWith(e, src)
+	}
+	for _, e := range v.files {
+		delete(dropped, e)
+	}
+	for e, name := range dropped {
+		if err := e.release(); err != nil {
+			v.log(slog.LevelError, "releasing replaced file failed", "file", v.LogName(name), "error", err)
+		}
+		v.forget(e)
+	}
+	v.recount()
+	return nil
+}
+
+// get returns the current state of name as seen by the transaction.
+func (tx *AdminTx) get(name string) (*entry, bool) {
+	if e, ok := tx.staged[name]; ok {
+		return e, e != nil
+	}
+	e, ok := tx.v.files[name]
+	return e, ok
+}
+
+// Put stores a copy of data as the main database name, replacing any
+// existing file but keeping its UUID. It fails with ErrBusy if a connection
+// is using name. The validators set with WithValidators are not run; see
+// Validate.
+//
+// Replacing an existing file only rewrites the blocks that differ, in place,
+// so refreshing a dataset with mostly unchanged contents does not hold two
+// full copies in memory. data must not be modified until Batch returns.
+func (tx *AdminTx) Put(name string, data []byte) error {
+	return tx.put(name, data, false)
+}
+
+// put is Put, keeping data itself as the buffer of a new file if owned.
+func (tx *AdminTx) put(name string, data []byte, owned bool) error {
+	id := newUUID()
+	if e, ok := tx.get(name); ok {
+		if e.locked(sqlite3vfs.LockShared) {
+			return fmt.Errorf("%w: %s", ErrBusy, tx.v.LogName(name))
+		}
+		if e.src == nil {
+			if err := tx.v.thaw(e); err != nil {
+				return err
+			}
+			tx.puts[e] = data
+			tx.staged[name] = e
+			return nil
+		}
+		id = tx.uuidOf(e)
+	}
+
+	if !owned {
+		data = bytes.Clone(data)
+	}
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	tx.staged[name] = &entry{
+		uuid:    id,
+		data:    data,
+		flags:   flags,
+		role:    roleFromFlags(flags),
+		handles: make(map[*MemFile]struct{}),
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+// Rename moves oldName to newName, replacing newName if it exists.
+// oldName may not be open by a connection. Like Put, replacing newName only
+// fails with ErrBusy while a connection is using it: idle connections keep
+// their handles and see the renamed file from their next transaction.
+func (tx *AdminTx) Rename(oldName, newName string) error {
+	e, ok := tx.get(oldName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, tx.v.LogName(oldName))
+	}
+	if len(e.handles) > 0 {
+		return fmt.Errorf("%w: %s", ErrBusy, tx.v.LogName(oldName))
+	}
+	if target, ok := tx.get(newName); ok && target.locked(sqlite3vfs.LockShared) {
+		return fmt.Errorf("%w: %s", ErrBusy, tx.v.LogName(newName))
+	}
+	if oldName == newName {
+		return nil
+	}
+
+	tx.staged[newName] = e
+	tx.staged[oldName] = nil
+	return nil
+}
+
+// Delete removes name, which may not be open by a connection.
+func (tx *AdminTx) Delete(name string) error {
+	e, ok := tx.get(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, tx.v.LogName(name))
+	}
+	if len(e.handles) > 0 {
+		return fmt.Errorf("%w: %s", ErrBusy, tx.v.LogName(name))
+	}
+
+	tx.staged[name] = nil
+	return nil
+}
+
+// updateBlockSize is the granularity at which update compares contents.
+const updateBlockSize = 4096
+
+// update makes e's contents equal to data, copying only the blocks that
+// differ, and returns the number of bytes copied.
+func (e *entry) update(data []byte) int64 {
+	resized := len(e.data) != len(data)
+	if cap(e.data) < len(data) {
+		grown := make([]byte, len(data))
+		copy(grown, e.data)
+		e.data = grown
+	} else {
+		e.data = e.data[:len(data)]
+	}
+
+	var copied int64
+	for off := 0; off < len(data); off += updateBlockSize {
+		end := min(off+updateBlockSize, len(data))
+		if !bytes.Equal(e.data[off:end], data[off:end]) {
+			e.own()
+			e.mutating(nil, int64(off), int64(end))
+			copy(e.data[off:end], data[off:end])
+			copied += int64(end - off)
+		}
+	}
+	if copied > 0 || resized {
+		e.modified()
+	}
+	return copied
+}
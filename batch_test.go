@@ -0,0 +1,250 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestBatch(t *testing.T) {
+	srcName := "test-batch-src.db"
+	src, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", srcName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer src.Close()
+	_, err = src.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	_, err = src.Exec(`INSERT INTO demo(data) VALUES ('v2')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	image, err := v.GetFile(srcName)
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+
+	// A failing batch applies nothing.
+	errAbort := errors.New("abort")
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		if err := tx.Put("test-batch-staging.db", image); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Expected batch error, got %v", err)
+	}
+	if ok, _ := v.Access("test-batch-staging.db", 0); ok {
+		t.Fatalf("Aborted batch left a file behind")
+	}
+
+	// Open files cannot be renamed away.
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Rename(srcName, "test-batch-moved.db")
+	})
+	if !errors.Is(err, memvfs.ErrBusy) {
+		t.Fatalf("Expected ErrBusy renaming an open file, got %v", err)
+	}
+
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		if err := tx.Put("test-batch-staging.db", image); err != nil {
+			return err
+		}
+		if err := tx.Put("test-batch-old.db", []byte("stale")); err != nil {
+			return err
+		}
+		if err := tx.Rename("test-batch-staging.db", "test-batch-live.db"); err != nil {
+			return err
+		}
+		return tx.Delete("test-batch-old.db")
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"test-batch-staging.db": false,
+		"test-batch-old.db":     false,
+		"test-batch-live.db":    true,
+	} {
+		if ok, _ := v.Access(name, 0); ok != want {
+			t.Errorf("Access(%v) = %v, want %v", name, ok, want)
+		}
+	}
+
+	live, err := sql.Open("sqlite3", "file:test-batch-live.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer live.Close()
+	var data string
+	if err := live.QueryRow(`SELECT data FROM demo`).Scan(&data); err != nil || data != "v2" {
+		t.Errorf("Query on renamed file = %q, %v", data, err)
+	}
+}
+
+func TestBatchPutRefresh(t *testing.T) {
+	dbName := "test-batch-refresh.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(100))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	before, _ := v.GetFile(dbName)
+	before = append([]byte(nil), before...)
+
+	_, err = db.Exec(`UPDATE demo SET data = 'refreshed' WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	after, _ := v.GetFile(dbName)
+	after = append([]byte(nil), after...)
+
+	// Roll the file back and forward again while the connection is idle.
+	for _, image := range [][]byte{before, after} {
+		err = v.Batch(func(tx *memvfs.AdminTx) error {
+			return tx.Put(dbName, image)
+		})
+		if err != nil {
+			t.Fatalf("Batch error: %v", err)
+		}
+		got, _ := v.GetFile(dbName)
+		if string(got) != string(image) {
+			t.Fatalf("Put did not reproduce the image")
+		}
+	}
+
+	var data string
+	if err := db.QueryRow(`SELECT data FROM demo WHERE id = 1`).Scan(&data); err != nil || data != "refreshed" {
+		t.Errorf("Query after refresh = %q, %v", data, err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	src, err := sql.Open("sqlite3", "file:test-rename-src.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer src.Close()
+	var images [][]byte
+	for _, stmt := range []string{
+		`CREATE TABLE demo (data TEXT); INSERT INTO demo VALUES ('v1')`,
+		`UPDATE demo SET data = 'v2'`,
+	} {
+		if _, err := src.Exec(stmt); err != nil {
+			t.Fatalf("Exec error: %v", err)
+		}
+		image, err := v.GetFile("test-rename-src.db")
+		if err != nil {
+			t.Fatalf("GetFile error: %v", err)
+		}
+		images = append(images, bytes.Clone(image))
+	}
+
+	if err := v.PutFile("test-rename-live.db", images[0]); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	live, err := sql.Open("sqlite3", "file:test-rename-live.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer live.Close()
+	live.SetMaxOpenConns(1)
+	query := func() string {
+		var data string
+		if err := live.QueryRow(`SELECT data FROM demo`).Scan(&data); err != nil {
+			t.Fatalf("Query error: %v", err)
+		}
+		return data
+	}
+	if got := query(); got != "v1" {
+		t.Fatalf("Expected v1, got %q", got)
+	}
+
+	// An idle connection to the live database sees the promoted one.
+	if err := v.PutFile("test-rename-staging.db", images[1]); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	if err := v.Rename("test-rename-staging.db", "test-rename-live.db"); err != nil {
+		t.Fatalf("Rename error: %v", err)
+	}
+	if got := query(); got != "v2" {
+		t.Errorf("Expected the renamed database to be live, got %q", got)
+	}
+	if _, err := v.Stat("test-rename-staging.db"); err != memvfs.ErrNotFound {
+		t.Errorf("Expected the staging name to be gone, got %v", err)
+	}
+
+	// One in a transaction holds it off.
+	tx, err := live.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	var data string
+	if err := tx.QueryRow(`SELECT data FROM demo`).Scan(&data); err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if err := v.PutFile("test-rename-staging.db", images[0]); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	if err := v.Rename("test-rename-staging.db", "test-rename-live.db"); !errors.Is(err, memvfs.ErrBusy) {
+		t.Errorf("Expected ErrBusy renaming over a database in use, got %v", err)
+	}
+	tx.Rollback()
+}
+
+// closingReader records whether it was closed.
+type closingReader struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (r *closingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestBatchReleasesDroppedFiles(t *testing.T) {
+	bv := memvfs.New()
+	replaced := &closingReader{Reader: bytes.NewReader(make([]byte, 4096))}
+	deleted := &closingReader{Reader: bytes.NewReader(make([]byte, 4096))}
+	if err := bv.Overlay(replaced, 4096, "replaced.db"); err != nil {
+		t.Fatalf("Overlay error: %v", err)
+	}
+	if err := bv.Overlay(deleted, 4096, "deleted.db"); err != nil {
+		t.Fatalf("Overlay error: %v", err)
+	}
+	err := bv.Batch(func(tx *memvfs.AdminTx) error {
+		return errors.Join(
+			tx.Put("replaced.db", make([]byte, 8192)),
+			tx.Delete("deleted.db"),
+		)
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	if !replaced.closed || !deleted.closed {
+		t.Errorf("Expected the replaced and deleted files to be released, got %v and %v", replaced.closed, deleted.closed)
+	}
+	if s := bv.Stats(); s.Bytes != 8192 {
+		t.Errorf("Expected only the new file to be counted, got %d bytes", s.Bytes)
+	}
+}
@@ -0,0 +1,118 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchBackends are the storages BenchmarkCompare runs every workload
+// against, given a unique database name: SQLite's own in-memory database, a
+// file on tmpfs (or the test's temporary directory where there is none) and
+// memvfs.
+var benchBackends = []struct {
+	name string
+	dsn  func(b *testing.B, name string) string
+}{
+	{"memory", func(b *testing.B, name string) string {
+		return fmt.Sprintf("file:%s?mode=memory&cache=shared", name)
+	}},
+	{"tmpfs", func(b *testing.B, name string) string {
+		dir := "/dev/shm"
+		if _, err := os.Stat(dir); err != nil {
+			dir = b.TempDir()
+		}
+		path := filepath.Join(dir, name)
+		b.Cleanup(func() {
+			os.Remove(path)
+			os.Remove(path + "-journal")
+		})
+		return path
+	}},
+	{"memvfs", func(b *testing.B, name string) string {
+		return fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name)
+	}},
+}
+
+// benchWorkloads run b.N operations against a database holding benchRows
+// rows of demo.
+var benchWorkloads = []struct {
+	name string
+	run  func(b *testing.B, db *sql.DB)
+}{
+	{"insert", func(b *testing.B, db *sql.DB) {
+		data := randSeq(200)
+		for i := 0; i < b.N; i++ {
+			if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, data); err != nil {
+				b.Fatalf("Insert error: %v", err)
+			}
+		}
+	}},
+	{"point-select", func(b *testing.B, db *sql.DB) {
+		var data string
+		for i := 0; i < b.N; i++ {
+			if err := db.QueryRow(`SELECT data FROM demo WHERE id = ?`, i%benchRows+1).Scan(&data); err != nil {
+				b.Fatalf("Query error: %v", err)
+			}
+		}
+	}},
+	{"scan", func(b *testing.B, db *sql.DB) {
+		var n int
+		for i := 0; i < b.N; i++ {
+			if err := db.QueryRow(`SELECT count(*) FROM demo WHERE data LIKE '%xyz%'`).Scan(&n); err != nil {
+				b.Fatalf("Query error: %v", err)
+			}
+		}
+	}},
+}
+
+const benchRows = 10000
+
+// BenchmarkCompare runs the same workloads on memvfs and the storages it
+// is usually weighed against. Compare the backends with
+//
+//	go test -run '^$' -bench Compare -count 10 | benchstat -col /backend -
+func BenchmarkCompare(b *testing.B) {
+	seq := 0
+	for _, w := range benchWorkloads {
+		b.Run("workload="+w.name, func(b *testing.B) {
+			for _, backend := range benchBackends {
+				b.Run("backend="+backend.name, func(b *testing.B) {
+					seq++
+					db, err := sql.Open("sqlite3", backend.dsn(b, fmt.Sprintf("bench-compare-%d.db", seq)))
+					if err != nil {
+						b.Fatalf("Failed to open DB: %v", err)
+					}
+					defer db.Close()
+					db.SetMaxOpenConns(1)
+					benchPopulate(b, db)
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					w.run(b, db)
+				})
+			}
+		})
+	}
+}
+
+// benchPopulate creates demo with benchRows rows.
+func benchPopulate(b *testing.B, db *sql.DB) {
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		b.Fatalf("Create table error: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatalf("Begin error: %v", err)
+	}
+	for i := 0; i < benchRows; i++ {
+		if _, err := tx.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			b.Fatalf("Insert error: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatalf("Commit error: %v", err)
+	}
+}
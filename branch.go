@@ -0,0 +1,165 @@
+package memvfs
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// ErrConflict is returned by Promote when the base of a branch has been
+// modified since the branch was forked.
+var ErrConflict = errors.New("memvfs: base changed since branch was forked")
+
+// Branch describes a writable fork of a database.
+type Branch struct {
+	Name string
+	Base string
+
+	// Snapshot is the fork point, kept as a labeled snapshot of the base so
+	// that its blocks stay shared with the base's other snapshots.
+	Snapshot SnapshotID
+	Created  time.Time
+
+	// baseVersion is the base's version at the fork point.
+	baseVersion uint64
+}
+
+// Branch forks the database name into a new writable database branchName,
+// starting from a consistent snapshot of name. The branch is an ordinary
+// file that outlives its connections; discard it with DropBranch or make it
+// the new base with Promote.
+func (v *MemVFS) Branch(name, branchName string) (Branch, error) {
+	v.mu.Lock()
+	_, taken := v.files[branchName]
+	v.mu.Unlock()
+	if taken {
+		return Branch{}, fmt.Errorf("%w: %s", ErrExist, v.LogName(branchName))
+	}
+
+	snap, err := v.SnapshotGroup(name)
+	if err != nil {
+		return Branch{}, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.files[branchName]; ok {
+		delete(v.snapshots, snap.ID)
+		return Branch{}, fmt.Errorf("%w: %s", ErrExist, v.LogName(branchName))
+	}
+	img := v.snapshots[snap.ID].images[name]
+	v.snapshots[snap.ID].Label = "branch " + branchName
+
+	e := v.lookup(branchName, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	e.data = make([]byte, img.size)
+	img.ReadAt(e.data, 0)
+	e.retain = true
+
+	b := &Branch{
+		Name:        branchName,
+		Base:        name,
+		Snapshot:    snap.ID,
+		Created:     snap.Created,
+		baseVersion: img.version,
+	}
+	if v.branches == nil {
+		v.branches = make(map[string]*Branch)
+	}
+	v.branches[branchName] = b
+	return *b, nil
+}
+
+// Branches lists the branches of the database name, oldest first.
+func (v *MemVFS) Branches(name string) []Branch {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var branches []Branch
+	for _, b := range v.branches {
+		if b.Base == name {
+			branches = append(branches, *b)
+		}
+	}
+	slices.SortFunc(branches, func(a, b Branch) int {
+		return cmp.Compare(a.Snapshot, b.Snapshot)
+	})
+	return branches
+}
+
+// DropBranch deletes the branch and its fork point. It fails with ErrBusy
+// while a connection has the branch open.
+func (v *MemVFS) DropBranch(branchName string) error {
+	return v.endBranch(branchName, func(tx *AdminTx, b *Branch) error {
+		return tx.Delete(branchName)
+	})
+}
+
+// ForkForDebug branches a consistent snapshot of the live database name
+// under a generated name and returns a DSN opening it, so its state can be
+// inspected, and even modified, from a REPL without touching name. cleanup
+// drops the fork once the connections opened with dsn are closed.
+func (v *MemVFS) ForkForDebug(name string) (dsn string, cleanup func() error, err error) {
+	v.mu.Lock()
+	vfsName := v.vfsName
+	v.tempSeq++
+	forkName := fmt.Sprintf("%s-debug-%d", name, v.tempSeq)
+	v.mu.Unlock()
+	if vfsName == "" {
+		return "", nil, ErrNotRegistered
+	}
+
+	if _, err := v.Branch(name, forkName); err != nil {
+		return "", nil, err
+	}
+	cleanup = func() error {
+		return v.DropBranch(forkName)
+	}
+	return fmt.Sprintf("file:%s?vfs=%s&cache=shared", forkName, vfsName), cleanup, nil
+}
+
+// Promote atomically replaces name, which must be the branch's base, with
+// the branch's contents and ends the branch. It fails with ErrConflict if
+// name has been written since the fork, as promoting would silently discard
+// those writes, and with ErrBusy while a connection has either file open.
+func (v *MemVFS) Promote(branchName, name string) error {
+	return v.endBranch(branchName, func(tx *AdminTx, b *Branch) error {
+		if name != b.Base {
+			return fmt.Errorf("memvfs: %s is a branch of %s, not %s", v.LogName(branchName), v.LogName(b.Base), v.LogName(name))
+		}
+		base, ok := tx.get(name)
+		if ok && base.version != b.baseVersion {
+			return fmt.Errorf("%w: %s", ErrConflict, v.LogName(name))
+		}
+		if ok && len(base.handles) > 0 {
+			return fmt.Errorf("%w: %s", ErrBusy, v.LogName(name))
+		}
+		if err := tx.Rename(branchName, name); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		// The promoted file takes the place of the base, identity included.
+		return tx.SetUUID(name, base.uuid)
+	})
+}
+
+func (v *MemVFS) endBranch(branchName string, fn func(tx *AdminTx, b *Branch) error) error {
+	return v.Batch(func(tx *AdminTx) error {
+		b, ok := v.branches[branchName]
+		if !ok {
+			return fmt.Errorf("%w: branch %s", ErrNotFound, v.LogName(branchName))
+		}
+		if err := fn(tx, b); err != nil {
+			return err
+		}
+		delete(v.branches, branchName)
+		delete(v.snapshots, b.Snapshot)
+		return nil
+	})
+}
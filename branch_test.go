@@ -0,0 +1,160 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func countRows(t *testing.T, name string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil {
+		t.Fatalf("Count %s: %v", name, err)
+	}
+	return count
+}
+
+func TestBranch(t *testing.T) {
+	base := "test-branch-base.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", base))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('base')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	for _, name := range []string{"test-branch-a.db", "test-branch-b.db"} {
+		if _, err := v.Branch(base, name); err != nil {
+			t.Fatalf("Branch error: %v", err)
+		}
+	}
+	if _, err := v.Branch(base, "test-branch-a.db"); !errors.Is(err, memvfs.ErrExist) {
+		t.Errorf("Expected ErrExist, got %v", err)
+	}
+	branches := v.Branches(base)
+	if len(branches) != 2 || branches[0].Name != "test-branch-a.db" || branches[1].Base != base {
+		t.Fatalf("Unexpected branches %+v", branches)
+	}
+
+	bdb, err := sql.Open("sqlite3", "file:test-branch-a.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open branch: %v", err)
+	}
+	if _, err := bdb.Exec(`INSERT INTO demo(data) VALUES ('branch')`); err != nil {
+		t.Fatalf("Insert on branch error: %v", err)
+	}
+	bdb.Close()
+	if n := countRows(t, base); n != 1 {
+		t.Errorf("Expected base to be unaffected by the branch, got %d rows", n)
+	}
+
+	if err := v.DropBranch("test-branch-b.db"); err != nil {
+		t.Fatalf("DropBranch error: %v", err)
+	}
+	if err := v.Promote("test-branch-a.db", base); !errors.Is(err, memvfs.ErrBusy) {
+		t.Errorf("Expected ErrBusy while base is open, got %v", err)
+	}
+	db.Close()
+	if err := v.Promote("test-branch-a.db", "test-branch-other.db"); err == nil {
+		t.Errorf("Expected error promoting onto a name other than the base")
+	}
+	if err := v.Promote("test-branch-a.db", base); err != nil {
+		t.Fatalf("PromoteBranch error: %v", err)
+	}
+	if n := countRows(t, base); n != 2 {
+		t.Errorf("Expected promoted base to have 2 rows, got %d", n)
+	}
+	if len(v.Branches(base)) != 0 {
+		t.Errorf("Expected no branches left")
+	}
+}
+
+func TestPromoteConflict(t *testing.T) {
+	src := "test-promote-src.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", src))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	// Branches outlive their connections, so one serves as a base that
+	// survives being closed.
+	base := "test-promote-base.db"
+	if _, err := v.Branch(src, base); err != nil {
+		t.Fatalf("Branch error: %v", err)
+	}
+	if _, err := v.Branch(base, "test-promote-branch.db"); err != nil {
+		t.Fatalf("Branch error: %v", err)
+	}
+	bdb, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", base))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if _, err := bdb.Exec(`INSERT INTO demo(data) VALUES ('advanced')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	bdb.Close()
+
+	if err := v.Promote("test-promote-branch.db", base); !errors.Is(err, memvfs.ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if err := v.DropBranch("test-promote-branch.db"); err != nil {
+		t.Errorf("DropBranch error: %v", err)
+	}
+}
+
+func TestForkForDebug(t *testing.T) {
+	base := "test-fork-debug.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", base))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	dsn, cleanup, err := v.ForkForDebug(base)
+	if err != nil {
+		t.Fatalf("ForkForDebug error: %v", err)
+	}
+	fork, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open fork: %v", err)
+	}
+	if _, err := fork.Exec(`DELETE FROM demo; INSERT INTO demo(data) VALUES ('poke')`); err != nil {
+		t.Fatalf("Write to fork error: %v", err)
+	}
+	fork.Close()
+	if n := countRows(t, base); n != 0 {
+		t.Errorf("Expected the live database to be untouched, got %d rows", n)
+	}
+
+	if err := cleanup(); err != nil {
+		t.Fatalf("cleanup error: %v", err)
+	}
+	if len(v.Branches(base)) != 0 {
+		t.Errorf("Expected cleanup to drop the fork")
+	}
+}
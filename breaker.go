@@ -0,0 +1,80 @@
+package memvfs
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// Defaults for WithCircuitBreaker.
+const (
+	DefaultBreakerFailures = 5
+	DefaultBreakerCooldown = 10 * time.Second
+)
+
+// WithCircuitBreaker sets when reads of a backend-backed file, such as a
+// mounted backup, stop reaching the backend: after failures consecutive
+// failed or timed out reads the circuit opens, and for cooldown reads are
+// served from blocks already fetched where possible and fail fast with
+// SQLITE_IOERR otherwise. The next read after cooldown is let through to
+// probe the backend. failures <= 0 disables the breaker.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(v *MemVFS) {
+		v.breakerFailures = failures
+		v.breakerCooldown = cooldown
+	}
+}
+
+// breaker is the circuit breaker state of an entry.
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// cachedReader is implemented by sources that can serve part of their
+// contents without reaching their backend.
+type cachedReader interface {
+	// readCached reads p at off if all of it is cached.
+	readCached(p []byte, off int64) (int, bool)
+}
+
+// readBackend reads from e's backend through the circuit breaker.
+func (v *MemVFS) readBackend(f *MemFile, e *entry, src io.ReaderAt, p []byte, off int64) (int, error) {
+	// Reads served from cache say nothing about the backend's health.
+	if c, ok := src.(cachedReader); ok {
+		if n, ok := c.readCached(p, off); ok {
+			return n, nil
+		}
+	}
+
+	v.lockEntry(e)
+	open := v.circuitOpen(e)
+	v.unlockEntry(e)
+	if open {
+		return 0, sqlite3vfs.IOError
+	}
+
+	n, err := v.readSource(f, src, p, off)
+
+	v.lockEntry(e)
+	defer v.unlockEntry(e)
+	if err != nil && err != io.EOF {
+		v.logSampled(slog.LevelWarn, "backend read failed", "file", v.LogName(f.fileName), "error", err)
+		e.breaker.failures++
+		if v.breakerFailures > 0 && e.breaker.failures >= v.breakerFailures {
+			e.breaker.openUntil = time.Now().Add(v.breakerCooldown)
+			v.log(slog.LevelWarn, "circuit breaker opened", "file", v.LogName(f.fileName), "cooldown", v.breakerCooldown)
+		}
+	} else {
+		e.breaker = breaker{}
+	}
+	return n, err
+}
+
+// circuitOpen reports whether reads of e currently bypass its backend.
+// e must be locked.
+func (v *MemVFS) circuitOpen(e *entry) bool {
+	return v.breakerFailures > 0 && time.Now().Before(e.breaker.openUntil)
+}
@@ -0,0 +1,105 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+// failingStore serves a DirBackupStore whose reads fail while fail is set.
+type failingStore struct {
+	memvfs.DirBackupStore
+	fail  atomic.Bool
+	calls atomic.Int64
+}
+
+type failingReader struct {
+	io.ReaderAt
+	s *failingStore
+}
+
+func (r failingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.s.calls.Add(1)
+	if r.s.fail.Load() {
+		return 0, errors.New("backend unavailable")
+	}
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func (s *failingStore) OpenBackup(id string) (io.ReaderAt, int64, error) {
+	r, size, err := s.DirBackupStore.OpenBackup(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return failingReader{r, s}, size, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := sql.Open("sqlite3", filepath.Join(dir, "backup-1"))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	_, err = disk.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	_, err = disk.Exec(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 2000)
+		INSERT INTO demo(data) SELECT hex(randomblob(200)) FROM n`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	disk.Close()
+
+	bv := memvfs.New(memvfs.WithCircuitBreaker(2, time.Hour))
+	if err := bv.Register("memvfs-breaker"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	store := &failingStore{DirBackupStore: memvfs.DirBackupStore(dir)}
+	name := "test-breaker.db"
+	if err := bv.MountBackup(store, "backup-1", name); err != nil {
+		t.Fatalf("MountBackup error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-breaker")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	cached := `SELECT count(*) FROM demo WHERE id < 10`
+	uncached := `SELECT data FROM demo WHERE id = 2000`
+	if _, err := db.Exec(cached); err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+
+	store.fail.Store(true)
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(uncached); err == nil {
+			t.Fatalf("Expected query to fail while the backend is down")
+		}
+	}
+	if info, _ := bv.Stat(name); !info.CircuitOpen {
+		t.Fatalf("Expected circuit to be open")
+	}
+	if bv.Stats().CircuitOpen != 1 {
+		t.Errorf("Expected Stats to count the open circuit")
+	}
+
+	calls := store.calls.Load()
+	if _, err := db.Exec(uncached); err == nil {
+		t.Errorf("Expected uncached read to fail fast")
+	}
+	if store.calls.Load() != calls {
+		t.Errorf("Expected no backend calls while the circuit is open")
+	}
+	if _, err := db.Exec(cached); err != nil {
+		t.Errorf("Expected cached read to succeed while the circuit is open: %v", err)
+	}
+}
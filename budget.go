@@ -0,0 +1,76 @@
+package memvfs
+
+// WithMemoryBudget caps the bytes the store holds in memory across all its
+// files at limit, so that a growing database fails with SQLITE_FULL
+// ("database or disk is full") and its transaction rolls back, instead of
+// the process running out of memory. Files served by a backend or spilled
+// to disk do not count; Stats reports the usage. limit <= 0 disables the
+// budget.
+//
+// SQLite reports any failed write as a disk I/O error, so the budget is
+// enforced where its error reaches SQLite as is: a sync or truncate that
+// would leave the store over budget fails, and so does starting a write
+// transaction while it already is. A transaction may thus exceed the
+// budget by what it wrote before its commit syncs; with synchronous=OFF,
+// where nothing syncs, the next write transaction fails instead.
+func WithMemoryBudget(limit int64) Option {
+	return func(v *MemVFS) {
+		v.memLimit = limit
+	}
+}
+
+// WithMaxMemory caps the bytes the store holds in memory at limit, the
+// same as WithMemoryBudget.
+func WithMaxMemory(limit int64) Option {
+	return WithMemoryBudget(limit)
+}
+
+// overMemory reports whether growing e's buffer to size exceeds the memory
+// budget. e must be locked.
+func (v *MemVFS) overMemory(e *entry, size int64) bool {
+	grow := size - int64(len(e.data))
+	return grow > 0 && v.overMemoryBy(e, grow)
+}
+
+// syncOverMemory reports whether syncing f, publishing its buffered writes
+// to e, leaves the store over its memory budget. f.mu must be held and e
+// locked.
+func (v *MemVFS) syncOverMemory(f *MemFile, e *entry) bool {
+	return v.overMemoryBy(e, max(f.pendingEnd-int64(len(e.data)), 0))
+}
+
+// overMemoryBy reports whether the store is over its memory budget once e,
+// if held in memory, grows by grow bytes. e must be locked.
+func (v *MemVFS) overMemoryBy(e *entry, grow int64) bool {
+	if v.memLimit <= 0 || e.src != nil || e.disk != nil {
+		return false
+	}
+	return v.memUsed.Load()+grow > v.memLimit
+}
+
+// account brings the store's memory usage up to date with e's buffer. e
+// must be locked.
+func (v *MemVFS) account(e *entry) {
+	if n := e.footprint(); n != e.charged {
+		v.memUsed.Add(n - e.charged)
+		e.charged = n
+	}
+}
+
+// forget stops counting e, which was removed from the store. v.mu must be
+// held.
+func (v *MemVFS) forget(e *entry) {
+	v.memUsed.Add(-e.charged)
+	e.charged = 0
+}
+
+// recount recomputes the store's memory usage after administrative changes
+// that replace, move or rewrite files. v.mu must be held.
+func (v *MemVFS) recount() {
+	var used int64
+	for _, e := range v.files {
+		e.charged = e.footprint()
+		used += e.charged
+	}
+	v.memUsed.Store(used)
+}
@@ -0,0 +1,66 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	const budget = 256 << 10
+	bv := memvfs.New(memvfs.WithMemoryBudget(budget))
+	if err := bv.Register("memvfs-budget"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	err := bv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("ballast.db", bytes.Repeat([]byte{1}, budget/2))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:test-budget.db?vfs=memvfs-budget&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	insert := func() error {
+		_, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(4096))
+		return err
+	}
+	inserted := 0
+	for err = insert(); err == nil; err = insert() {
+		if inserted++; inserted > 1000 {
+			t.Fatalf("Expected inserts to hit the memory budget")
+		}
+	}
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrFull {
+		t.Errorf("Expected SQLITE_FULL from an insert over the budget, got %v", err)
+	}
+	if s := bv.Stats(); s.Bytes > budget || s.MemoryBudget != budget {
+		t.Errorf("Expected usage within the %d byte budget, got %+v", budget, s)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil || count != inserted {
+		t.Errorf("Expected the failed insert to roll back leaving %d rows, got %d (%v)", inserted, count, err)
+	}
+
+	err = bv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Delete("ballast.db")
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	if err := insert(); err != nil {
+		t.Errorf("Insert error after freeing memory: %v", err)
+	}
+}
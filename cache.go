@@ -0,0 +1,280 @@
+package memvfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// WithBackingVFS makes the store a cache in front of backend: a main
+// database or journal that is not in the store is opened in backend, its
+// blocks are read through the first time SQLite needs them and kept, and
+// writes stay in memory until SQLite syncs the file, when they are written
+// back and backend's file is synced. Journals live in backend too, so a
+// crash loses no more than it would on backend itself.
+//
+// The store arbitrates locking, so backend's files must not be used by
+// anything else, another process included, while they are open through it.
+// Blocks read from backend are not charged against WithMemoryBudget.
+func WithBackingVFS(backend sqlite3vfs.VFS) Option {
+	return func(v *MemVFS) {
+		v.backing = backend
+	}
+}
+
+// backed reports whether a file opened with flags lives in v.backing.
+func (v *MemVFS) backed(flags sqlite3vfs.OpenFlag) bool {
+	return v.backing != nil &&
+		flags&(sqlite3vfs.OpenMainDB|sqlite3vfs.OpenMainJournal|sqlite3vfs.OpenSuperJournal) != 0 &&
+		flags&sqlite3vfs.OpenDeleteOnClose == 0
+}
+
+// openBacked opens name in v.backing and creates its entry, served by the
+// backend file. v.mu must be held.
+func (v *MemVFS) openBacked(name string, flags sqlite3vfs.OpenFlag) error {
+	file, _, err := v.backing.Open(name, flags)
+	if err != nil {
+		return err
+	}
+	src, err := newCacheSource(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	e := v.lookup(name, flags)
+	e.src = src
+	return nil
+}
+
+// cacheSource serves a file of a backing VFS, caching the blocks read and
+// holding those written until sync.
+type cacheSource struct {
+	*overlaySource
+	file sqlite3vfs.File
+
+	// dirty holds the blocks written since the last sync and fileSize the
+	// size of file as of then.
+	dirty    map[int64]bool
+	fileSize int64
+}
+
+func newCacheSource(file sqlite3vfs.File) (*cacheSource, error) {
+	size, err := file.FileSize()
+	if err != nil {
+		return nil, err
+	}
+	return &cacheSource{
+		overlaySource: newOverlaySource(file, size),
+		file:          file,
+		dirty:         make(map[int64]bool),
+		fileSize:      size,
+	}, nil
+}
+
+// ReadAt is overlaySource.ReadAt, keeping the blocks read.
+func (s *cacheSource) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for n < len(p) && off < s.size {
+		i := off / overlayBlockSize
+		b, err := s.block(i)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], b[off%overlayBlockSize:min(overlayBlockSize, s.size-i*overlayBlockSize)])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt is overlaySource.WriteAt, marking the blocks written dirty.
+func (s *cacheSource) WriteAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		i := off / overlayBlockSize
+		b, err := s.block(i)
+		if err != nil {
+			return n, err
+		}
+		s.dirty[i] = true
+		c := copy(b[off%overlayBlockSize:], p[n:])
+		n += c
+		off += int64(c)
+	}
+	s.size = max(s.size, off)
+	return n, nil
+}
+
+// sync writes the changes since the last sync back to the backend file and
+// syncs it.
+func (s *cacheSource) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Truncate first what a truncate hid, so that no stale bytes show
+	// between the blocks written.
+	if s.based < s.fileSize {
+		if err := s.file.Truncate(s.based); err != nil {
+			return err
+		}
+		s.fileSize = s.based
+	}
+	for i := range s.dirty {
+		off := i * overlayBlockSize
+		if off >= s.size {
+			continue
+		}
+		b := s.blocks[i][:min(overlayBlockSize, s.size-off)]
+		if _, err := s.file.WriteAt(b, off); err != nil {
+			return err
+		}
+		s.fileSize = max(s.fileSize, off+int64(len(b)))
+	}
+	if s.fileSize != s.size {
+		if err := s.file.Truncate(s.size); err != nil {
+			return err
+		}
+		s.fileSize = s.size
+	}
+	if err := s.file.Sync(sqlite3vfs.SyncNormal); err != nil {
+		return err
+	}
+	clear(s.dirty)
+	s.based = s.size
+	return nil
+}
+
+// Close writes back what was not synced, as SQLite does not sync files it
+// is done with, such as a journal it truncates to commit, and closes the
+// backend file.
+func (s *cacheSource) Close() error {
+	return errors.Join(s.sync(), s.file.Close())
+}
+
+// syncer is implemented by the sources of files whose writes are held until
+// SQLite syncs them.
+type syncer interface {
+	sync() error
+}
+
+// DirVFS is a sqlite3vfs.VFS over the files of a directory, for use as the
+// backend of WithBackingVFS. It does no locking of its own, leaving that to
+// the store in front of it.
+type DirVFS string
+
+func (d DirVFS) path(name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", sqlite3vfs.CantOpenError
+	}
+	return filepath.Join(string(d), name), nil
+}
+
+func (d DirVFS) Open(name string, flags sqlite3vfs.OpenFlag) (sqlite3vfs.File, sqlite3vfs.OpenFlag, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	mode := os.O_RDWR
+	if flags&sqlite3vfs.OpenReadOnly != 0 {
+		mode = os.O_RDONLY
+	}
+	if flags&sqlite3vfs.OpenCreate != 0 {
+		mode |= os.O_CREATE
+	}
+	if flags&sqlite3vfs.OpenExclusive != 0 {
+		mode |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, mode, 0o644)
+	if err != nil {
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+	return dirFile{f}, flags, nil
+}
+
+func (d DirVFS) Delete(name string, syncDir bool) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if !syncDir {
+		return nil
+	}
+	dir, err := os.Open(string(d))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (d DirVFS) Access(name string, flag sqlite3vfs.AccessFlag) (bool, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return false, nil
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d DirVFS) FullPathname(name string) string {
+	return name
+}
+
+// dirFile is a file of a DirVFS.
+type dirFile struct {
+	*os.File
+}
+
+func (f dirFile) Truncate(size int64) error {
+	return f.File.Truncate(size)
+}
+
+func (f dirFile) Sync(flag sqlite3vfs.SyncType) error {
+	return f.File.Sync()
+}
+
+func (f dirFile) FileSize() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (f dirFile) Lock(elock sqlite3vfs.LockType) error {
+	return nil
+}
+
+func (f dirFile) Unlock(elock sqlite3vfs.LockType) error {
+	return nil
+}
+
+func (f dirFile) CheckReservedLock() (bool, error) {
+	return false, nil
+}
+
+func (f dirFile) SectorSize() int64 {
+	return 0
+}
+
+func (f dirFile) DeviceCharacteristics() sqlite3vfs.DeviceCharacteristic {
+	return 0
+}
@@ -0,0 +1,86 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestBackingVFS(t *testing.T) {
+	dir := t.TempDir()
+	cv := memvfs.New(memvfs.WithBackingVFS(memvfs.DirVFS(dir)))
+	if err := cv.Register("memvfs-cache"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:test.db?vfs=memvfs-cache")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM demo WHERE id > 40`); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		t.Fatalf("Vacuum error: %v", err)
+	}
+
+	// Committed transactions are on disk while the database is open.
+	path := filepath.Join(dir, "test.db")
+	disk, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open disk DB: %v", err)
+	}
+	var n int
+	if err := disk.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+		t.Fatalf("Count on disk error: %v", err)
+	}
+	disk.Close()
+	if n != 40 {
+		t.Errorf("Expected 40 rows on disk, got %d", n)
+	}
+	db.Close()
+
+	if _, err := os.Stat(path + "-journal"); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be deleted from disk, got %v", err)
+	}
+	if ok, _ := cv.Access("test.db", 0); !ok {
+		t.Errorf("Expected the closed database to be found on disk")
+	}
+
+	// Reopening reads through to disk, including changes made there.
+	disk, err = sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open disk DB: %v", err)
+	}
+	if _, err := disk.Exec(`INSERT INTO demo(data) VALUES ('disk')`); err != nil {
+		t.Fatalf("Insert on disk error: %v", err)
+	}
+	disk.Close()
+
+	db, err = sql.Open("sqlite3", "file:test.db?vfs=memvfs-cache")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if err := db.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+		t.Fatalf("Count error: %v", err)
+	}
+	if n != 41 {
+		t.Errorf("Expected 41 rows read through, got %d", n)
+	}
+	var check string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		t.Errorf("Integrity check failed: %q, %v", check, err)
+	}
+}
@@ -0,0 +1,40 @@
+package memvfs
+
+import (
+	"fmt"
+	"time"
+)
+
+// A file is churning once it has been opened at least churnMinOpens times,
+// at churnMinRate opens per second or more, with fewer than
+// churnMaxReadsPerOpen reads per open: each connection is opened for a
+// query or two and closed again, paying for SQLite's schema parsing and
+// a cold page cache every time.
+const (
+	churnMinOpens        = 100
+	churnMinRate         = 1.0
+	churnMaxReadsPerOpen = 50
+)
+
+// opened counts an Open of e. v.mu must be held.
+func (e *entry) opened() {
+	if e.opens == 0 {
+		e.firstOpen = time.Now()
+	}
+	e.opens++
+}
+
+// churn describes how e is churning, with a remedy, or returns "" if it is
+// not. v.mu must be held.
+func (e *entry) churn() string {
+	if e.opens < churnMinOpens {
+		return ""
+	}
+	rate := float64(e.opens) / max(time.Since(e.firstOpen).Seconds(), 1)
+	readsPerOpen := float64(e.io.load().Reads) / float64(e.opens)
+	if rate < churnMinRate || readsPerOpen >= churnMaxReadsPerOpen {
+		return ""
+	}
+	return fmt.Sprintf("%d opens at %.1f per second with %.1f reads each: connections are opened per query; "+
+		"reuse a long-lived *sql.DB and raise SetMaxIdleConns so they stay open", e.opens, rate, readsPerOpen)
+}
@@ -0,0 +1,136 @@
+// Command memvfs-bench drives a mix of reads, writes and transactions
+// against memvfs-backed databases and reports throughput, latencies, memory
+// growth and GC impact. Run it long with -duration for a soak test, compare
+// -json reports across releases, and compare journal modes on slow storage
+// with -journal and -profile.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hleng1/memvfs"
+	"github.com/hleng1/memvfs/loadgen"
+)
+
+func main() {
+	var cfg loadgen.Config
+	flag.IntVar(&cfg.Databases, "dbs", 1, "number of databases")
+	flag.IntVar(&cfg.Keys, "keys", 10000, "rows per database")
+	flag.IntVar(&cfg.RowSize, "row-size", 100, "bytes per row")
+	flag.IntVar(&cfg.Workers, "workers", 0, "concurrent workers (default GOMAXPROCS)")
+	flag.IntVar(&cfg.Mix.Reads, "reads", 8, "weight of single row reads")
+	flag.IntVar(&cfg.Mix.Writes, "writes", 2, "weight of single row writes")
+	flag.IntVar(&cfg.Mix.Transactions, "txs", 0, "weight of multi-row transactions")
+	flag.IntVar(&cfg.TxRows, "tx-rows", 10, "rows written per transaction")
+	flag.DurationVar(&cfg.Duration, "duration", 10*time.Second, "how long to run")
+	flag.Int64Var(&cfg.Ops, "ops", 0, "stop after this many operations instead")
+	flag.StringVar(&cfg.JournalMode, "journal", "DELETE", "SQLite journal mode")
+	flag.DurationVar(&cfg.SampleEvery, "sample", time.Second, "progress sampling interval")
+	budget := flag.Int64("memory-budget", 0, "memory budget of the store in bytes (0 for none)")
+	profile := flag.String("profile", "", "simulate a storage device: laptop-ssd, network-fs or flaky-sd-card")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	progress := flag.Bool("progress", false, "print samples to stderr as the run goes")
+	flag.Parse()
+
+	if cfg.Ops > 0 && !isFlagSet("duration") {
+		cfg.Duration = 0
+	}
+	if *budget > 0 {
+		cfg.Options = append(cfg.Options, memvfs.WithMemoryBudget(*budget))
+	}
+	if *profile != "" {
+		p, err := memvfs.StorageProfileByName(*profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "memvfs-bench:", err)
+			os.Exit(2)
+		}
+		cfg.Options = append(cfg.Options, memvfs.WithStorageProfile(p))
+	}
+	if *progress {
+		cfg.Progress = func(s loadgen.Sample) {
+			fmt.Fprintf(os.Stderr, "%8s  %10d ops  heap %s  store %s\n",
+				s.Elapsed.Round(time.Second), s.Ops, bytes(s.HeapAlloc), bytes(uint64(s.StoreBytes)))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := loadgen.Run(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "memvfs-bench:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		out := struct {
+			loadgen.Report
+			Err string `json:",omitempty"`
+		}{Report: report}
+		if report.Err != nil {
+			out.Err = report.Err.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+	} else {
+		printReport(report)
+	}
+	if report.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+func printReport(r loadgen.Report) {
+	fmt.Printf("%d ops in %s: %.0f ops/s, %d errors\n", r.Ops, r.Elapsed.Round(time.Millisecond), r.Throughput, r.Errors)
+	if r.Err != nil {
+		fmt.Printf("first error: %v\n", r.Err)
+	}
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\tmean\tp50\tp95\tp99\tmax\t")
+	kinds := make([]loadgen.OpKind, 0, len(r.Latencies))
+	for kind := range r.Latencies {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	for _, kind := range kinds {
+		l := r.Latencies[kind]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n", kind, l.Count, l.Mean, l.P50, l.P95, l.P99, l.Max)
+	}
+	tw.Flush()
+	fmt.Println()
+
+	fmt.Printf("heap: %s at start, %s at end, %s peak\n", bytes(r.HeapStart), bytes(r.HeapEnd), bytes(r.HeapPeak))
+	fmt.Printf("gc: %d cycles, %s paused\n", r.GCCycles, r.GCPause)
+	fmt.Printf("store: %d files, %s, %d reads, %d writes\n", r.Stats.Files, bytes(uint64(r.Stats.Bytes)), r.Stats.Reads, r.Stats.Writes)
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+func bytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,102 @@
+package memvfs
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// StaleRead describes a read that missed a committed write; see
+// WithReadYourWritesCheck.
+type StaleRead struct {
+	Name string
+
+	// Writer is the handle that committed the write and Reader the handle
+	// whose later read missed it.
+	Writer HandleID
+	Reader HandleID
+
+	// Offset and Length are the part of the committed write that the read
+	// returned differently.
+	Offset int64
+	Length int64
+}
+
+func (s StaleRead) Error() string {
+	return fmt.Sprintf("memvfs: %s: read by handle %d missed %d bytes at %d committed by handle %d",
+		s.Name, s.Reader, s.Length, s.Offset, s.Writer)
+}
+
+// WithReadYourWritesCheck checks that once a transaction commits on one
+// connection, every read transaction started afterwards, on any connection
+// to the same database, reads back what it wrote until the database
+// changes again. A read that misses a committed write is passed to report
+// and fails with SQLITE_IOERR, so that regressions in the lock manager or
+// write buffering surface where they happen rather than as corrupt query
+// results. report runs with the file locked and must not call methods of
+// v.
+//
+// The check keeps a copy of the pages of each database's last transaction
+// and compares every read against them, so it is meant for tests.
+func WithReadYourWritesCheck(report func(StaleRead)) Option {
+	return func(v *MemVFS) {
+		v.checkReads = report
+	}
+}
+
+// lastCommit is the pages of the last transaction committed to a main
+// database; see WithReadYourWritesCheck.
+type lastCommit struct {
+	seq     uint64
+	version uint64
+	writer  HandleID
+	pages   []pendingWrite
+}
+
+// recordCommit keeps a copy of the pages f's transaction wrote to e for
+// WithReadYourWritesCheck, before e.commitWrites ends it. e must be locked.
+func (v *MemVFS) recordCommit(f *MemFile, e *entry) {
+	if v.checkReads == nil || len(e.txWritten) == 0 || e.reader() != nil {
+		return
+	}
+	e.commitSeq++
+	c := &lastCommit{seq: e.commitSeq, version: e.version, writer: f.id}
+	for _, sp := range e.txWritten.clip(int64(len(e.data))) {
+		c.pages = append(c.pages, pendingWrite{off: sp.start, data: bytes.Clone(e.data[sp.start:sp.end])})
+	}
+	e.lastCommit = c
+}
+
+// checkRead reports a read of n bytes at off through f that misses the
+// last commit to e. It only checks reads of read transactions that began
+// after the commit, while e is as the commit left it. e must be locked.
+func (v *MemVFS) checkRead(f *MemFile, e *entry, off int64, n int) error {
+	c := e.lastCommit
+	if v.checkReads == nil || c == nil || c.version != e.version ||
+		f.readSince < c.seq || e.reader() != nil {
+		return nil
+	}
+	end := off + int64(n)
+	for _, w := range c.pages {
+		start, stop := max(off, w.off), min(end, w.off+int64(len(w.data)))
+		if start >= stop {
+			continue
+		}
+		want := w.data[start-w.off : stop-w.off]
+		var got []byte
+		if start < int64(len(e.data)) {
+			got = e.data[start:min(stop, int64(len(e.data)))]
+		}
+		if !bytes.Equal(got, want) {
+			stale := StaleRead{
+				Name:   v.LogName(f.fileName),
+				Writer: c.writer,
+				Reader: f.id,
+				Offset: start,
+				Length: stop - start,
+			}
+			v.checkReads(stale)
+			return stale
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestReadYourWritesCheck(t *testing.T) {
+	var mu sync.Mutex
+	var stale []memvfs.StaleRead
+	cv := memvfs.New(memvfs.WithReadYourWritesCheck(func(s memvfs.StaleRead) {
+		mu.Lock()
+		defer mu.Unlock()
+		stale = append(stale, s)
+	}))
+	if err := cv.Register("memvfs-coherence"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	// Connections without a shared cache read the file itself.
+	dsn := "file:test-coherence.db?vfs=memvfs-coherence&_busy_timeout=10000&_txlock=immediate"
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		db.SetMaxOpenConns(1)
+		return db
+	}
+	writer, reader := open(), open()
+	defer writer.Close()
+	defer reader.Close()
+
+	if _, err := writer.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 1; i <= 50; i++ {
+		if _, err := writer.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+		var n int
+		if err := reader.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil {
+			t.Fatalf("Count error: %v", err)
+		}
+		if n != i {
+			t.Fatalf("Reader saw %d rows after %d commits", n, i)
+		}
+	}
+
+	// Writers and readers interleaving across connections.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db := open()
+			defer db.Close()
+			for range 20 {
+				if _, err := db.Exec(`UPDATE demo SET data = ? WHERE id = 1`, randSeq(500)); err != nil {
+					t.Errorf("Update error: %v", err)
+					return
+				}
+				var data string
+				if err := db.QueryRow(`SELECT data FROM demo WHERE id = 1`).Scan(&data); err != nil {
+					t.Errorf("Query error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(stale) > 0 {
+		t.Errorf("Expected no stale reads, got %v", stale)
+	}
+}
@@ -0,0 +1,79 @@
+package memvfs
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compressable reports whether e may be compressed: it is idle, held in
+// memory as is and not pinned. v.mu must be held.
+func (e *entry) compressable(olderThan time.Duration, now time.Time) bool {
+	return e.class() == StorageHot && len(e.data) >= compressMinSize &&
+		e.compressTried != e.version+1 && e.tierable(olderThan, now)
+}
+
+// CompressIdle compresses the files IdleFiles(olderThan) would list, cutting
+// the memory held by stores with many rarely touched databases, and
+// returns what they are now. A compressed file is decompressed
+// transparently the next time it is opened or read by any method of the
+// store. Files served by a backend, pinned with Pin or smaller than 16 KiB
+// are left alone, as are files that compression would not shrink.
+//
+// Compression runs without holding up the store; a file that is opened or
+// modified meanwhile is skipped.
+func (v *MemVFS) CompressIdle(olderThan time.Duration) []FileInfo {
+	v.mu.Lock()
+	now := time.Now()
+	candidates := make(map[string]*entry)
+	for name, e := range v.files {
+		if e.compressable(olderThan, now) {
+			candidates[name] = e
+		}
+	}
+	v.mu.Unlock()
+
+	var infos []FileInfo
+	for name, e := range candidates {
+		info, ok, _ := v.demote(name, e, StorageWarm, func(e *entry) bool {
+			return e.compressable(olderThan, now)
+		})
+		if ok {
+			infos = append(infos, info)
+		}
+	}
+	slices.SortFunc(infos, func(a, b FileInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// AutoCompress runs CompressIdle(olderThan) every interval until stop is
+// called. interval must be positive.
+func (v *MemVFS) AutoCompress(olderThan, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, errInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if infos := v.CompressIdle(olderThan); len(infos) > 0 {
+					v.log(slog.LevelInfo, "idle files compressed", "files", len(infos))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}, nil
+}
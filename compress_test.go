@@ -0,0 +1,123 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestCompressIdle(t *testing.T) {
+	ctx := context.Background()
+	tv := memvfs.New()
+	if err := tv.Register("memvfs-compress-src"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	src, err := tv.OpenDB("template.db", memvfs.ProfileNone)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := src.Exec(`INSERT INTO demo(data) VALUES (?)`, strings.Repeat("cold ", 40)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	cv := memvfs.New()
+	if err := cv.Register("memvfs-compress"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	// Pulled copies outlive their connections, like the rarely touched
+	// databases compression is for.
+	for _, name := range []string{"cold.db", "pinned.db"} {
+		if _, err := tv.CopyTo(ctx, "template.db", cv, name); err != nil {
+			t.Fatalf("CopyTo error: %v", err)
+		}
+	}
+	noise := make([]byte, 64<<10)
+	rand.Read(noise)
+	if err := cv.Batch(func(tx *memvfs.AdminTx) error { return tx.Put("noise.db", noise) }); err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	unpin, err := cv.Pin("pinned.db", []memvfs.PageRange{{First: 1, Last: 1}}, time.Minute)
+	if err != nil {
+		t.Fatalf("Pin error: %v", err)
+	}
+	defer unpin()
+	want, _ := cv.GetFile("cold.db")
+	want = bytes.Clone(want)
+	before := cv.Stats()
+
+	if files := cv.CompressIdle(time.Hour); len(files) != 0 {
+		t.Errorf("Expected nothing idle for an hour to be compressed, got %+v", files)
+	}
+	time.Sleep(20 * time.Millisecond)
+	files := cv.CompressIdle(10 * time.Millisecond)
+	if len(files) != 1 || files[0].Name != "cold.db" || files[0].StorageClass != memvfs.StorageWarm {
+		t.Fatalf("Expected only cold.db to be compressed, got %+v", files)
+	}
+	if files[0].Size != int64(len(want)) {
+		t.Errorf("Expected a compressed file to keep its size %d, got %d", len(want), files[0].Size)
+	}
+	after := cv.Stats()
+	if after.Compressed != 1 || after.Bytes >= before.Bytes-int64(len(want))/2 {
+		t.Errorf("Expected compression to halve the file's memory, from %d to %d bytes", before.Bytes, after.Bytes)
+	}
+
+	db, err := cv.OpenDB("cold.db", memvfs.ProfileNone)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 500 {
+		t.Errorf("Expected 500 rows after decompression, got %d, %v", n, err)
+	}
+	if info, _ := cv.Stat("cold.db"); info.StorageClass == memvfs.StorageWarm {
+		t.Errorf("Expected an open file to be decompressed")
+	}
+	db.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	if files := cv.CompressIdle(10 * time.Millisecond); len(files) != 1 {
+		t.Fatalf("Expected cold.db to be compressed again, got %+v", files)
+	}
+	got, err := cv.GetFile("cold.db")
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Expected GetFile to return the decompressed contents, got %d bytes, %v", len(got), err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := cv.AutoCompress(time.Millisecond, 0); err == nil {
+		t.Errorf("Expected AutoCompress to reject a zero interval")
+	}
+	stop, err := cv.AutoCompress(10*time.Millisecond, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("AutoCompress error: %v", err)
+	}
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for info, _ := cv.Stat("cold.db"); info.StorageClass != memvfs.StorageWarm; info, _ = cv.Stat("cold.db") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected AutoCompress to compress cold.db")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	stop()
+
+	var reopened *sql.DB
+	reopened, _ = cv.OpenDB("cold.db", memvfs.ProfileNone)
+	defer reopened.Close()
+	if err := reopened.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 500 {
+		t.Errorf("Expected 500 rows after AutoCompress, got %d, %v", n, err)
+	}
+}
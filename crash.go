@@ -0,0 +1,94 @@
+package memvfs
+
+import (
+	"bytes"
+	"errors"
+)
+
+// undoBlockSize is the granularity at which crash simulation saves synced
+// contents.
+const undoBlockSize = 4096
+
+// WithCrashSimulation keeps the last synced contents of every block SQLite
+// overwrites, so that SimulateCrash can drop the writes a power loss would
+// have lost. It costs a copy of each block on its first write after a sync.
+func WithCrashSimulation() Option {
+	return func(v *MemVFS) {
+		v.crashSim = true
+	}
+}
+
+// undoLog holds what a file looked like when it was last synced.
+type undoLog struct {
+	size   int64
+	blocks map[int64][]byte
+}
+
+// saveUndo records the synced contents of [off, end) of e before they are
+// overwritten or truncated away. e must be locked.
+func (v *MemVFS) saveUndo(e *entry, off, end int64) {
+	if !v.crashSim || e.src != nil || e.disk != nil {
+		return
+	}
+	if e.undo == nil {
+		e.undo = &undoLog{size: int64(len(e.data)), blocks: make(map[int64][]byte)}
+	}
+	u := e.undo
+	for i := off / undoBlockSize; i*undoBlockSize < min(end, u.size); i++ {
+		if _, ok := u.blocks[i]; ok {
+			continue
+		}
+		start := i * undoBlockSize
+		u.blocks[i] = bytes.Clone(e.data[start:min(start+undoBlockSize, u.size)])
+	}
+}
+
+// rollbackUnsynced restores e to its contents at the last sync. v.mu must be
+// held.
+func (e *entry) rollbackUnsynced() {
+	u := e.undo
+	if u == nil {
+		return
+	}
+	data := e.data
+	if int64(cap(data)) < u.size {
+		data = make([]byte, u.size)
+		copy(data, e.data)
+	}
+	data = data[:u.size]
+	for i, block := range u.blocks {
+		copy(data[i*undoBlockSize:], block)
+	}
+	e.data = data
+	e.undo = nil
+	e.unsynced = nil
+	e.modified()
+}
+
+// SimulateCrash simulates the process dying or the machine losing power
+// while connections are using the named database: the handles on it and
+// on its journal and WAL are revoked as by Drain, and every write SQLite has
+// not synced yet is dropped. Connections opened afterwards find the files
+// as SQLite would after a reboot, so that its crash recovery, rolling back a
+// hot journal or replaying the WAL, can be tested. The store must have been
+// created WithCrashSimulation.
+func (v *MemVFS) SimulateCrash(name string) error {
+	if !v.crashSim {
+		return errors.New("memvfs: crash simulation not enabled")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, other := range v.files {
+		if other == e || other.owner == e {
+			other.revoke()
+			other.rollbackUnsynced()
+			v.account(other)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestSimulateCrashDropsUnsynced(t *testing.T) {
+	cv := memvfs.New(memvfs.WithCrashSimulation())
+	name := "test-crash-raw.db"
+	f, _, err := cv.Open(name, sqlite3vfs.OpenMainJournal|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	f.WriteAt([]byte("synced"), 0)
+	if err := f.Sync(sqlite3vfs.SyncNormal); err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	f.WriteAt([]byte("unsync"), 0)
+	f.WriteAt(make([]byte, 10000), 6)
+
+	if err := cv.SimulateCrash(name); err != nil {
+		t.Fatalf("SimulateCrash error: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("after"), 0); err == nil {
+		t.Errorf("Expected the crashed handle to be revoked")
+	}
+	f.Close()
+	if data, _ := cv.GetFile(name); string(data) != "synced" {
+		t.Errorf("Expected only synced contents to survive, got %d bytes %.6q", len(data), data)
+	}
+
+	if err := memvfs.New().SimulateCrash(name); err == nil {
+		t.Errorf("Expected an error without WithCrashSimulation")
+	}
+}
+
+func TestSimulateCrashRecovery(t *testing.T) {
+	cv := memvfs.New(memvfs.WithCrashSimulation())
+	if err := cv.Register("memvfs-crash"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	dsn := "file:test-crash.db?vfs=memvfs-crash&cache=shared"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	// A small cache makes SQLite write pages to the database in the middle
+	// of the transaction, after syncing the journal.
+	if _, err := db.Exec(`PRAGMA cache_size = 1`); err != nil {
+		t.Fatalf("Pragma error: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := tx.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	if err := cv.SimulateCrash("test-crash.db"); err != nil {
+		t.Fatalf("SimulateCrash error: %v", err)
+	}
+	tx.Rollback()
+	db.Close()
+	if fi, err := cv.Stat("test-crash.db-journal"); err != nil || fi.Size == 0 {
+		t.Fatalf("Expected a hot journal to survive the crash, got %+v (%v)", fi, err)
+	}
+
+	db, err = sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil || count != 10 {
+		t.Errorf("Expected recovery to leave the 10 committed rows, got %d (%v)", count, err)
+	}
+	var check string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		t.Errorf("Expected a consistent database after recovery, got %q (%v)", check, err)
+	}
+}
@@ -0,0 +1,154 @@
+package memvfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+// Dataset is a stored database addressed as an object rather than a raw
+// file name, for code that manages many of them.
+type Dataset struct {
+	v    *MemVFS
+	name string
+}
+
+// Dataset returns the dataset stored under name.
+func (v *MemVFS) Dataset(name string) (*Dataset, error) {
+	if _, err := v.Stat(name); err != nil {
+		return nil, err
+	}
+	return &Dataset{v: v, name: name}, nil
+}
+
+func (d *Dataset) Name() string {
+	return d.name
+}
+
+// Size returns the current size of the dataset in bytes.
+func (d *Dataset) Size() (int64, error) {
+	info, err := d.v.Stat(d.name)
+	return info.Size, err
+}
+
+// OpenReadOnlyDB opens the dataset with a query-only connection pool. The
+// MemVFS must have been registered with Register.
+func (d *Dataset) OpenReadOnlyDB() (*sql.DB, error) {
+	return d.v.openDB(d.name, "", "PRAGMA query_only = ON")
+}
+
+// Checksum returns the hex-encoded SHA-256 of a consistent image of the
+// dataset.
+func (d *Dataset) Checksum() (string, error) {
+	data, err := d.image()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Export writes a consistent image of the dataset to w, redacted if the
+// store was created WithExportRedaction.
+func (d *Dataset) Export(w io.Writer) (int64, error) {
+	data, err := d.image()
+	if err == nil {
+		data, err = d.v.redact(d.name, data)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// HTTPFile returns a consistent image of the dataset as an http.File, for
+// serving with http.ServeContent or an http.FileSystem.
+func (d *Dataset) HTTPFile() (http.File, error) {
+	data, err := d.image()
+	if err != nil {
+		return nil, err
+	}
+	info, err := d.v.Stat(d.name)
+	if err != nil {
+		return nil, err
+	}
+	return &httpFile{
+		Reader: bytes.NewReader(data),
+		info:   datasetInfo{name: d.name, size: int64(len(data)), modTime: info.ModTime},
+	}, nil
+}
+
+// image returns a private copy of the dataset; see MemVFS.image.
+func (d *Dataset) image() ([]byte, error) {
+	return d.v.image(d.name)
+}
+
+// image returns a private copy of the named file taken while it is frozen,
+// so that no write transaction is half applied.
+func (v *MemVFS) image(name string) ([]byte, error) {
+	unfreeze, err := v.Freeze(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unfreeze()
+
+	return v.copyFile(name)
+}
+
+// copyFile returns a private copy of the named file's contents. A file held
+// in memory is copied under its own lock only, as a reader would, so other
+// files are not held up by the copy.
+func (v *MemVFS) copyFile(name string) ([]byte, error) {
+	v.mu.RLock()
+	if e, ok := v.files[name]; ok {
+		e.mu.RLock()
+		if e.reader() == nil && e.compressed == nil {
+			data := bytes.Clone(e.data)
+			v.runlockEntry(e)
+			return data, nil
+		}
+		e.mu.RUnlock()
+	}
+	v.mu.RUnlock()
+
+	// GetFile already reads sourced files into a fresh buffer.
+	return v.GetFile(name)
+}
+
+type httpFile struct {
+	*bytes.Reader
+	info datasetInfo
+}
+
+func (f *httpFile) Close() error {
+	return nil
+}
+
+func (f *httpFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, errors.New("memvfs: dataset is not a directory")
+}
+
+func (f *httpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// datasetInfo is the fs.FileInfo of an exported dataset.
+type datasetInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i datasetInfo) Name() string       { return i.name }
+func (i datasetInfo) Size() int64        { return i.size }
+func (i datasetInfo) Mode() fs.FileMode  { return 0o444 }
+func (i datasetInfo) ModTime() time.Time { return i.modTime }
+func (i datasetInfo) IsDir() bool        { return false }
+func (i datasetInfo) Sys() any           { return nil }
@@ -0,0 +1,84 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDataset(t *testing.T) {
+	dbName := "test-dataset.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES ('dataset')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	if _, err := v.Dataset("test-dataset-missing.db"); err == nil {
+		t.Errorf("Expected error for missing dataset")
+	}
+	ds, err := v.Dataset(dbName)
+	if err != nil {
+		t.Fatalf("Dataset error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := ds.Export(&buf)
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	size, err := ds.Size()
+	if err != nil || n != size {
+		t.Errorf("Exported %d bytes, Size() = %d, %v", n, size, err)
+	}
+
+	sum, err := ds.Checksum()
+	if err != nil {
+		t.Fatalf("Checksum error: %v", err)
+	}
+	want := sha256.Sum256(buf.Bytes())
+	if sum != hex.EncodeToString(want[:]) {
+		t.Errorf("Checksum %s does not match exported bytes", sum)
+	}
+
+	ro, err := ds.OpenReadOnlyDB()
+	if err != nil {
+		t.Fatalf("OpenReadOnlyDB error: %v", err)
+	}
+	defer ro.Close()
+	var data string
+	if err := ro.QueryRow(`SELECT data FROM demo`).Scan(&data); err != nil || data != "dataset" {
+		t.Errorf("Read-only query = %q, %v", data, err)
+	}
+	if _, err := ro.Exec(`INSERT INTO demo(data) VALUES ('rejected')`); err == nil {
+		t.Errorf("Read-only DB should reject writes")
+	}
+
+	f, err := ds.HTTPFile()
+	if err != nil {
+		t.Fatalf("HTTPFile error: %v", err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, httptest.NewRequest("GET", "/"+dbName, nil), info.Name(), info.ModTime(), f)
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || !bytes.Equal(body, buf.Bytes()) {
+		t.Errorf("ServeContent returned %d with %d bytes", rec.Code, len(body))
+	}
+}
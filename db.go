@@ -0,0 +1,231 @@
+package memvfs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/psanford/sqlite3vfs"
+)
+
+// ErrNotRegistered is returned by helpers that build DSNs when the MemVFS
+// has not been registered with Register.
+var ErrNotRegistered = errors.New("memvfs not registered")
+
+// Register registers v with SQLite under vfsName, which can then be used as
+// the ?vfs= parameter of a DSN and is remembered for helpers such as OpenDB.
+func (v *MemVFS) Register(vfsName string, opts ...sqlite3vfs.Option) error {
+	if err := sqlite3vfs.RegisterVFS(vfsName, v, opts...); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.vfsName = vfsName
+	v.log(slog.LevelInfo, "registered", "vfs", vfsName)
+	return nil
+}
+
+// Profile names a vetted set of pragmas applied to every connection opened
+// by OpenDB.
+type Profile string
+
+const (
+	// ProfileNone applies no pragmas and leaves SQLite's defaults.
+	ProfileNone Profile = ""
+
+	// ProfileThroughput keeps the journal out of the VFS and skips syncs,
+	// which memvfs ignores anyway. A crash mid-transaction loses the
+	// database, which it would as an in-memory store regardless.
+	ProfileThroughput Profile = "throughput"
+
+	// ProfileDurability keeps a rollback journal in the VFS and full syncs,
+	// so the file is consistent at every commit for anything persisting or
+	// snapshotting it.
+	ProfileDurability Profile = "durability"
+
+	// ProfileTest uses a small page cache and a rollback journal so that
+	// test suites push their IO through the VFS and exercise it.
+	ProfileTest Profile = "test"
+)
+
+var profilePragmas = map[Profile][]string{
+	ProfileNone: nil,
+	ProfileThroughput: {
+		"PRAGMA journal_mode = MEMORY",
+		"PRAGMA synchronous = OFF",
+		"PRAGMA temp_store = MEMORY",
+		"PRAGMA cache_size = -16384",
+	},
+	ProfileDurability: {
+		"PRAGMA journal_mode = DELETE",
+		"PRAGMA synchronous = FULL",
+		"PRAGMA temp_store = MEMORY",
+		"PRAGMA cache_size = -2000",
+	},
+	ProfileTest: {
+		"PRAGMA journal_mode = DELETE",
+		"PRAGMA synchronous = OFF",
+		"PRAGMA temp_store = MEMORY",
+		"PRAGMA cache_size = -256",
+	},
+}
+
+// OpenDB opens the named database on v with a shared cache, applying the
+// pragmas of profile to each new connection. v must have been registered
+// with Register.
+func (v *MemVFS) OpenDB(name string, profile Profile) (*sql.DB, error) {
+	pragmas, ok := profilePragmas[profile]
+	if !ok {
+		return nil, fmt.Errorf("memvfs: unknown profile %q", profile)
+	}
+	return v.openDB(name, "", pragmas...)
+}
+
+// openDB opens name with extra DSN params (each starting with "&") and runs
+// pragmas on every new connection.
+func (v *MemVFS) openDB(name string, params string, pragmas ...string) (*sql.DB, error) {
+	v.mu.Lock()
+	vfsName := v.vfsName
+	v.mu.Unlock()
+	if vfsName == "" {
+		return nil, ErrNotRegistered
+	}
+
+	drv := &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			for _, pragma := range pragmas {
+				if _, err := conn.Exec(pragma, nil); err != nil {
+					return fmt.Errorf("%s: %w", pragma, err)
+				}
+			}
+			return nil
+		},
+	}
+
+	return sql.OpenDB(&connector{
+		driver: drv,
+		dsn:    fmt.Sprintf("file:%s?vfs=%s&cache=shared%s", name, vfsName, params),
+	}), nil
+}
+
+// scratchDB opens a single connection on a consistent copy of name, held in
+// a scratch file named after purpose so that live connections only wait for
+// the copy. done closes the connection and deletes the scratch file.
+func (v *MemVFS) scratchDB(name, purpose string) (db *sql.DB, done func(), err error) {
+	data, err := v.image(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.openScratch(name+"-"+purpose, data)
+}
+
+// openScratch opens a single connection on a copy of data, stored in a
+// scratch file named after prefix. done closes the connection and deletes
+// the scratch file.
+func (v *MemVFS) openScratch(prefix string, data []byte) (db *sql.DB, done func(), err error) {
+	_, db, done, err = v.openScratchFile(prefix, data)
+	return db, done, err
+}
+
+// openScratchFile is openScratch, also returning the name of the scratch
+// file.
+func (v *MemVFS) openScratchFile(prefix string, data []byte) (scratch string, db *sql.DB, done func(), err error) {
+	v.mu.Lock()
+	v.tempSeq++
+	scratch = fmt.Sprintf("%s-%d", prefix, v.tempSeq)
+	v.mu.Unlock()
+	err = v.Batch(func(tx *AdminTx) error {
+		return tx.put(scratch, data, false)
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+	drop := func() {
+		v.Batch(func(tx *AdminTx) error {
+			if _, ok := tx.get(scratch); ok {
+				return tx.Delete(scratch)
+			}
+			return nil
+		})
+	}
+
+	db, err = v.openDB(scratch, "")
+	if err != nil {
+		drop()
+		return "", nil, nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return scratch, db, func() {
+		db.Close()
+		drop()
+	}, nil
+}
+
+// Pool is a pair of handles on one database split by access, see OpenPool.
+type Pool struct {
+	Writer *sql.DB
+	Reader *sql.DB
+}
+
+// OpenPool opens name with separate writer and reader handles configured to
+// avoid the SQLITE_LOCKED errors readers otherwise hit on a shared cache
+// while a write is in flight.
+//
+// Writers begin transactions with BEGIN IMMEDIATE. Readers are query-only
+// and read uncommitted, so they never wait on table locks held by the
+// writer; the price is that they can observe rows of an in-flight
+// transaction. memvfs does not support WAL, which would give readers
+// snapshot isolation instead.
+//
+// maxWriters should normally be 1: shared-cache writers exclude each other
+// with SQLITE_LOCKED, which busy_timeout does not retry. Values <= 0 default
+// to 1 writer and an unlimited number of readers.
+func (v *MemVFS) OpenPool(name string, maxWriters, maxReaders int) (*Pool, error) {
+	if maxWriters <= 0 {
+		maxWriters = 1
+	}
+
+	writer, err := v.openDB(name, "&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	writer.SetMaxOpenConns(maxWriters)
+
+	reader, err := v.openDB(name, "",
+		"PRAGMA query_only = ON",
+		"PRAGMA read_uncommitted = ON",
+	)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if maxReaders > 0 {
+		reader.SetMaxOpenConns(maxReaders)
+	}
+
+	return &Pool{Writer: writer, Reader: reader}, nil
+}
+
+// Close closes both handles of the pool.
+func (p *Pool) Close() error {
+	return errors.Join(p.Reader.Close(), p.Writer.Close())
+}
+
+type connector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,114 @@
+package memvfs_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestOpenDBProfiles(t *testing.T) {
+	for _, tc := range []struct {
+		profile     memvfs.Profile
+		journalMode string
+		synchronous int
+	}{
+		{memvfs.ProfileNone, "delete", 1},
+		{memvfs.ProfileThroughput, "memory", 0},
+		{memvfs.ProfileDurability, "delete", 2},
+		{memvfs.ProfileTest, "delete", 0},
+	} {
+		t.Run(string(tc.profile), func(t *testing.T) {
+			db, err := v.OpenDB("test-profile-"+string(tc.profile)+".db", tc.profile)
+			if err != nil {
+				t.Fatalf("OpenDB error: %v", err)
+			}
+			defer db.Close()
+
+			var journalMode string
+			if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+				t.Fatalf("journal_mode query error: %v", err)
+			}
+			if strings.ToLower(journalMode) != tc.journalMode {
+				t.Errorf("Expected journal_mode %v, got %v", tc.journalMode, journalMode)
+			}
+
+			var synchronous int
+			if err := db.QueryRow(`PRAGMA synchronous`).Scan(&synchronous); err != nil {
+				t.Fatalf("synchronous query error: %v", err)
+			}
+			if synchronous != tc.synchronous {
+				t.Errorf("Expected synchronous %v, got %v", tc.synchronous, synchronous)
+			}
+		})
+	}
+
+	if _, err := v.OpenDB("test-profile.db", "bogus"); err == nil {
+		t.Errorf("Expected error for unknown profile")
+	}
+	if _, err := memvfs.New().OpenDB("test-profile.db", memvfs.ProfileNone); err != memvfs.ErrNotRegistered {
+		t.Errorf("Expected ErrNotRegistered, got %v", err)
+	}
+}
+
+func TestOpenPool(t *testing.T) {
+	const (
+		readerCount = 4
+		iterations  = 2000
+	)
+
+	pool, err := v.OpenPool("test-pool.db", 1, readerCount)
+	if err != nil {
+		t.Fatalf("OpenPool error: %v", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Writer.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(readerCount)
+	for i := 0; i < readerCount; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var count int
+				if err := pool.Reader.QueryRow(`SELECT COUNT(*) FROM test`).Scan(&count); err != nil {
+					t.Errorf("Query error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < iterations; i++ {
+		_, err := pool.Writer.Exec(`INSERT INTO test(value) VALUES(?)`, fmt.Sprintf("iteration %d", i))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if _, err := pool.Reader.Exec(`INSERT INTO test(value) VALUES('reader')`); err == nil {
+		t.Errorf("Reader should not be able to write")
+	}
+
+	var total int
+	if err := pool.Reader.QueryRow(`SELECT COUNT(*) FROM test`).Scan(&total); err != nil {
+		t.Fatalf("Final count query error: %v", err)
+	}
+	if total != iterations {
+		t.Fatalf("Expected %d rows, got %d", iterations, total)
+	}
+}
@@ -0,0 +1,62 @@
+package memvfs
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// WithIODeadline bounds how long a single read from a backend, such as a
+// mounted backup or a file spilled to disk, or a simulated device operation
+// may take. An operation exceeding d fails with SQLITE_IOERR and marks its
+// file degraded in FileInfo, so that a stalled backend cannot hang a
+// connection. In-memory IO never blocks and is not affected.
+func WithIODeadline(d time.Duration) Option {
+	return func(v *MemVFS) {
+		v.ioDeadline = d
+	}
+}
+
+// readSource reads from a backend within the IO deadline. On timeout the
+// read is abandoned: it completes into a private buffer that is then
+// dropped, so p is never written after readSource returns.
+func (v *MemVFS) readSource(f *MemFile, src io.ReaderAt, p []byte, off int64) (int, error) {
+	if v.ioDeadline <= 0 {
+		return src.ReadAt(p, off)
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	buf := make([]byte, len(p))
+	done := make(chan result, 1)
+	go func() {
+		n, err := src.ReadAt(buf, off)
+		done <- result{n, err}
+	}()
+
+	timer := time.NewTimer(v.ioDeadline)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		copy(p, buf[:r.n])
+		return r.n, r.err
+	case <-timer.C:
+		v.degrade(f)
+		return 0, sqlite3vfs.IOError
+	}
+}
+
+// degrade marks f's file degraded. v.mu must not be held.
+func (v *MemVFS) degrade(f *MemFile) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if e, ok := v.files[f.fileName]; ok {
+		e.degraded = true
+	}
+	v.logSampled(slog.LevelWarn, "io deadline exceeded", "file", v.LogName(f.fileName), "deadline", v.ioDeadline)
+}
@@ -0,0 +1,58 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+// stalledStore serves backups whose reads block until release is closed.
+type stalledStore struct {
+	release chan struct{}
+}
+
+func (s stalledStore) OpenBackup(id string) (io.ReaderAt, int64, error) {
+	return s, 1 << 20, nil
+}
+
+func (s stalledStore) ReadAt(p []byte, off int64) (int, error) {
+	<-s.release
+	return 0, io.EOF
+}
+
+func TestIODeadline(t *testing.T) {
+	dv := memvfs.New(memvfs.WithIODeadline(50 * time.Millisecond))
+	if err := dv.Register("memvfs-deadline"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	store := stalledStore{release: make(chan struct{})}
+	defer close(store.release)
+
+	name := "test-deadline.db"
+	if err := dv.MountBackup(store, "stalled", name); err != nil {
+		t.Fatalf("MountBackup error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-deadline")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	if _, err := db.Exec(`SELECT * FROM sqlite_master`); err == nil {
+		t.Errorf("Expected a stalled read to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stalled read took %v", elapsed)
+	}
+	info, err := dv.Stat(name)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if !info.Degraded {
+		t.Errorf("Expected %s to be degraded", name)
+	}
+}
@@ -0,0 +1,150 @@
+package memvfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"strings"
+)
+
+// DigestMismatchError is returned by MatchDigest when a database does not
+// match its golden digest.
+type DigestMismatchError struct {
+	Name      string
+	Got, Want string
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("memvfs: logical digest of %s is %s, want %s", e.Name, e.Got, e.Want)
+}
+
+// LogicalDigest returns the hex-encoded SHA-256 of the schema and rows of
+// the database name, taken from a consistent copy. Unlike a checksum of the
+// file, it does not depend on page layout, free pages or rowids that are
+// not part of a table, so it survives VACUUM and inserting the same rows in
+// another order, and suits asserting database state in tests. Tables are
+// digested in name order and their rows sorted by every column. v must have
+// been registered with Register.
+func (v *MemVFS) LogicalDigest(ctx context.Context, name string) (string, error) {
+	db, done, err := v.scratchDB(name, "digest")
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	rows, err := db.QueryContext(ctx, `SELECT type, name, tbl_name, coalesce(sql, '')
+		FROM sqlite_schema WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name`)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	var tables []string
+	for rows.Next() {
+		var typ, name, tblName, sql string
+		if err := rows.Scan(&typ, &name, &tblName, &sql); err != nil {
+			rows.Close()
+			return "", err
+		}
+		digestValues(sum, typ, name, tblName, sql)
+		// Virtual tables keep their rows in shadow tables, digested on
+		// their own.
+		if typ == "table" && !strings.HasPrefix(strings.ToUpper(sql), "CREATE VIRTUAL") {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	for _, table := range tables {
+		if err := digestTable(ctx, sum, db, table); err != nil {
+			return "", fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// MatchDigest compares the LogicalDigest of name to the golden value want,
+// returning a *DigestMismatchError, which carries the actual digest, if they
+// differ.
+func (v *MemVFS) MatchDigest(ctx context.Context, name, want string) error {
+	got, err := v.LogicalDigest(ctx, name)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return &DigestMismatchError{Name: v.LogName(name), Got: got, Want: want}
+	}
+	return nil
+}
+
+// digestTable adds the rows of table, sorted by every column, to sum.
+func digestTable(ctx context.Context, sum hash.Hash, db *sql.DB, table string) error {
+	quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+	var columns int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM pragma_table_info(?)`, table).Scan(&columns)
+	if err != nil {
+		return err
+	}
+	order := make([]string, columns)
+	for i := range order {
+		order[i] = fmt.Sprint(i + 1)
+	}
+	query := "SELECT * FROM " + quoted
+	if columns > 0 {
+		query += " ORDER BY " + strings.Join(order, ", ")
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	values := make([]any, columns)
+	ptrs := make([]any, columns)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	digestValues(sum, table)
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		digestValues(sum, values...)
+	}
+	return rows.Err()
+}
+
+// digestValues adds values to sum, each tagged with its type and length so
+// that no two sequences of values hash alike.
+func digestValues(sum hash.Hash, values ...any) {
+	var buf []byte
+	for _, value := range values {
+		var tag byte
+		var data []byte
+		switch value := value.(type) {
+		case nil:
+			tag = 'n'
+		case int64:
+			tag, data = 'i', binary.BigEndian.AppendUint64(nil, uint64(value))
+		case float64:
+			tag, data = 'f', binary.BigEndian.AppendUint64(nil, math.Float64bits(value))
+		case string:
+			tag, data = 's', []byte(value)
+		case []byte:
+			tag, data = 'b', value
+		default:
+			tag, data = 'v', fmt.Append(nil, value)
+		}
+		buf = append(buf, tag)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(data)))
+		buf = append(buf, data...)
+	}
+	sum.Write(buf)
+}
@@ -0,0 +1,83 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestLogicalDigest(t *testing.T) {
+	ctx := context.Background()
+	rows := make([]string, 100)
+	for i := range rows {
+		rows[i] = randSeq(200)
+	}
+	fill := func(name string, reverse bool) *sql.DB {
+		db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name))
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT, n REAL, b BLOB)`)
+		if err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+		for i := range rows {
+			if reverse {
+				i = len(rows) - 1 - i
+			}
+			_, err := db.Exec(`INSERT INTO demo VALUES (?, ?, ?, ?)`, i, rows[i], float64(i)/3, []byte{byte(i)})
+			if err != nil {
+				t.Fatalf("Insert error: %v", err)
+			}
+		}
+		return db
+	}
+	a := fill("test-digest-a.db", false)
+	defer a.Close()
+	b := fill("test-digest-b.db", true)
+	defer b.Close()
+
+	golden, err := v.LogicalDigest(ctx, "test-digest-a.db")
+	if err != nil {
+		t.Fatalf("LogicalDigest error: %v", err)
+	}
+	if err := v.MatchDigest(ctx, "test-digest-b.db", golden); err != nil {
+		t.Errorf("Expected the same rows inserted in another order to match: %v", err)
+	}
+
+	if _, err := b.Exec(`DELETE FROM demo WHERE id < 50; VACUUM`); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	for i := range 50 {
+		_, err := b.Exec(`INSERT INTO demo VALUES (?, ?, ?, ?)`, i, rows[i], float64(i)/3, []byte{byte(i)})
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	if err := v.MatchDigest(ctx, "test-digest-b.db", golden); err != nil {
+		t.Errorf("Expected the digest to survive a VACUUM: %v", err)
+	}
+
+	if _, err := b.Exec(`UPDATE demo SET n = n + 1 WHERE id = 7`); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	err = v.MatchDigest(ctx, "test-digest-b.db", golden)
+	var mismatch *memvfs.DigestMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a DigestMismatchError after an update, got %v", err)
+	}
+	if mismatch.Want != golden || mismatch.Got == golden || len(mismatch.Got) != 64 {
+		t.Errorf("Unexpected mismatch: %v", mismatch)
+	}
+
+	if _, err := a.Exec(`CREATE INDEX demo_data ON demo(data)`); err != nil {
+		t.Fatalf("Create index error: %v", err)
+	}
+	if err := v.MatchDigest(ctx, "test-digest-a.db", golden); err == nil {
+		t.Errorf("Expected a schema change to change the digest")
+	}
+}
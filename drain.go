@@ -0,0 +1,145 @@
+package memvfs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// DrainReport describes what Drain could not shut down cleanly.
+type DrainReport struct {
+	// Revoked lists the files that still had open handles when Drain gave
+	// up waiting. Those handles fail all further IO.
+	Revoked []string
+
+	// Unflushed lists the databases that could not be persisted to the
+	// BackingStore. Their changes are only in memory.
+	Unflushed []string
+}
+
+// Drain prepares the store for shutdown. It stops accepting new Opens, which
+// fail with SQLITE_CANTOPEN, and waits for every open handle to be closed.
+// If ctx is done first, the remaining handles are revoked: their locks are
+// released and their reads and writes fail with SQLITE_IOERR, so any
+// transaction still in flight rolls back.
+//
+// If v was created WithBackingStore, Drain then flushes every modified
+// database to it as Flush does, and lists the ones that failed in the
+// report. The returned error joins ctx.Err(), if the handles were revoked,
+// and the flush failures.
+//
+// A drained store does not accept Opens again.
+func (v *MemVFS) Drain(ctx context.Context) (DrainReport, error) {
+	report, err := v.closeHandles(ctx)
+	if v.store == nil {
+		return report, err
+	}
+	_, flushErr := v.Flush(nil, func(name string, err error) {
+		if err != nil {
+			report.Unflushed = append(report.Unflushed, name)
+		}
+	})
+	return report, errors.Join(err, flushErr)
+}
+
+// closeHandles stops new Opens and waits for the open handles to be closed,
+// revoking the ones left once ctx is done.
+func (v *MemVFS) closeHandles(ctx context.Context) (DrainReport, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.draining = true
+	v.log(slog.LevelInfo, "draining", "handles", v.openHandles())
+	for v.openHandles() > 0 {
+		if v.closed == nil {
+			v.closed = make(chan struct{})
+		}
+		closed := v.closed
+		v.mu.Unlock()
+
+		select {
+		case <-closed:
+			v.mu.Lock()
+		case <-ctx.Done():
+			v.mu.Lock()
+			report := v.revokeAll()
+			v.log(slog.LevelWarn, "drain timed out, handles revoked", "files", report.Revoked)
+			return report, ctx.Err()
+		}
+	}
+	return DrainReport{}, nil
+}
+
+// openHandles returns the number of open handles. v.mu must be held.
+func (v *MemVFS) openHandles() int {
+	n := 0
+	for _, e := range v.files {
+		n += len(e.handles)
+	}
+	return n
+}
+
+// revokeAll revokes every open handle. v.mu must be held.
+func (v *MemVFS) revokeAll() DrainReport {
+	var report DrainReport
+	for name, e := range v.files {
+		if len(e.handles) == 0 {
+			continue
+		}
+		e.revoke()
+		report.Revoked = append(report.Revoked, name)
+	}
+	sort.Strings(report.Revoked)
+	return report
+}
+
+// revoke releases the locks of e's handles and makes them fail all further
+// IO. v.mu must be held.
+func (e *entry) revoke() {
+	for f := range e.handles {
+		if f.lockLevel > sqlite3vfs.LockNone && e.src != nil {
+			e.src.Unpin()
+		}
+		f.lockLevel = sqlite3vfs.LockNone
+		f.revoked = true
+		delete(e.handles, f)
+	}
+	if e.unlocked != nil {
+		close(e.unlocked)
+		e.unlocked = nil
+	}
+}
+
+// Bind ties the named file to ctx, typically that of the request or job
+// the database was created for: once ctx is done, the file's handles are
+// revoked as by Drain and the file is deleted together with its journal and
+// WAL, so an abandoned request-scoped database does not leak. A file
+// replaced or deleted in the meantime is left alone.
+func (v *MemVFS) Bind(ctx context.Context, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return ErrNotFound
+	}
+	context.AfterFunc(ctx, func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		for fileName, other := range v.files {
+			if other == e || other.owner == e {
+				other.revoke()
+				if err := other.release(); err != nil {
+					v.log(slog.LevelError, "releasing unbound file failed", "file", v.LogName(fileName), "error", err)
+				}
+				v.forget(other)
+				delete(v.files, fileName)
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,141 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestDrain(t *testing.T) {
+	dv := memvfs.New()
+	if err := dv.Register("memvfs-drain"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:test-drain.db?vfs=memvfs-drain")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO demo(data) VALUES ('in flight')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := dv.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if len(report.Revoked) == 0 || report.Revoked[0] != "test-drain.db" {
+		t.Errorf("Expected test-drain.db to be revoked, got %v", report.Revoked)
+	}
+
+	if err := tx.Commit(); err == nil {
+		t.Errorf("Expected commit on revoked handle to fail")
+	}
+	tx.Rollback()
+
+	other, err := sql.Open("sqlite3", "file:test-drain-new.db?vfs=memvfs-drain")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer other.Close()
+	if err := other.Ping(); err == nil {
+		t.Errorf("Expected open on a drained store to fail")
+	}
+
+	if _, err := dv.Drain(context.Background()); err != nil {
+		t.Errorf("Drain of an idle store error: %v", err)
+	}
+}
+
+// refusingStore is a mapStore that refuses to store the named file.
+type refusingStore struct {
+	*mapStore
+	fail string
+}
+
+func (s refusingStore) Store(ctx context.Context, name string, data []byte) error {
+	if name == s.fail {
+		return errors.New("store unavailable")
+	}
+	return s.mapStore.Store(ctx, name, data)
+}
+
+func TestDrainFlush(t *testing.T) {
+	backing := &mapStore{files: make(map[string][]byte)}
+	dv := memvfs.New(memvfs.WithBackingStore(refusingStore{backing, "bad.db"}), memvfs.WithRetainOnClose())
+	if err := dv.Register("memvfs-drain-flush"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	for _, name := range []string{"good.db", "bad.db"} {
+		db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-drain-flush")
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+		db.Close()
+	}
+
+	report, err := dv.Drain(context.Background())
+	if err == nil {
+		t.Errorf("Expected the failed flush to be reported")
+	}
+	if len(report.Unflushed) != 1 || report.Unflushed[0] != "bad.db" {
+		t.Errorf("Expected bad.db unflushed, got %v", report.Unflushed)
+	}
+	if _, ok := backing.files["good.db"]; !ok {
+		t.Errorf("Expected good.db flushed to the backing store")
+	}
+}
+
+func TestBind(t *testing.T) {
+	name := "test-bind.db"
+	db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := v.Bind(ctx, name); err != nil {
+		t.Fatalf("Bind error: %v", err)
+	}
+	if err := v.Bind(ctx, "test-bind-missing.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := v.Stat(name); errors.Is(err, memvfs.ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("File still present after its context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('too late')`); err == nil {
+		t.Errorf("Expected writes through a revoked handle to fail")
+	}
+}
@@ -0,0 +1,272 @@
+package memvfs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// encryptChunkSize is the granularity at which encrypted files are sealed,
+// a common SQLite page size so that a page write re-seals one chunk.
+const encryptChunkSize = 4096
+
+// WithEncryption keeps the contents of the files the store holds encrypted
+// in memory with AES-GCM under key, which must be 16, 24 or 32 bytes long,
+// so that a memory dump or core file does not expose them. Each file is
+// sealed in chunks of 4 KiB under a key of its own derived from key, and
+// only the chunks a read or write touches are decrypted, into a buffer
+// that is cleared afterwards. A chunk modified in memory fails to decrypt
+// and the read fails with SQLITE_IOERR.
+//
+// Files are encrypted when created through SQLite and when first opened;
+// contents stored with Put, PutFile or Batch are encrypted as they are
+// stored. Encrypted files are served like mounted ones: they are not
+// charged against WithMemoryBudget, nor compressed, tiered or evicted, and
+// writes to them are not buffered per transaction. What the store hands
+// out, such as GetFile's result or exports, is plaintext.
+//
+// WithEncryption panics if key has an invalid length.
+func WithEncryption(key []byte) Option {
+	if _, err := aes.NewCipher(key); err != nil {
+		panic(fmt.Sprintf("memvfs: WithEncryption: %v", err))
+	}
+	key = append([]byte(nil), key...)
+	return func(v *MemVFS) {
+		v.encryptKey = key
+	}
+}
+
+// seal moves the contents of e into an encrypted source if the store
+// encrypts files and e is held in memory in plaintext. e must not be
+// locked by any handle, and v.mu must be held.
+func (v *MemVFS) seal(e *entry) error {
+	if !v.sealable(e) {
+		return nil
+	}
+	if err := v.thaw(e); err != nil {
+		return err
+	}
+	src, err := newEncryptedSource(v.encryptKey, e.data)
+	if err != nil {
+		return err
+	}
+	v.sealWith(e, src)
+	return nil
+}
+
+// sealable reports whether seal would encrypt e.
+func (v *MemVFS) sealable(e *entry) bool {
+	return v.encryptKey != nil && e.src == nil && e.disk == nil && !e.locked(sqlite3vfs.LockShared)
+}
+
+// sealWith replaces the plaintext contents of e with src, which holds them
+// encrypted. v.mu must be held.
+func (v *MemVFS) sealWith(e *entry, src *encryptedSource) {
+	// A buffer WriteFileTo is writing out is left to it.
+	if !e.exporting() {
+		clear(e.data)
+	}
+	e.data = nil
+	e.guarded = false
+	e.src = src
+	v.account(e)
+}
+
+// sealed reports whether e is held encrypted by seal.
+func (e *entry) sealed() bool {
+	_, ok := e.src.(*encryptedSource)
+	return ok
+}
+
+// unseal turns an encrypted e back into a file held in memory in plaintext,
+// for the administrative operations that rewrite its buffer in place; seal
+// encrypts it again. Other files are left alone. v.mu must be held.
+func (v *MemVFS) unseal(e *entry) error {
+	src, ok := e.src.(*encryptedSource)
+	if !ok {
+		return nil
+	}
+	data := make([]byte, src.Size())
+	if _, err := src.ReadAt(data, 0); err != nil && err != io.EOF {
+		return err
+	}
+	e.data = data
+	e.src = nil
+	e.setGuard()
+	v.account(e)
+	return nil
+}
+
+// encryptedSource serves a file sealed chunk by chunk with AES-GCM.
+type encryptedSource struct {
+	mu     sync.Mutex
+	aead   cipher.AEAD
+	size   int64
+	chunks [][]byte // nil for chunks never written, which read as zeros
+
+	// buf holds the plaintext of the chunk being worked on.
+	buf [encryptChunkSize]byte
+}
+
+func newEncryptedSource(key, data []byte) (*encryptedSource, error) {
+	// A random per-file key keeps each file well within the number of
+	// random nonces AES-GCM tolerates under one key.
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil)[:len(key)])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &encryptedSource{aead: aead}
+	if _, err := s.WriteAt(data, 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open decrypts chunk i into s.buf. s.mu must be held.
+func (s *encryptedSource) open(i int64) error {
+	sealed := s.chunks[i]
+	if sealed == nil {
+		clear(s.buf[:])
+		return nil
+	}
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return errChunkTampered
+	}
+	_, err := s.aead.Open(s.buf[:0], sealed[:n], sealed[n:], chunkAAD(i))
+	if err != nil {
+		return errChunkTampered
+	}
+	return nil
+}
+
+// seal encrypts s.buf as chunk i and clears it. s.mu must be held.
+func (s *encryptedSource) seal(i int64) error {
+	defer clear(s.buf[:])
+	n := s.aead.NonceSize()
+	sealed := make([]byte, n, n+encryptChunkSize+s.aead.Overhead())
+	if _, err := rand.Read(sealed); err != nil {
+		return err
+	}
+	s.chunks[i] = s.aead.Seal(sealed, sealed[:n], s.buf[:], chunkAAD(i))
+	return nil
+}
+
+var errChunkTampered = errors.New("memvfs: encrypted chunk failed authentication")
+
+// chunkAAD binds a sealed chunk to its position, so that chunks cannot be
+// swapped undetected.
+func chunkAAD(i int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(i))
+}
+
+func (s *encryptedSource) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer clear(s.buf[:])
+
+	n := 0
+	for n < len(p) && off < s.size {
+		i := off / encryptChunkSize
+		if err := s.open(i); err != nil {
+			return n, err
+		}
+		c := copy(p[n:], s.buf[off%encryptChunkSize:min(encryptChunkSize, s.size-i*encryptChunkSize)])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *encryptedSource) WriteAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if end := (off + int64(len(p)) + encryptChunkSize - 1) / encryptChunkSize; end > int64(len(s.chunks)) {
+		s.chunks = append(s.chunks, make([][]byte, end-int64(len(s.chunks)))...)
+	}
+	n := 0
+	for n < len(p) {
+		i, within := off/encryptChunkSize, off%encryptChunkSize
+		if within != 0 || len(p)-n < encryptChunkSize {
+			if err := s.open(i); err != nil {
+				return n, err
+			}
+		}
+		c := copy(s.buf[within:], p[n:])
+		if err := s.seal(i); err != nil {
+			return n, err
+		}
+		n += c
+		off += int64(c)
+	}
+	s.size = max(s.size, off)
+	return n, nil
+}
+
+func (s *encryptedSource) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunks := (size + encryptChunkSize - 1) / encryptChunkSize
+	if chunks < int64(len(s.chunks)) {
+		clear(s.chunks[chunks:])
+		s.chunks = s.chunks[:chunks]
+	} else {
+		s.chunks = append(s.chunks, make([][]byte, chunks-int64(len(s.chunks)))...)
+	}
+	// Clear the tail of a cut chunk, which a later extension must read as
+	// zeros.
+	if within := size % encryptChunkSize; size < s.size && within != 0 && s.chunks[chunks-1] != nil {
+		if err := s.open(chunks - 1); err != nil {
+			return err
+		}
+		clear(s.buf[within:])
+		if err := s.seal(chunks - 1); err != nil {
+			return err
+		}
+	}
+	s.size = size
+	return nil
+}
+
+func (s *encryptedSource) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *encryptedSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks = nil
+	return nil
+}
+
+// Pin and Unpin are no-ops: the store's locking keeps the contents
+// stable.
+func (s *encryptedSource) Pin() error { return nil }
+
+func (s *encryptedSource) Unpin() {}
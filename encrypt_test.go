@@ -0,0 +1,101 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestEncryption(t *testing.T) {
+	ev := memvfs.New(memvfs.WithEncryption(bytes.Repeat([]byte{7}, 32)))
+	if err := ev.Register("memvfs-encrypted"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:secret.db?vfs=memvfs-encrypted")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, "marker-"+randSeq(300)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	// Shrink the file so that truncation crosses chunks.
+	if _, err := db.Exec(`DELETE FROM demo WHERE id > 20`); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		t.Fatalf("Vacuum error: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM demo WHERE data LIKE 'marker-%'`).Scan(&n); err != nil {
+		t.Fatalf("Count error: %v", err)
+	}
+	if n != 20 {
+		t.Errorf("Expected 20 rows, got %d", n)
+	}
+	var check string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		t.Errorf("Integrity check failed: %q, %v", check, err)
+	}
+
+	// What the store hands out is plaintext, and contents put in are
+	// encrypted and served like the rest.
+	data, err := ev.GetFile("secret.db")
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) || !bytes.Contains(data, []byte("marker-")) {
+		t.Errorf("Expected GetFile to return the plaintext database")
+	}
+	if err := ev.PutFile("copy.db", data); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	copied, err := sql.Open("sqlite3", "file:copy.db?vfs=memvfs-encrypted")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer copied.Close()
+	if err := copied.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil || n != 20 {
+		t.Errorf("Expected 20 rows in the copy, got %d, %v", n, err)
+	}
+
+	// Transfers and snapshots see the plaintext too.
+	plain := memvfs.New()
+	if _, err := ev.CopyTo(context.Background(), "secret.db", plain, "plain.db"); err != nil {
+		t.Fatalf("CopyTo error: %v", err)
+	}
+	if got, _ := plain.GetFile("plain.db"); !bytes.Equal(got, data) {
+		t.Errorf("Expected CopyTo to transfer the plaintext database")
+	}
+	snap, err := ev.Snapshot("secret.db")
+	if err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM demo`); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if err := ev.Restore("secret.db", snap.ID); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil || n != 20 {
+		t.Errorf("Expected 20 rows after restore, got %d, %v", n, err)
+	}
+}
+
+func TestEncryptionKeyLength(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected WithEncryption to panic on a 10 byte key")
+		}
+	}()
+	memvfs.WithEncryption(make([]byte, 10))
+}
@@ -0,0 +1,53 @@
+package memvfs
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// CreateEphemeral creates an empty database named prefix followed by a
+// unique suffix and returns its name. The database outlives its
+// connections until ctx is done, then is deleted: right away if no
+// connection has it open, otherwise when the last one closes. Give ctx a
+// deadline to time-box the database, or pass a request's context to scope
+// scratch work to the request.
+func (v *MemVFS) CreateEphemeral(ctx context.Context, prefix string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	name := prefix + newUUID().String()
+
+	v.mu.Lock()
+	e := v.lookup(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	e.retain = true
+	v.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		v.expire(name, e)
+	})
+	return name, nil
+}
+
+// expire deletes the ephemeral file e, stored as name, or leaves it to its
+// last handle to.
+func (v *MemVFS) expire(name string, e *entry) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.files[name] != e {
+		return
+	}
+	e.retain = false
+	e.expired = true
+	if len(e.handles) > 0 {
+		return
+	}
+	if err := e.release(); err != nil {
+		v.log(slog.LevelError, "releasing expired file failed", "file", v.LogName(name), "error", err)
+	}
+	v.forget(e)
+	delete(v.files, name)
+	v.log(slog.LevelInfo, "ephemeral file expired", "file", v.LogName(name))
+}
@@ -0,0 +1,80 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateEphemeral(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	name, err := v.CreateEphemeral(ctx, "test-ephemeral-")
+	if err != nil {
+		t.Fatalf("CreateEphemeral error: %v", err)
+	}
+	if !strings.HasPrefix(name, "test-ephemeral-") {
+		t.Errorf("Expected the name to start with the prefix, got %q", name)
+	}
+	other, _ := v.CreateEphemeral(ctx, "test-ephemeral-")
+	if other == name {
+		t.Errorf("Expected unique names, got %q twice", name)
+	}
+
+	// The database outlives its connections while ctx is live.
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	db.Close()
+	if n := countRows(t, name); n != 0 {
+		t.Errorf("Expected an empty table, got %d rows", n)
+	}
+
+	// Cancelling ctx waits for open connections to close.
+	db, err = sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('v1')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	cancel()
+	waitGone := func(name string) bool {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if ok, _ := v.Access(name, 0); !ok {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitGone(other) {
+		t.Errorf("Expected the unused ephemeral database to be deleted")
+	}
+	if ok, _ := v.Access(name, 0); !ok {
+		t.Fatalf("Ephemeral database deleted while open")
+	}
+	db.Close()
+	if !waitGone(name) {
+		t.Errorf("Expected the last close to delete the expired database")
+	}
+
+	// A deadline time-boxes the database.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	name, err = v.CreateEphemeral(ctx, "test-ephemeral-")
+	if err != nil {
+		t.Fatalf("CreateEphemeral error: %v", err)
+	}
+	if !waitGone(name) {
+		t.Errorf("Expected the database to be deleted at its deadline")
+	}
+	if _, err := v.CreateEphemeral(ctx, "test-ephemeral-"); err == nil {
+		t.Errorf("Expected an error creating a database with an expired context")
+	}
+}
@@ -0,0 +1,129 @@
+package memvfs
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// WithEviction drops the least recently used closed files once the store
+// holds more than limit bytes in memory, for services that keep many
+// short-lived databases around WithRetainOnClose. A file is evictable once
+// no connection has it open, in the order its last connection closed; files
+// served by a backend, pinned with Pin or forked with Branch stay.
+//
+// persist, if not nil, is called with a copy of each file before it is
+// dropped, so that it can be written somewhere durable; a file persist
+// fails on is kept. Eviction runs in the background whenever a file's last
+// connection closes over the limit, and on demand with Evict.
+func WithEviction(limit int64, persist func(name string, data []byte) error) Option {
+	return func(v *MemVFS) {
+		v.evictLimit = limit
+		v.evictPersist = persist
+	}
+}
+
+// evictable reports whether e may be evicted: it is idle, held in memory
+// by the store itself and not pinned. v.mu must be held.
+func (v *MemVFS) evictable(name string, e *entry) bool {
+	_, branch := v.branches[name]
+	return !branch && e.footprint() > 0 && e.tierable(0, time.Now())
+}
+
+// Evict drops the least recently used closed files until the store holds
+// no more than the limit set with WithEviction, persisting each first, and
+// returns what they were. A file opened or modified while it is persisted
+// is kept. The returned error joins the errors of persist.
+func (v *MemVFS) Evict() ([]FileInfo, error) {
+	type candidate struct {
+		name  string
+		e     *entry
+		since time.Time
+	}
+	if v.evictLimit <= 0 {
+		return nil, nil
+	}
+	v.mu.Lock()
+	var candidates []candidate
+	for name, e := range v.files {
+		if v.evictable(name, e) {
+			candidates = append(candidates, candidate{name, e, e.idleSince()})
+		}
+	}
+	v.mu.Unlock()
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(a.since.Compare(b.since), cmp.Compare(a.name, b.name))
+	})
+
+	var infos []FileInfo
+	var errs []error
+	for _, c := range candidates {
+		if v.memUsed.Load() <= v.evictLimit {
+			break
+		}
+		info, ok, err := v.evict(c.name, c.e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.LogName(c.name), err))
+		}
+		if ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos, errors.Join(errs...)
+}
+
+// evict persists and drops e, stored as name, if it is still evictable
+// once persisted. It reports whether e was dropped.
+func (v *MemVFS) evict(name string, e *entry) (FileInfo, bool, error) {
+	v.mu.Lock()
+	if v.files[name] != e || !v.evictable(name, e) {
+		v.mu.Unlock()
+		return FileInfo{}, false, nil
+	}
+	if err := v.thaw(e); err != nil {
+		v.mu.Unlock()
+		return FileInfo{}, false, err
+	}
+	version, lastClose := e.version, e.lastClose
+	var data []byte
+	if v.evictPersist != nil {
+		data = bytes.Clone(e.data)
+	}
+	v.mu.Unlock()
+
+	if v.evictPersist != nil {
+		if err := v.evictPersist(name, data); err != nil {
+			v.log(slog.LevelWarn, "persisting file for eviction failed", "file", v.LogName(name), "error", err)
+			return FileInfo{}, false, err
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.files[name] != e || e.version != version || !e.lastClose.Equal(lastClose) || !v.evictable(name, e) {
+		return FileInfo{}, false, nil
+	}
+	info := v.fileInfo(name, e)
+	e.release()
+	v.forget(e)
+	delete(v.files, name)
+	v.evicted.Add(1)
+	v.log(slog.LevelInfo, "file evicted", "file", v.LogName(name), "bytes", info.Size)
+	return info, true, nil
+}
+
+// evictSoon starts Evict in the background if the store is over its
+// eviction limit and no eviction is running. v.mu must be held.
+func (v *MemVFS) evictSoon() {
+	if v.evictLimit <= 0 || v.memUsed.Load() <= v.evictLimit || !v.evicting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer v.evicting.Store(false)
+		v.Evict()
+	}()
+}
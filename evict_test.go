@@ -0,0 +1,82 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestEviction(t *testing.T) {
+	var mu sync.Mutex
+	persisted := make(map[string][]byte)
+	errPersist := errors.New("persist failed")
+	ev := memvfs.New(memvfs.WithRetainOnClose(), memvfs.WithEviction(10000, func(name string, data []byte) error {
+		if name == "stuck.db" {
+			return errPersist
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		persisted[name] = data
+		return nil
+	}))
+	for i, name := range []string{"stuck.db", "old.db", "mid.db", "new.db"} {
+		if err := ev.PutFile(name, bytes.Repeat([]byte{byte(i)}, 4096)); err != nil {
+			t.Fatalf("PutFile error: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite
+	open, _, err := ev.Open("old.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+
+	// 16 KiB against a 10000 byte limit: stuck.db fails to persist and
+	// old.db is open, so mid.db goes, then new.db.
+	evicted, err := ev.Evict()
+	if !errors.Is(err, errPersist) {
+		t.Errorf("Expected the persist error, got %v", err)
+	}
+	var names []string
+	for _, info := range evicted {
+		names = append(names, info.Name)
+	}
+	if want := []string{"mid.db", "new.db"}; !slices.Equal(names, want) {
+		t.Fatalf("Evicted %v, want %v", names, want)
+	}
+	if !bytes.Equal(persisted["mid.db"], bytes.Repeat([]byte{2}, 4096)) {
+		t.Errorf("Expected mid.db to be persisted before eviction")
+	}
+	for _, name := range []string{"stuck.db", "old.db"} {
+		if _, err := ev.Stat(name); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", name, err)
+		}
+	}
+
+	// Closing the last connection over the limit evicts in the background,
+	// starting with the file closed longest ago.
+	if err := ev.PutFile("more.db", make([]byte, 4096)); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	open.Close()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, err := ev.Stat("more.db"); errors.Is(err, memvfs.ErrNotFound) {
+			break
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := persisted["more.db"]; !ok {
+		t.Errorf("Expected more.db to be evicted, files: %+v", ev.ListFiles(""))
+	}
+	if _, err := ev.Stat("old.db"); err != nil {
+		t.Errorf("Expected the just closed old.db to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package memvfs
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// FaultConfig makes operations fail deterministically, to exercise an
+// application's handling of SQLite errors. Each FailAfterN field lets that
+// many operations of its kind succeed and fails the ones after; zero leaves
+// the kind alone.
+type FaultConfig struct {
+	// Prefix limits the faults to files whose names start with it.
+	Prefix string
+
+	FailAfterNReads     int
+	FailAfterNWrites    int
+	FailAfterNSyncs     int
+	FailAfterNTruncates int
+
+	// Error is returned by the failed operations, sqlite3vfs.IOError if
+	// nil; sqlite3vfs.FullError and BusyError are other useful choices.
+	// SQLite reports every failed write as SQLITE_IOERR_WRITE whatever the
+	// error.
+	Error error
+
+	// Once fails only the first operation past the limit, simulating a
+	// transient fault; otherwise the fault persists until cleared.
+	Once bool
+}
+
+// fault is an injected FaultConfig and its progress.
+type fault struct {
+	FaultConfig
+	reads, writes, syncs, truncates atomic.Int64
+	fired                           atomic.Bool
+}
+
+// InjectFault replaces any fault injected earlier with c. clear removes it
+// again.
+func (v *MemVFS) InjectFault(c FaultConfig) (clear func()) {
+	if c.Error == nil {
+		c.Error = sqlite3vfs.IOError
+	}
+	ft := &fault{FaultConfig: c}
+	v.fault.Store(ft)
+	return func() {
+		v.fault.CompareAndSwap(ft, nil)
+	}
+}
+
+// injected returns the error of the injected fault, if any, for an
+// operation of kind through f.
+func (v *MemVFS) injected(f *MemFile, kind OpKind) error {
+	ft := v.fault.Load()
+	if ft == nil || !strings.HasPrefix(f.fileName, ft.Prefix) {
+		return nil
+	}
+	var count *atomic.Int64
+	var limit int
+	switch kind {
+	case OpRead:
+		count, limit = &ft.reads, ft.FailAfterNReads
+	case OpWrite:
+		count, limit = &ft.writes, ft.FailAfterNWrites
+	case OpSync:
+		count, limit = &ft.syncs, ft.FailAfterNSyncs
+	case OpTruncate:
+		count, limit = &ft.truncates, ft.FailAfterNTruncates
+	}
+	if limit <= 0 || count.Add(1) <= int64(limit) {
+		return nil
+	}
+	if ft.Once && ft.fired.Swap(true) {
+		return nil
+	}
+	return ft.Error
+}
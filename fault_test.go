@@ -0,0 +1,76 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestInjectFault(t *testing.T) {
+	fv := memvfs.New()
+	f, _, err := fv.Open("faulty.db", sqlite3vfs.OpenMainJournal|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+
+	clear := fv.InjectFault(memvfs.FaultConfig{FailAfterNWrites: 2, Error: sqlite3vfs.FullError})
+	for i := 0; i < 2; i++ {
+		if _, err := f.WriteAt([]byte("data"), int64(i*4)); err != nil {
+			t.Fatalf("WriteAt %d error: %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := f.WriteAt([]byte("data"), 8); err != sqlite3vfs.FullError {
+			t.Errorf("Expected SQLITE_FULL past the limit, got %v", err)
+		}
+	}
+	if _, err := f.ReadAt(make([]byte, 4), 0); err != nil {
+		t.Errorf("Expected reads to be unaffected, got %v", err)
+	}
+	clear()
+	if _, err := f.WriteAt([]byte("data"), 8); err != nil {
+		t.Errorf("WriteAt error after clearing the fault: %v", err)
+	}
+
+	fv.InjectFault(memvfs.FaultConfig{Prefix: "faulty", FailAfterNSyncs: 1, Once: true})
+	f.Sync(sqlite3vfs.SyncNormal)
+	if err := f.Sync(sqlite3vfs.SyncNormal); err != sqlite3vfs.IOError {
+		t.Errorf("Expected the second sync to fail, got %v", err)
+	}
+	if err := f.Sync(sqlite3vfs.SyncNormal); err != nil {
+		t.Errorf("Expected a transient fault to fail once, got %v", err)
+	}
+}
+
+func TestInjectFaultSQL(t *testing.T) {
+	fv := memvfs.New()
+	if err := fv.Register("memvfs-fault"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:test-fault.db?vfs=memvfs-fault&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	clear := fv.InjectFault(memvfs.FaultConfig{FailAfterNWrites: 1})
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES ('lost')`)
+	if err == nil || !strings.Contains(err.Error(), "disk I/O error") {
+		t.Errorf("Expected SQLITE_IOERR to surface, got %v", err)
+	}
+	clear()
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('kept')`); err != nil {
+		t.Fatalf("Insert error after clearing the fault: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("Expected only the second insert to persist, got %d rows (%v)", n, err)
+	}
+}
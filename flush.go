@@ -0,0 +1,147 @@
+package memvfs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FlushConfig configures AutoFlush.
+type FlushConfig struct {
+	// Interval is the time between flushes, each delayed by a random
+	// extra of up to Jitter so that stores started together do not flush
+	// in step.
+	Interval time.Duration
+	Jitter   time.Duration
+
+	// Persist writes a copy of a main database somewhere durable, such as
+	// the function returned by PersistToDir. If nil, databases are stored
+	// in the store's BackingStore.
+	Persist func(name string, data []byte) error
+
+	// OnFlush, if not nil, is called after each database is persisted,
+	// with the error of persisting it.
+	OnFlush func(name string, err error)
+}
+
+// PersistToDir returns a FlushConfig.Persist that keeps each database as a
+// file named after it in dir, replaced atomically as by WithWriteThrough.
+func PersistToDir(dir string) func(name string, data []byte) error {
+	return func(name string, data []byte) error {
+		return DirStore(dir).Store(context.Background(), name, data)
+	}
+}
+
+// Flush passes a consistent image of each main database modified since it
+// was last flushed to persist, as ExportArchive takes them, and returns
+// the names persisted. A nil persist stores them in the BackingStore set
+// with WithBackingStore. onFlush, if not nil, is called with the outcome of
+// each. The returned error joins the failures, which are retried on the
+// next flush.
+//
+// Only the files in the store are flushed: a database whose last
+// connection closed is gone unless the store was created
+// WithRetainOnClose.
+func (v *MemVFS) Flush(persist func(name string, data []byte) error, onFlush func(name string, err error)) ([]string, error) {
+	type dirty struct {
+		name    string
+		e       *entry
+		version uint64
+	}
+	if persist == nil {
+		if v.store == nil {
+			return nil, errNoBackingStore
+		}
+		persist = func(name string, data []byte) error {
+			return v.store.Store(context.Background(), name, data)
+		}
+	}
+	v.mu.Lock()
+	var files []dirty
+	for name, e := range v.files {
+		if e.role == RoleMainDB && e.version != e.flushed {
+			files = append(files, dirty{name, e, e.version})
+		}
+	}
+	v.mu.Unlock()
+	slices.SortFunc(files, func(a, b dirty) int {
+		return cmp.Compare(a.name, b.name)
+	})
+
+	var names []string
+	var errs []error
+	for _, f := range files {
+		data, err := v.image(f.name)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err == nil {
+			err = persist(f.name, data)
+		}
+		if onFlush != nil {
+			onFlush(f.name, err)
+		}
+		if err != nil {
+			v.log(slog.LevelWarn, "flush failed", "file", v.LogName(f.name), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", v.LogName(f.name), err))
+			continue
+		}
+		v.mu.Lock()
+		if v.files[f.name] == f.e {
+			// The image may be newer than version, which only means the
+			// next flush persists it again.
+			f.e.flushed = f.version
+		}
+		v.mu.Unlock()
+		names = append(names, f.name)
+	}
+	if len(names) > 0 {
+		v.log(slog.LevelInfo, "files flushed", "files", len(names))
+	}
+	return names, errors.Join(errs...)
+}
+
+// AutoFlush runs Flush every cfg.Interval until stop is called, so that a
+// long-running service loses at most an interval of changes when it
+// restarts. stop flushes once more before it returns, so that calling it
+// on shutdown, after Drain, persists every change, and returns the error
+// of that last flush.
+func (v *MemVFS) AutoFlush(cfg FlushConfig) (stop func() error) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			delay := cfg.Interval
+			if cfg.Jitter > 0 {
+				delay += rand.N(cfg.Jitter)
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+				v.Flush(cfg.Persist, cfg.OnFlush)
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	var err error
+	return func() error {
+		once.Do(func() {
+			close(done)
+			<-exited
+			_, err = v.Flush(cfg.Persist, cfg.OnFlush)
+		})
+		return err
+	}
+}
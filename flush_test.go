@@ -0,0 +1,132 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestFlush(t *testing.T) {
+	fv := memvfs.New(memvfs.WithRetainOnClose())
+	if err := fv.Register("memvfs-flush"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:flush.db?vfs=memvfs-flush")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	errStore := errors.New("store failed")
+	failing := true
+	persisted := make(map[string][]byte)
+	persist := func(name string, data []byte) error {
+		if failing {
+			return errStore
+		}
+		persisted[name] = data
+		return nil
+	}
+	var outcomes []error
+	onFlush := func(name string, err error) {
+		outcomes = append(outcomes, err)
+	}
+
+	// A failed flush is retried by the next one.
+	if _, err := fv.Flush(persist, onFlush); !errors.Is(err, errStore) {
+		t.Errorf("Expected the store error, got %v", err)
+	}
+	failing = false
+	names, err := fv.Flush(persist, onFlush)
+	if err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if want := []string{"flush.db"}; !slices.Equal(names, want) {
+		t.Errorf("Flushed %v, want %v", names, want)
+	}
+	if len(outcomes) != 2 || !errors.Is(outcomes[0], errStore) || outcomes[1] != nil {
+		t.Errorf("Expected a failure then a success reported, got %v", outcomes)
+	}
+
+	// Unchanged databases are skipped.
+	if names, _ := fv.Flush(persist, nil); len(names) != 0 {
+		t.Errorf("Expected nothing to flush, got %v", names)
+	}
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('x')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if names, _ := fv.Flush(persist, nil); len(names) != 1 {
+		t.Errorf("Expected the modified database flushed, got %v", names)
+	}
+}
+
+func TestAutoFlush(t *testing.T) {
+	fv := memvfs.New(memvfs.WithRetainOnClose())
+	if err := fv.Register("memvfs-autoflush"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:auto.db?vfs=memvfs-autoflush")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	dir := t.TempDir()
+	var mu sync.Mutex
+	flushes := 0
+	stop := fv.AutoFlush(memvfs.FlushConfig{
+		Interval: 5 * time.Millisecond,
+		Jitter:   5 * time.Millisecond,
+		Persist:  memvfs.PersistToDir(dir),
+		OnFlush: func(name string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				t.Errorf("Flush of %s failed: %v", name, err)
+			}
+			flushes++
+		},
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := flushes
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// stop flushes what changed since.
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('last')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("stop error: %v", err)
+	}
+	disk, err := sql.Open("sqlite3", "file:"+filepath.Join(dir, "auto.db")+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open disk DB: %v", err)
+	}
+	defer disk.Close()
+	var n int
+	if err := disk.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+		t.Fatalf("Count on disk error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected the last insert on disk, got %d rows", n)
+	}
+}
@@ -0,0 +1,129 @@
+package memvfs
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// DefaultFreezeTimeout bounds how long Freeze waits for in-flight write
+// transactions to finish.
+const DefaultFreezeTimeout = 5 * time.Second
+
+// Freeze is FreezeContext with DefaultFreezeTimeout.
+func (v *MemVFS) Freeze(name string) (unfreeze func(), err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultFreezeTimeout)
+	defer cancel()
+
+	return v.FreezeContext(ctx, name)
+}
+
+// FreezeContext blocks new write transactions on name and waits for the ones
+// in flight to finish, leaving the file quiescent for administrative work
+// such as exporting or swapping it. Readers are unaffected. A transaction
+// is in flight from the moment it holds RESERVED, since from then on its
+// writes may be buffered for the file, not only once it holds EXCLUSIVE.
+//
+// While frozen, connections asking for a RESERVED lock get SQLITE_BUSY, so
+// they wait in their busy handler (busy_timeout) rather than fail outright.
+// If ctx is done before the file is quiescent, the freeze is lifted again
+// and ctx.Err() is returned. Otherwise unfreeze must be called to let
+// writers resume; calling it more than once is harmless.
+func (v *MemVFS) FreezeContext(ctx context.Context, name string) (unfreeze func(), err error) {
+	return v.freezeFiles(ctx, []string{name})
+}
+
+// freezeGroup is the set of files frozen by one call to freezeFiles.
+type freezeGroup struct {
+	entries []*entry
+}
+
+// writing reports whether a write transaction is in flight on e, i.e. a
+// handle holds RESERVED or higher. v.mu must be held.
+func (e *entry) writing() bool {
+	return e.locked(sqlite3vfs.LockReserved)
+}
+
+// freezeBlocks reports whether a freeze denies a new RESERVED lock on e.
+// A transaction spanning several files of a group (ATTACH) takes their
+// locks one at a time, so RESERVED is still granted while another file of
+// the group is write-locked: it is most likely the same transaction, and
+// the freeze is waiting for it to finish. v.mu must be held.
+func (e *entry) freezeBlocks() bool {
+	for _, g := range e.frozen {
+		inFlight := false
+		for _, other := range g.entries {
+			if other != e && other.writing() {
+				inFlight = true
+				break
+			}
+		}
+		if !inFlight {
+			return true
+		}
+	}
+	return false
+}
+
+// freezeFiles freezes all of names together and waits until none of them
+// has a write transaction in flight.
+func (v *MemVFS) freezeFiles(ctx context.Context, names []string) (unfreeze func(), err error) {
+	v.mu.Lock()
+	entries := make([]*entry, 0, len(names))
+	for _, name := range names {
+		e, ok := v.files[name]
+		if !ok {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, v.LogName(name))
+		}
+		entries = append(entries, e)
+	}
+	group := &freezeGroup{entries: entries}
+	for _, e := range entries {
+		e.frozen = append(e.frozen, group)
+	}
+
+	var once sync.Once
+	unfreeze = func() {
+		once.Do(func() {
+			v.mu.Lock()
+			for _, e := range entries {
+				e.frozen = slices.DeleteFunc(e.frozen, func(g *freezeGroup) bool {
+					return g == group
+				})
+			}
+			v.mu.Unlock()
+		})
+	}
+
+	// Files already passed can be write-locked again by a transaction that
+	// spans the group, so check them all until none is.
+	for {
+		i := slices.IndexFunc(entries, (*entry).writing)
+		if i < 0 {
+			break
+		}
+		e := entries[i]
+		if e.unlocked == nil {
+			e.unlocked = make(chan struct{})
+		}
+		unlocked := e.unlocked
+		v.mu.Unlock()
+
+		select {
+		case <-unlocked:
+		case <-ctx.Done():
+			unfreeze()
+			return nil, ctx.Err()
+		}
+
+		v.mu.Lock()
+	}
+	v.mu.Unlock()
+
+	return unfreeze, nil
+}
@@ -0,0 +1,112 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFreeze(t *testing.T) {
+	dbName := "test-freeze.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared&_busy_timeout=50", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	_, err = tx.Exec(`INSERT INTO demo(data) VALUES ('in flight')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	// The open transaction keeps the file from becoming quiescent.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := v.FreezeContext(ctx, dbName); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded while a write is in flight, got %v", err)
+	}
+
+	frozen := make(chan func())
+	go func() {
+		unfreeze, err := v.Freeze(dbName)
+		if err != nil {
+			t.Errorf("Freeze error: %v", err)
+		}
+		frozen <- unfreeze
+	}()
+
+	select {
+	case <-frozen:
+		t.Fatalf("Freeze returned before the in-flight transaction finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit error: %v", err)
+	}
+	unfreeze := <-frozen
+
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('frozen')`); err == nil {
+		t.Fatalf("Insert should fail while %v is frozen", dbName)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&count); err != nil {
+		t.Fatalf("Reads should continue while frozen: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 row, got %d", count)
+	}
+
+	unfreeze()
+	unfreeze()
+
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('thawed')`); err != nil {
+		t.Fatalf("Insert after unfreeze error: %v", err)
+	}
+}
+
+func TestFreezeWaitsForReserved(t *testing.T) {
+	dbName := "test-freeze-reserved.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&_busy_timeout=50&_txlock=immediate", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	// BEGIN IMMEDIATE holds RESERVED before writing anything; the writes
+	// that follow are buffered until the commit.
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	defer tx.Rollback()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := v.FreezeContext(ctx, dbName); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded while RESERVED is held, got %v", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO demo(data) VALUES ('reserved')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit error: %v", err)
+	}
+}
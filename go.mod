@@ -1,8 +1,10 @@
 module github.com/hleng1/memvfs
 
-go 1.23.4
+go 1.25
 
 require (
+	github.com/klauspost/compress v1.20.1
 	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/psanford/sqlite3vfs v0.0.0-20240315230605-24e1d98cf361
+	golang.org/x/sys v0.28.0
 )
@@ -0,0 +1,171 @@
+package memvfs
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// growthSamples bounds the size history kept per file, and
+// growthMinSpacing is the closest two samples are taken.
+const (
+	growthSamples    = 32
+	growthMinSpacing = 100 * time.Millisecond
+)
+
+// sizeSample is the size of a file at a point in time.
+type sizeSample struct {
+	at   time.Time
+	size int64
+}
+
+// growth is the size history of a file. Samples are taken as the file is
+// modified, at least every apart, the last one following the latest
+// modification; once growthSamples are held every other one is dropped and
+// the spacing doubled, so that the history covers the file's whole life in
+// bounded memory.
+type growth struct {
+	samples []sizeSample
+	every   time.Duration
+}
+
+// record notes that the file is size bytes at now.
+func (g *growth) record(now time.Time, size int64) {
+	if g.every == 0 {
+		g.every = growthMinSpacing
+	}
+	if n := len(g.samples); n > 1 && now.Sub(g.samples[n-2].at) < g.every {
+		g.samples[n-1] = sizeSample{now, size}
+		return
+	}
+	if len(g.samples) == growthSamples {
+		kept := g.samples[:0]
+		for i := 0; i < len(g.samples); i += 2 {
+			kept = append(kept, g.samples[i])
+		}
+		g.samples = kept
+		g.every *= 2
+	}
+	g.samples = append(g.samples, sizeSample{now, size})
+}
+
+// rate returns the growth of the file, in bytes per second, as the slope
+// of a least squares fit through its history and its size at now.
+func (g *growth) rate(now time.Time, size int64) float64 {
+	points := append(slices.Clip(g.samples), sizeSample{now, size})
+	if len(points) < 2 || !now.After(points[0].at) {
+		return 0
+	}
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		x := p.at.Sub(points[0].at).Seconds()
+		y := float64(p.size)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(len(points))
+	if d := n*sxx - sx*sx; d > 0 {
+		return (n*sxy - sx*sy) / d
+	}
+	return 0
+}
+
+// Forecast projects the memory held by the store, see Stats.Bytes, from
+// the growth of its files.
+type Forecast struct {
+	Horizon time.Duration
+
+	// Bytes is held now and Rate the growth in bytes per second; Projected
+	// is the bytes held at the horizon.
+	Bytes     int64
+	Rate      float64
+	Projected int64
+
+	// Budget is the memory budget set with WithMemoryBudget, or 0. If the
+	// store runs out of it within the horizon, Exhausted is when.
+	Budget    int64
+	Exhausted time.Time
+
+	// Namespaces break the forecast down by the part of file names up to
+	// and including their first "/", such as "tenant-a/", sorted by Rate,
+	// fastest growing first. Names without a "/" fall in namespace "".
+	Namespaces []NamespaceForecast
+}
+
+// NamespaceForecast is the forecast of the files of one namespace.
+type NamespaceForecast struct {
+	Namespace string
+	Files     int
+	Bytes     int64
+	Rate      float64
+	Projected int64
+
+	// Exhausted is when the namespace would use up the budget left by
+	// itself, if that falls within the horizon, regardless of the other
+	// namespaces.
+	Exhausted time.Time
+}
+
+// namespace returns the namespace of name; see Forecast.Namespaces.
+func namespace(name string) string {
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		return name[:i+1]
+	}
+	return ""
+}
+
+// Forecast estimates, from the recent growth of each file, how much memory
+// the store will hold horizon from now and when its memory budget will run
+// out, so that capacity alerts can fire before writes start failing with
+// SQLITE_FULL. Growth is measured from the sizes files had as they were
+// modified; shrinking files count against the growth of the rest.
+func (v *MemVFS) Forecast(horizon time.Duration) Forecast {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	f := Forecast{Horizon: horizon, Budget: v.memLimit}
+	byNamespace := make(map[string]*NamespaceForecast)
+	for name, e := range v.files {
+		ns := byNamespace[namespace(name)]
+		if ns == nil {
+			ns = &NamespaceForecast{Namespace: namespace(name)}
+			byNamespace[ns.Namespace] = ns
+		}
+		rate := e.growth.rate(now, e.size())
+		ns.Files++
+		ns.Bytes += e.footprint()
+		ns.Rate += rate
+		f.Bytes += e.footprint()
+		f.Rate += rate
+	}
+
+	project := func(bytes int64, rate float64) int64 {
+		return max(bytes+int64(rate*horizon.Seconds()), 0)
+	}
+	exhausted := func(rate float64) time.Time {
+		headroom := v.memLimit - f.Bytes
+		if v.memLimit <= 0 || rate <= 0 {
+			return time.Time{}
+		}
+		in := time.Duration(float64(headroom) / rate * float64(time.Second))
+		if in > horizon {
+			return time.Time{}
+		}
+		return now.Add(max(in, 0))
+	}
+	f.Projected = project(f.Bytes, f.Rate)
+	f.Exhausted = exhausted(f.Rate)
+	for _, ns := range byNamespace {
+		ns.Projected = project(ns.Bytes, ns.Rate)
+		ns.Exhausted = exhausted(ns.Rate)
+		f.Namespaces = append(f.Namespaces, *ns)
+	}
+	slices.SortFunc(f.Namespaces, func(a, b NamespaceForecast) int {
+		return cmp.Or(cmp.Compare(b.Rate, a.Rate), strings.Compare(a.Namespace, b.Namespace))
+	})
+	return f
+}
@@ -0,0 +1,62 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestForecast(t *testing.T) {
+	const budget = 64 << 20
+	gv := memvfs.New(memvfs.WithMemoryBudget(budget))
+	if err := gv.Register("memvfs-forecast"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if err := gv.PutFile("tenant-b/static.db", make([]byte, 8192)); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:tenant-a/log.db?vfs=memvfs-forecast")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE log (data BLOB)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for range 6 {
+		if _, err := db.Exec(`INSERT INTO log VALUES (zeroblob(100000))`); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	f := gv.Forecast(time.Hour)
+	if len(f.Namespaces) != 2 {
+		t.Fatalf("Expected 2 namespaces, got %+v", f.Namespaces)
+	}
+	a, b := f.Namespaces[0], f.Namespaces[1]
+	if a.Namespace != "tenant-a/" || b.Namespace != "tenant-b/" {
+		t.Fatalf("Expected tenant-a/ to grow fastest, got %+v", f.Namespaces)
+	}
+	// About 2 MB/s.
+	if a.Rate < 500_000 || a.Rate > 10_000_000 {
+		t.Errorf("Unexpected growth of tenant-a/: %.0f bytes/s", a.Rate)
+	}
+	if b.Rate != 0 || b.Projected != b.Bytes || !b.Exhausted.IsZero() {
+		t.Errorf("Expected tenant-b/ to hold steady, got %+v", b)
+	}
+	if f.Exhausted.IsZero() || time.Until(f.Exhausted) > time.Hour || f.Projected < budget {
+		t.Errorf("Expected the budget to run out within the hour, got %+v", f)
+	}
+	if f := gv.Forecast(time.Second); !f.Exhausted.IsZero() {
+		t.Errorf("Expected the budget to last the second, got %v", f.Exhausted)
+	}
+
+	s := gv.Stats()
+	if s.GrowthRate <= 0 || s.BudgetLeft <= 0 || s.BudgetLeft > time.Hour {
+		t.Errorf("Expected Stats to report the growth, got %.0f bytes/s with %v left", s.GrowthRate, s.BudgetLeft)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build memvfsdebug
+
+package memvfs
+
+import "fmt"
+
+// guardSize is the number of canary bytes kept past the end of each file's
+// buffer in memvfsdebug builds.
+const guardSize = 64
+
+const canary = 0xdb
+
+// setGuard writes canary bytes into the spare capacity past the end of e's
+// buffer, growing the capacity if needed. Go bounds checks stop overruns of
+// the buffer itself, but not a caller appending to a slice returned by
+// GetFile, which scribbles on that spare capacity and reappears as file
+// contents the next time the file grows in place. e must be locked.
+func (e *entry) setGuard() {
+	if e.disk != nil || e.src != nil {
+		e.guarded = false
+		return
+	}
+	n := len(e.data)
+	if cap(e.data)-n < guardSize {
+		data := make([]byte, n, n+guardSize)
+		copy(data, e.data)
+		e.data = data
+	}
+	guard := e.data[n : n+guardSize]
+	for i := range guard {
+		guard[i] = canary
+	}
+	e.guarded = true
+}
+
+// checkGuard returns an error if the canary bytes past the end of e's
+// buffer were overwritten since setGuard. Callers panic with it once they
+// have released e, so that the other connections fail fast rather than
+// hang on its lock. e must be locked.
+func (e *entry) checkGuard(name string) error {
+	if !e.guarded {
+		return nil
+	}
+	n := len(e.data)
+	for i, b := range e.data[n : n+guardSize] {
+		if b != canary {
+			return fmt.Errorf("memvfs: %s: buffer overrun detected at offset %d", name, n+i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build memvfsdebug
+
+package memvfs_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestGuardOverrun(t *testing.T) {
+	v := memvfs.New()
+	name := "test-guard-overrun.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(make([]byte, 4096), 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+
+	data, _ := v.GetFile(name)
+	_ = append(data, 'x')
+
+	func() {
+		defer func() {
+			r := recover()
+			if err, _ := r.(error); err == nil || !strings.Contains(err.Error(), "overrun detected at offset 4096") {
+				t.Errorf("Expected an overrun panic, got %v", r)
+			}
+		}()
+		f.ReadAt(make([]byte, 4096), 0)
+	}()
+
+	// The panic released the file, so other callers carry on.
+	if _, err := v.Stat(name); err != nil {
+		t.Errorf("Stat error after the panic: %v", err)
+	}
+}
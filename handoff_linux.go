@@ -0,0 +1,292 @@
+//go:build linux
+
+package memvfs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+	"golang.org/x/sys/unix"
+)
+
+// handoffEntry describes one file passed by Handoff. Its contents travel as
+// a memfd attached to the same message.
+type handoffEntry struct {
+	Name     string              `json:"name"`
+	UUID     UUID                `json:"uuid"`
+	Flags    sqlite3vfs.OpenFlag `json:"flags"`
+	Size     int64               `json:"size"`
+	ReadOnly bool                `json:"read_only,omitempty"`
+	Retain   bool                `json:"retain,omitempty"`
+}
+
+// Handoff passes every file in v to the process at the other end of conn,
+// which takes them over with Receive, so in-memory databases survive a
+// binary upgrade. Each file is written straight from the store into a memfd
+// and sent as a file descriptor with SCM_RIGHTS, so the contents are never
+// encoded into the stream nor copied on the heap. Linux only.
+//
+// The files are frozen together while they are copied, so the successor
+// sees them at a single point in time with no write transaction half
+// applied; Handoff waits up to DefaultFreezeTimeout for the ones in flight.
+// Files served by a backend, such as mounted or encrypted ones, are read
+// through it and handed over as plain in-memory files. v keeps its files;
+// stopping writers and exiting is up to the caller.
+func (v *MemVFS) Handoff(conn *net.UnixConn) error {
+	v.mu.RLock()
+	names := make([]string, 0, len(v.files))
+	for name := range v.files {
+		names = append(names, name)
+	}
+	v.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultFreezeTimeout)
+	defer cancel()
+
+	unfreeze, err := v.freezeFiles(ctx, names)
+	if err != nil {
+		return err
+	}
+	defer unfreeze()
+
+	var (
+		entries []handoffEntry
+		fds     []int
+	)
+	defer func() {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+	}()
+	for _, name := range names {
+		fd, err := unix.MemfdCreate("memvfs:"+name, unix.MFD_CLOEXEC)
+		if err != nil {
+			return err
+		}
+		fds = append(fds, fd)
+		he, ok, err := v.writeMemfd(name, fd)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			unix.Close(fd)
+			fds = fds[:len(fds)-1]
+			continue
+		}
+		entries = append(entries, he)
+	}
+	unfreeze()
+
+	for i, he := range entries {
+		msg, err := json.Marshal(he)
+		if err != nil {
+			return err
+		}
+		if err := sendHandoff(conn, msg, fds[i]); err != nil {
+			return err
+		}
+	}
+	return sendHandoff(conn, nil, -1)
+}
+
+// writeMemfd writes the named file's contents into the memfd fd and
+// describes it, reporting false if there is no such file. In-memory files
+// are written from their own buffer and sourced ones streamed from their
+// source.
+func (v *MemVFS) writeMemfd(name string, fd int) (handoffEntry, bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return handoffEntry{}, false, nil
+	}
+	if err := v.thaw(e); err != nil {
+		return handoffEntry{}, false, err
+	}
+	he := handoffEntry{
+		Name:     name,
+		UUID:     e.uuid,
+		Flags:    e.flags,
+		ReadOnly: e.readOnly,
+		Retain:   e.retain,
+	}
+	if src := e.reader(); src != nil {
+		n, err := io.Copy(io.NewOffsetWriter(memfd(fd), 0), io.NewSectionReader(src, 0, e.size()))
+		he.Size = n
+		return he, true, err
+	}
+	if _, err := memfd(fd).WriteAt(e.data, 0); err != nil {
+		return he, true, err
+	}
+	he.Size = int64(len(e.data))
+	return he, true, nil
+}
+
+// memfd writes to the memfd it numbers.
+type memfd int
+
+func (fd memfd) WriteAt(p []byte, off int64) (int, error) {
+	// A single pwrite moves at most about 2GiB.
+	n := 0
+	for n < len(p) {
+		c, err := unix.Pwrite(int(fd), p[n:], off+int64(n))
+		n += c
+		if err == nil && c == 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// sendHandoff writes one length-prefixed message, attaching fd if it is not
+// negative. An empty message ends the handoff.
+func sendHandoff(conn *net.UnixConn, msg []byte, fd int) error {
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	frame = append(frame, msg...)
+	var oob []byte
+	if fd >= 0 {
+		oob = syscall.UnixRights(fd)
+	}
+	n, _, err := conn.WriteMsgUnix(frame, oob, nil)
+	if err == nil && n != len(frame) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// Receive takes over the files sent by Handoff on conn. No file is added
+// unless all of them arrive, and ErrExist is returned if any name is already
+// taken in v.
+//
+// The files are not read into memory: each is served from a mapping of the
+// memfd it came in, the way MapDiskFile serves a disk file, and writes go
+// to an in-memory overlay of the blocks they touch. Deleting a file unmaps
+// its memfd.
+func (v *MemVFS) Receive(conn *net.UnixConn) (err error) {
+	received := make(map[string]*entry)
+	defer func() {
+		if err != nil {
+			for _, e := range received {
+				e.release()
+			}
+		}
+	}()
+	for {
+		msg, fd, err := recvHandoff(conn)
+		if err != nil {
+			if fd >= 0 {
+				unix.Close(fd)
+			}
+			return err
+		}
+		if len(msg) == 0 {
+			break
+		}
+
+		var he handoffEntry
+		err = json.Unmarshal(msg, &he)
+		if err == nil && fd < 0 {
+			err = fmt.Errorf("memvfs: handoff of %s carried no file descriptor", v.LogName(he.Name))
+		}
+		if err != nil {
+			if fd >= 0 {
+				unix.Close(fd)
+			}
+			return err
+		}
+
+		var data []byte
+		if he.Size > 0 {
+			data, err = syscall.Mmap(fd, 0, int(he.Size), syscall.PROT_READ, syscall.MAP_SHARED)
+		}
+		unix.Close(fd)
+		if err != nil {
+			return err
+		}
+
+		received[he.Name] = &entry{
+			uuid:     he.UUID,
+			src:      newOverlaySource(&mapping{data: data}, he.Size),
+			flags:    he.Flags,
+			role:     roleFromFlags(he.Flags),
+			handles:  make(map[*MemFile]struct{}),
+			readOnly: he.ReadOnly,
+			retain:   he.Retain,
+			modTime:  time.Now(),
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for name := range received {
+		if _, ok := v.files[name]; ok {
+			return fmt.Errorf("%w: %s", ErrExist, v.LogName(name))
+		}
+	}
+	for name, e := range received {
+		v.files[name] = e
+	}
+	v.recount()
+	return nil
+}
+
+// recvHandoff reads one message and the file descriptor sent with it, or -1
+// if there was none.
+func recvHandoff(conn *net.UnixConn) ([]byte, int, error) {
+	fd := -1
+	read := func(p []byte) error {
+		oob := make([]byte, syscall.CmsgSpace(4))
+		for len(p) > 0 {
+			n, oobn, _, _, err := conn.ReadMsgUnix(p, oob)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return io.ErrUnexpectedEOF
+			}
+			if oobn > 0 {
+				msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+				if err != nil {
+					return err
+				}
+				for _, m := range msgs {
+					fds, err := syscall.ParseUnixRights(&m)
+					if err != nil {
+						return err
+					}
+					for _, f := range fds {
+						if fd < 0 {
+							fd = f
+						} else {
+							unix.Close(f)
+						}
+					}
+				}
+			}
+			p = p[n:]
+		}
+		return nil
+	}
+
+	var hdr [4]byte
+	if err := read(hdr[:]); err != nil {
+		return nil, fd, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+	if err := read(msg); err != nil {
+		return nil, fd, err
+	}
+	return msg, fd, nil
+}
@@ -0,0 +1,106 @@
+//go:build linux
+
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestHandoff(t *testing.T) {
+	dbName := "test-handoff.db"
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	// Files served by a backend are handed over too.
+	overlayName := "test-handoff-overlay.db"
+	base := []byte("handoff overlay base")
+	if err := v.Overlay(bytes.NewReader(base), int64(len(base)), overlayName); err != nil {
+		t.Fatalf("Overlay error: %v", err)
+	}
+	defer v.Delete(overlayName, false)
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(t.TempDir(), "handoff.sock"), Net: "unix"})
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer l.Close()
+
+	// A second MemVFS stands in for the successor process.
+	successor := memvfs.New()
+	received := make(chan error, 1)
+	go func() {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			received <- err
+			return
+		}
+		defer conn.Close()
+		received <- successor.Receive(conn)
+	}()
+
+	conn, err := net.DialUnix("unix", nil, l.Addr().(*net.UnixAddr))
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer conn.Close()
+	if err := v.Handoff(conn); err != nil {
+		t.Fatalf("Handoff error: %v", err)
+	}
+	if err := <-received; err != nil {
+		t.Fatalf("Receive error: %v", err)
+	}
+
+	if got, err := successor.GetFile(overlayName); err != nil || !bytes.Equal(got, base) {
+		t.Errorf("Overlaid file after handoff = %q, %v; want %q", got, err, base)
+	}
+
+	if err := successor.Register("memvfs-successor"); err != nil {
+		t.Fatalf("Failed to register VFS: %v", err)
+	}
+	db2, err := successor.OpenDB(dbName, memvfs.ProfileNone)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	defer db2.Close()
+
+	var total int
+	if err := db2.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&total); err != nil {
+		t.Fatalf("Count query error: %v", err)
+	}
+	if total != 100 {
+		t.Errorf("Expected 100 rows after handoff, got %d", total)
+	}
+
+	// Received files are served from the memfd, with writes overlaid.
+	if _, err := db2.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+		t.Fatalf("Insert after handoff error: %v", err)
+	}
+	if err := db2.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&total); err != nil || total != 101 {
+		t.Errorf("Expected 101 rows after writing to the handed-off database, got %d, %v", total, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&total); err != nil || total != 100 {
+		t.Errorf("Expected the original to keep 100 rows, got %d, %v", total, err)
+	}
+}
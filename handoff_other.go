@@ -0,0 +1,18 @@
+//go:build !linux
+
+package memvfs
+
+import (
+	"errors"
+	"net"
+)
+
+// Handoff is only supported on Linux.
+func (v *MemVFS) Handoff(conn *net.UnixConn) error {
+	return errors.ErrUnsupported
+}
+
+// Receive is only supported on Linux.
+func (v *MemVFS) Receive(conn *net.UnixConn) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,31 @@
+package memvfs
+
+import "github.com/psanford/sqlite3vfs"
+
+// Hooks observe the operations SQLite performs on a store's files and may
+// veto them: a hook returning an error fails the operation with that error
+// before it touches the file. Return one of the sqlite3vfs errors, wrapped
+// or not, to choose the code SQLite sees; other errors are translated, such
+// as ErrBusy to SQLITE_BUSY, and default to SQLITE_IOERR. Nil hooks are
+// skipped.
+//
+// Hooks run on the IO path of every connection, without any of the store's
+// locks held, and must be safe for concurrent use. They must not call back
+// into the store's VFS methods for the same handle. OnOpen sees the name
+// SQLite passed, which is empty for temporary files; the other hooks see
+// the name the store gave them. OnDelete covers deletes requested by SQLite,
+// not files freed on Close or removed with Batch.
+type Hooks struct {
+	OnOpen     func(name string, flags sqlite3vfs.OpenFlag) error
+	OnRead     func(name string, off int64, n int) error
+	OnWrite    func(name string, off int64, p []byte) error
+	OnTruncate func(name string, size int64) error
+	OnDelete   func(name string) error
+}
+
+// WithHooks installs h on the store.
+func WithHooks(h Hooks) Option {
+	return func(v *MemVFS) {
+		v.hooks = h
+	}
+}
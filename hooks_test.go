@@ -0,0 +1,64 @@
+package memvfs_test
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestHooks(t *testing.T) {
+	var opens, reads, written atomic.Int64
+	hv := memvfs.New(memvfs.WithHooks(memvfs.Hooks{
+		OnOpen: func(name string, flags sqlite3vfs.OpenFlag) error {
+			opens.Add(1)
+			return nil
+		},
+		OnRead: func(name string, off int64, n int) error {
+			reads.Add(1)
+			return nil
+		},
+		OnWrite: func(name string, off int64, p []byte) error {
+			if strings.HasPrefix(name, "locked-") {
+				return sqlite3vfs.ReadOnlyError
+			}
+			written.Add(int64(len(p)))
+			return nil
+		},
+		OnDelete: func(name string) error {
+			return sqlite3vfs.IOError
+		},
+	}))
+
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	f, _, err := hv.Open("audited.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt([]byte("data"), 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+	f.ReadAt(make([]byte, 4), 0)
+
+	locked, _, err := hv.Open("locked-audit.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer locked.Close()
+	if _, err := locked.WriteAt([]byte("data"), 0); err != sqlite3vfs.ReadOnlyError {
+		t.Errorf("Expected the hook to veto the write, got %v", err)
+	}
+	if size, _ := locked.FileSize(); size != 0 {
+		t.Errorf("Expected the vetoed write not to reach the file, size is %d", size)
+	}
+	if err := hv.Delete("audited.db", false); err != sqlite3vfs.IOError {
+		t.Errorf("Expected the hook to veto the delete, got %v", err)
+	}
+
+	if opens.Load() != 2 || reads.Load() != 1 || written.Load() != 4 {
+		t.Errorf("Unexpected hook counts: %d opens, %d reads, %d bytes written", opens.Load(), reads.Load(), written.Load())
+	}
+}
@@ -0,0 +1,77 @@
+package memvfs
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// idleSince returns when e was last closed or modified, whichever is later,
+// or zero if a handle has it open. v.mu must be held.
+func (e *entry) idleSince() time.Time {
+	if len(e.handles) > 0 {
+		return time.Time{}
+	}
+	if e.lastClose.After(e.modTime) {
+		return e.lastClose
+	}
+	return e.modTime
+}
+
+// idle reports whether e has been idle for olderThan at now. Side files
+// count as in use while their database is. v.mu must be held.
+func (e *entry) idle(olderThan time.Duration, now time.Time) bool {
+	since := e.idleSince()
+	if since.IsZero() || now.Sub(since) < olderThan {
+		return false
+	}
+	return e.owner == nil || len(e.owner.handles) == 0
+}
+
+// IdleFiles lists the files, sorted by name, that no handle has had open
+// for at least olderThan, and which were not modified in that time. These
+// are files that outlive their connections, such as mounts, overlays and
+// pulled copies, or files put with Batch and never opened.
+func (v *MemVFS) IdleFiles(olderThan time.Duration) []FileInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	var infos []FileInfo
+	for name, e := range v.files {
+		if e.idle(olderThan, now) {
+			infos = append(infos, v.fileInfo(name, e))
+		}
+	}
+	slices.SortFunc(infos, func(a, b FileInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
+
+// CloseIdle deletes the files IdleFiles(olderThan) would list, releasing
+// their memory and any backend they are served from, and returns what they
+// were. It is a manual lever for reclaiming files nobody came back for.
+func (v *MemVFS) CloseIdle(olderThan time.Duration) []FileInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	var infos []FileInfo
+	for name, e := range v.files {
+		if !e.idle(olderThan, now) {
+			continue
+		}
+		infos = append(infos, v.fileInfo(name, e))
+		if err := e.release(); err != nil {
+			v.log(slog.LevelError, "releasing idle file failed", "file", v.LogName(name), "error", err)
+		}
+		v.forget(e)
+		delete(v.files, name)
+	}
+	slices.SortFunc(infos, func(a, b FileInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos
+}
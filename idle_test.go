@@ -0,0 +1,67 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestCloseIdle(t *testing.T) {
+	v := memvfs.New()
+	err := v.Batch(func(tx *memvfs.AdminTx) error {
+		return errors.Join(
+			tx.Put("idle.db", make([]byte, 4096)),
+			tx.Put("busy.db", make([]byte, 4096)),
+		)
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	if err := v.Overlay(bytes.NewReader(make([]byte, 4096)), 4096, "reopened.db"); err != nil {
+		t.Fatalf("Overlay error: %v", err)
+	}
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite
+	busy, _, err := v.Open("busy.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer busy.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	f, _, err := v.Open("reopened.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if info, _ := v.Stat("reopened.db"); !info.IdleSince.IsZero() {
+		t.Errorf("Expected no IdleSince while open, got %v", info.IdleSince)
+	}
+	f.Close()
+
+	if files := v.IdleFiles(time.Hour); len(files) != 0 {
+		t.Errorf("Expected no files idle for an hour, got %d", len(files))
+	}
+	files := v.IdleFiles(10 * time.Millisecond)
+	if len(files) != 1 || files[0].Name != "idle.db" {
+		t.Fatalf("Expected only idle.db to be idle, got %+v", files)
+	}
+	if files := v.IdleFiles(0); len(files) != 2 || files[1].Name != "reopened.db" {
+		t.Errorf("Expected the reopened overlay to be idle since its close, got %+v", files)
+	}
+
+	closed := v.CloseIdle(10 * time.Millisecond)
+	if len(closed) != 1 || closed[0].Name != "idle.db" {
+		t.Fatalf("Expected CloseIdle to close idle.db, got %+v", closed)
+	}
+	if _, err := v.Stat("idle.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected idle.db to be deleted, got %v", err)
+	}
+	for _, name := range []string{"busy.db", "reopened.db"} {
+		if _, err := v.Stat(name); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", name, err)
+		}
+	}
+}
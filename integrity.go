@@ -0,0 +1,157 @@
+package memvfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// IntegrityPlan describes an IntegrityCheckAll.
+type IntegrityPlan struct {
+	// Match selects the databases to check by name; nil checks every main
+	// database in the store.
+	Match func(name string) bool
+
+	// Quick runs PRAGMA quick_check, which skips the index checks, instead
+	// of PRAGMA integrity_check.
+	Quick bool
+
+	// Parallelism is the number of concurrent checks; 0 means 1.
+	Parallelism int
+
+	// Interval, if positive, is the least time between the starts of two
+	// checks, to bound the load a run puts on a busy store.
+	Interval time.Duration
+}
+
+// IntegrityResult is the outcome of checking one database.
+type IntegrityResult struct {
+	Name string
+
+	// Problems are the lines reported by the check; there are none for a
+	// sound database.
+	Problems []string
+	Duration time.Duration
+
+	// Err is set if the check could not be run.
+	Err error
+}
+
+// OK reports whether the database was checked and found sound.
+func (r IntegrityResult) OK() bool {
+	return r.Err == nil && len(r.Problems) == 0
+}
+
+// IntegrityCheckAll runs SQLite's integrity check on the databases selected
+// by plan and returns a result per database, sorted by name. Each check
+// runs on a consistent copy taken while the database is frozen, so live
+// connections only wait for the copy. The returned error joins the errors
+// of the checks that could not run; damage found is reported in the
+// results. v must have been registered with Register.
+func (v *MemVFS) IntegrityCheckAll(ctx context.Context, plan IntegrityPlan) ([]IntegrityResult, error) {
+	v.mu.Lock()
+	registered := v.vfsName != ""
+	var names []string
+	for name, e := range v.files {
+		if e.role == RoleMainDB && (plan.Match == nil || plan.Match(name)) {
+			names = append(names, name)
+		}
+	}
+	v.mu.Unlock()
+	if !registered {
+		return nil, ErrNotRegistered
+	}
+	slices.Sort(names)
+
+	results := make([]IntegrityResult, len(names))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+	for range max(plan.Parallelism, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = v.checkIntegrity(ctx, names[i], plan.Quick)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if plan.Interval > 0 {
+		ticker := time.NewTicker(plan.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	i := 0
+feed:
+	for ; i < len(names); i++ {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for j := range results {
+		if j >= i {
+			results[j] = IntegrityResult{Name: names[j], Err: ctx.Err()}
+		}
+		if results[j].Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.LogName(names[j]), results[j].Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// checkIntegrity checks a copy of name held in a scratch file.
+func (v *MemVFS) checkIntegrity(ctx context.Context, name string, quick bool) (r IntegrityResult) {
+	start := time.Now()
+	r.Name = name
+	defer func() {
+		r.Duration = time.Since(start)
+	}()
+
+	db, done, err := v.scratchDB(name, "integrity")
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer done()
+
+	pragma := "PRAGMA integrity_check"
+	if quick {
+		pragma = "PRAGMA quick_check"
+	}
+	rows, err := db.QueryContext(ctx, pragma)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			r.Err = err
+			return r
+		}
+		if line != "ok" {
+			r.Problems = append(r.Problems, line)
+		}
+	}
+	r.Err = rows.Err()
+	return r
+}
@@ -0,0 +1,53 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestIntegrityCheckAll(t *testing.T) {
+	iv := memvfs.New()
+	if err := iv.Register("memvfs-integrity"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	for _, name := range []string{"a.db", "b.db"} {
+		db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs-integrity&cache=shared", name))
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+	}
+	err := iv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("garbage.db", []byte(strings.Repeat("not a database", 512)))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	files := iv.Stats().Files
+
+	results, err := iv.IntegrityCheckAll(context.Background(), memvfs.IntegrityPlan{
+		Match:       func(name string) bool { return name != "b.db" },
+		Quick:       true,
+		Parallelism: 2,
+	})
+	if len(results) != 2 || results[0].Name != "a.db" || results[1].Name != "garbage.db" {
+		t.Fatalf("Expected results for a.db and garbage.db, got %+v", results)
+	}
+	if !results[0].OK() {
+		t.Errorf("Expected a.db to pass, got %+v", results[0])
+	}
+	if results[1].OK() || err == nil || !strings.Contains(err.Error(), "garbage.db") {
+		t.Errorf("Expected garbage.db to fail, got %+v (%v)", results[1], err)
+	}
+	if n := iv.Stats().Files; n != files {
+		t.Errorf("Expected the scratch copies to be gone, store has %d files, had %d", n, files)
+	}
+}
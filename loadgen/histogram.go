@@ -0,0 +1,70 @@
+package loadgen
+
+import (
+	"math"
+	"time"
+)
+
+// bucketsPerDoubling sets the resolution of a histogram: bucket bounds grow
+// by 2^(1/8), about 9%.
+const bucketsPerDoubling = 8
+
+// histogram counts latencies in exponentially growing buckets, so that a
+// long soak run does not keep every sample.
+type histogram struct {
+	counts [64 * bucketsPerDoubling]int64
+	n      int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func bucket(d time.Duration) int {
+	if d <= 1 {
+		return 0
+	}
+	return min(int(math.Log2(float64(d))*bucketsPerDoubling), 64*bucketsPerDoubling-1)
+}
+
+func (h *histogram) add(d time.Duration) {
+	h.counts[bucket(d)]++
+	h.n++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+func (h *histogram) merge(o *histogram) {
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.n += o.n
+	h.sum += o.sum
+	h.max = max(h.max, o.max)
+}
+
+// quantile returns the upper bound of the bucket holding the q quantile,
+// capped at the largest latency seen.
+func (h *histogram) quantile(q float64) time.Duration {
+	target := int64(math.Ceil(q * float64(h.n)))
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= target && c > 0 {
+			return min(time.Duration(math.Exp2(float64(i+1)/bucketsPerDoubling)), h.max)
+		}
+	}
+	return h.max
+}
+
+func (h *histogram) summary() Latency {
+	if h.n == 0 {
+		return Latency{}
+	}
+	return Latency{
+		Count: h.n,
+		Mean:  h.sum / time.Duration(h.n),
+		P50:   h.quantile(0.50),
+		P95:   h.quantile(0.95),
+		P99:   h.quantile(0.99),
+		Max:   h.max,
+	}
+}
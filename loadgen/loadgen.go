@@ -0,0 +1,415 @@
+// Package loadgen drives configurable mixes of reads, writes and
+// transactions against memvfs-backed databases and reports throughput,
+// latencies, memory growth and GC impact, so that performance can be
+// compared across releases. cmd/memvfs-bench is its command line front end.
+package loadgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+// OpKind is a kind of operation issued by a worker.
+type OpKind string
+
+const (
+	// OpRead reads a row by key.
+	OpRead OpKind = "read"
+
+	// OpWrite inserts or replaces a row by key, as a transaction of its
+	// own.
+	OpWrite OpKind = "write"
+
+	// OpTransaction writes Config.TxRows rows in one transaction.
+	OpTransaction OpKind = "transaction"
+)
+
+// Mix weighs the operations workers pick from; a Mix of {Reads: 8,
+// Writes: 2} issues four reads for every write.
+type Mix struct {
+	Reads        int
+	Writes       int
+	Transactions int
+}
+
+func (m Mix) total() int {
+	return m.Reads + m.Writes + m.Transactions
+}
+
+// pick returns the operation n, drawn from [0, m.total()), stands for.
+func (m Mix) pick(n int) OpKind {
+	switch {
+	case n < m.Reads:
+		return OpRead
+	case n < m.Reads+m.Writes:
+		return OpWrite
+	default:
+		return OpTransaction
+	}
+}
+
+// Config describes a run. Zero fields take the defaults noted.
+type Config struct {
+	// Databases is the number of databases the workers spread over, 1 by
+	// default, each holding Keys rows of RowSize bytes to start with,
+	// 10000 and 100 by default.
+	Databases int
+	Keys      int
+	RowSize   int
+
+	// Workers is the number of goroutines issuing operations, GOMAXPROCS
+	// by default.
+	Workers int
+
+	// Mix is the operations issued, {Reads: 8, Writes: 2} by default, and
+	// TxRows the rows written by each OpTransaction, 10 by default.
+	Mix    Mix
+	TxRows int
+
+	// The run stops after Duration, or once Ops operations were issued if
+	// Ops is positive. Duration is 10 seconds by default unless Ops is set.
+	Duration time.Duration
+	Ops      int64
+
+	// JournalMode is the SQLite journal mode of the databases, DELETE by
+	// default.
+	JournalMode string
+
+	// SampleEvery is how often progress is sampled into Report.Samples,
+	// every second by default, and passed to Progress if it is set. A soak
+	// run reads memory growth off the samples.
+	SampleEvery time.Duration
+	Progress    func(Sample) `json:"-"`
+
+	// Options configure the store the databases live in.
+	Options []memvfs.Option `json:"-"`
+}
+
+func (c *Config) setDefaults() {
+	if c.Databases <= 0 {
+		c.Databases = 1
+	}
+	if c.Keys <= 0 {
+		c.Keys = 10000
+	}
+	if c.RowSize <= 0 {
+		c.RowSize = 100
+	}
+	if c.Workers <= 0 {
+		c.Workers = runtime.GOMAXPROCS(0)
+	}
+	if c.Mix.total() <= 0 {
+		c.Mix = Mix{Reads: 8, Writes: 2}
+	}
+	if c.TxRows <= 0 {
+		c.TxRows = 10
+	}
+	if c.Duration <= 0 && c.Ops <= 0 {
+		c.Duration = 10 * time.Second
+	}
+	if c.JournalMode == "" {
+		c.JournalMode = "DELETE"
+	}
+	if c.SampleEvery <= 0 {
+		c.SampleEvery = time.Second
+	}
+}
+
+// Report is the outcome of Run.
+type Report struct {
+	Config  Config
+	Elapsed time.Duration
+
+	// Ops counts the operations issued and Errors those that failed; Err
+	// is the first failure.
+	Ops    int64
+	Errors int64
+	Err    error `json:"-"`
+
+	// Throughput is the operations completed per second.
+	Throughput float64
+
+	Latencies map[OpKind]Latency
+
+	// HeapStart and HeapEnd are the live heap before and after the run,
+	// and HeapPeak the largest sampled in between. GCCycles and GCPause
+	// are the collections the run caused and the time they stopped the
+	// world.
+	HeapStart uint64
+	HeapEnd   uint64
+	HeapPeak  uint64
+	GCCycles  uint32
+	GCPause   time.Duration
+
+	Samples []Sample
+
+	// Stats are the store's statistics at the end of the run.
+	Stats memvfs.Stats
+}
+
+// Latency summarizes the latencies of one kind of operation. Percentiles
+// are accurate to about 10%.
+type Latency struct {
+	Count int64
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Sample is the progress of a run at one point.
+type Sample struct {
+	Elapsed    time.Duration
+	Ops        int64
+	HeapAlloc  uint64
+	StoreBytes int64
+}
+
+// vfsSeq numbers the VFS names registered by Run, as SQLite offers no way
+// to unregister one.
+var vfsSeq atomic.Int64
+
+// Run sets up a fresh store as cfg describes and drives it until the run
+// is over or ctx is done. An error is only returned if the run could not
+// be set up; failed operations are counted in the report.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	cfg.setDefaults()
+	report := Report{Config: cfg}
+	if cfg.Mix.Reads < 0 || cfg.Mix.Writes < 0 || cfg.Mix.Transactions < 0 {
+		return report, errors.New("loadgen: negative operation weight")
+	}
+
+	v := memvfs.New(cfg.Options...)
+	vfsName := fmt.Sprintf("memvfs-loadgen-%d", vfsSeq.Add(1))
+	if err := v.Register(vfsName); err != nil {
+		return report, err
+	}
+	dbs := make([]*sql.DB, cfg.Databases)
+	defer func() {
+		for _, db := range dbs {
+			if db != nil {
+				db.Close()
+			}
+		}
+	}()
+	for i := range dbs {
+		dsn := fmt.Sprintf("file:bench-%d.db?vfs=%s&_busy_timeout=10000&_txlock=immediate&_journal_mode=%s",
+			i, vfsName, cfg.JournalMode)
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			return report, err
+		}
+		dbs[i] = db
+		if err := seed(ctx, db, cfg); err != nil {
+			return report, fmt.Errorf("loadgen: seeding database %d: %w", i, err)
+		}
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	report.HeapStart = before.HeapAlloc
+	report.HeapPeak = before.HeapAlloc
+
+	var issued, failed atomic.Int64
+	var firstErr error
+	var errOnce sync.Once
+	workers := make([]*worker, cfg.Workers)
+	start := time.Now()
+
+	sampled := make(chan struct{})
+	stopSampling := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(cfg.SampleEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s := sample(v, start, issued.Load())
+				report.Samples = append(report.Samples, s)
+				report.HeapPeak = max(report.HeapPeak, s.HeapAlloc)
+				if cfg.Progress != nil {
+					cfg.Progress(s)
+				}
+			case <-stopSampling:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range workers {
+		w := &worker{
+			cfg:       cfg,
+			dbs:       dbs,
+			rnd:       rand.New(rand.NewPCG(uint64(i), uint64(start.UnixNano()))),
+			latencies: make(map[OpKind]*histogram),
+			row:       make([]byte, cfg.RowSize),
+		}
+		workers[i] = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if n := issued.Add(1); cfg.Ops > 0 && n > cfg.Ops {
+					issued.Add(-1)
+					return
+				}
+				if err := w.step(ctx); err != nil && ctx.Err() == nil {
+					failed.Add(1)
+					errOnce.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	close(stopSampling)
+	<-sampled
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	report.HeapEnd = after.HeapAlloc
+	report.HeapPeak = max(report.HeapPeak, after.HeapAlloc)
+	report.GCCycles = after.NumGC - before.NumGC
+	report.GCPause = time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+
+	report.Ops = issued.Load()
+	report.Errors = failed.Load()
+	report.Err = firstErr
+	report.Latencies = make(map[OpKind]Latency)
+	merged := make(map[OpKind]*histogram)
+	var completed int64
+	for _, w := range workers {
+		for kind, h := range w.latencies {
+			if merged[kind] == nil {
+				merged[kind] = new(histogram)
+			}
+			merged[kind].merge(h)
+			completed += h.n
+		}
+	}
+	for kind, h := range merged {
+		report.Latencies[kind] = h.summary()
+	}
+	report.Throughput = float64(completed) / report.Elapsed.Seconds()
+	report.Stats = v.Stats()
+	return report, nil
+}
+
+// seed creates the table of db and fills it with cfg.Keys rows.
+func seed(ctx context.Context, db *sql.DB, cfg Config) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE kv (id INTEGER PRIMARY KEY, data BLOB)`); err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	row := make([]byte, cfg.RowSize)
+	for id := range cfg.Keys {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO kv VALUES (?, ?)`, id, row); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sample takes a Sample of a run started at start.
+func sample(v *memvfs.MemVFS, start time.Time, ops int64) Sample {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Sample{
+		Elapsed:    time.Since(start),
+		Ops:        ops,
+		HeapAlloc:  m.HeapAlloc,
+		StoreBytes: v.Stats().Bytes,
+	}
+}
+
+// worker issues operations from one goroutine, keeping its own latencies.
+type worker struct {
+	cfg       Config
+	dbs       []*sql.DB
+	rnd       *rand.Rand
+	latencies map[OpKind]*histogram
+	row       []byte
+}
+
+// step issues one operation, picked by the mix, against a random database.
+func (w *worker) step(ctx context.Context) error {
+	db := w.dbs[w.rnd.IntN(len(w.dbs))]
+	kind := w.cfg.Mix.pick(w.rnd.IntN(w.cfg.Mix.total()))
+	start := time.Now()
+	var err error
+	switch kind {
+	case OpRead:
+		var data []byte
+		err = db.QueryRowContext(ctx, `SELECT data FROM kv WHERE id = ?`, w.key()).Scan(&data)
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+	case OpWrite:
+		_, err = db.ExecContext(ctx, `INSERT OR REPLACE INTO kv VALUES (?, ?)`, w.key(), w.fill())
+	case OpTransaction:
+		err = w.transaction(ctx, db)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	h := w.latencies[kind]
+	if h == nil {
+		h = new(histogram)
+		w.latencies[kind] = h
+	}
+	h.add(time.Since(start))
+	return nil
+}
+
+func (w *worker) transaction(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for range w.cfg.TxRows {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO kv VALUES (?, ?)`, w.key(), w.fill()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (w *worker) key() int {
+	return w.rnd.IntN(w.cfg.Keys)
+}
+
+// fill fills the worker's row buffer with fresh bytes and returns it.
+func (w *worker) fill() []byte {
+	for i := 0; i < len(w.row); i += 8 {
+		n := w.rnd.Uint64()
+		for j := i; j < min(i+8, len(w.row)); j++ {
+			w.row[j] = byte(n)
+			n >>= 8
+		}
+	}
+	return w.row
+}
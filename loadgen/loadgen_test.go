@@ -0,0 +1,46 @@
+package loadgen_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hleng1/memvfs/loadgen"
+)
+
+func TestRun(t *testing.T) {
+	var samples int
+	report, err := loadgen.Run(context.Background(), loadgen.Config{
+		Databases: 2,
+		Keys:      100,
+		Workers:   4,
+		Mix:       loadgen.Mix{Reads: 5, Writes: 3, Transactions: 2},
+		Ops:       500,
+		Progress:  func(loadgen.Sample) { samples++ },
+	})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if report.Errors > 0 {
+		t.Fatalf("Expected no failed operations, got %d: %v", report.Errors, report.Err)
+	}
+	if report.Ops != 500 {
+		t.Errorf("Expected 500 operations, got %d", report.Ops)
+	}
+	var count int64
+	for _, kind := range []loadgen.OpKind{loadgen.OpRead, loadgen.OpWrite, loadgen.OpTransaction} {
+		l := report.Latencies[kind]
+		if l.Count == 0 || l.P50 > l.P99 || l.P99 > l.Max {
+			t.Errorf("Unexpected %s latencies %+v", kind, l)
+		}
+		count += l.Count
+	}
+	if count != 500 || report.Throughput <= 0 {
+		t.Errorf("Expected 500 timed operations and a throughput, got %d at %.0f/s", count, report.Throughput)
+	}
+	if report.Stats.Files < 2 {
+		t.Errorf("Expected the report to cover both databases, got %d files", report.Stats.Files)
+	}
+	if len(report.Samples) != samples {
+		t.Errorf("Expected Progress to see every sample, got %d of %d", samples, len(report.Samples))
+	}
+}
@@ -0,0 +1,97 @@
+package memvfs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Defaults for WithLogSampling.
+const (
+	DefaultLogSampleTick       = time.Second
+	DefaultLogSampleFirst      = 10
+	DefaultLogSampleThereafter = 100
+)
+
+// WithLogger has the store log to l what happens out of sight of its
+// callers: registration and draining, files evicted, compressed, tiered or
+// written back to a backing VFS, failed background work, backend read
+// failures, circuit breakers opening, IO deadlines and lock contention.
+// Which levels are logged is up to l's handler; failures are logged at
+// Warn or Error, routine work at Info and lock contention at Debug. Events
+// that can occur on every query are sampled as set with WithLogSampling.
+// Nothing is logged by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(v *MemVFS) {
+		v.logger = l
+	}
+}
+
+// WithLogSampling sets how high-frequency events, such as busy locks and
+// failed backend reads, are sampled: of the events with the same message
+// in each tick, the first are logged, then every thereafter-th. thereafter
+// <= 0 drops the rest of the tick and first <= 0 disables sampling.
+func WithLogSampling(tick time.Duration, first, thereafter int) Option {
+	return func(v *MemVFS) {
+		v.sampler = logSampler{tick: tick, first: first, thereafter: thereafter}
+	}
+}
+
+// logSampler counts the events of each message in the current tick.
+type logSampler struct {
+	tick              time.Duration
+	first, thereafter int
+
+	mu     sync.Mutex
+	counts map[string]*logCount
+}
+
+type logCount struct {
+	start time.Time
+	n     int
+}
+
+// sample reports whether the next event with msg is to be logged.
+func (s *logSampler) sample(msg string, now time.Time) bool {
+	if s.first <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counts == nil {
+		s.counts = make(map[string]*logCount)
+	}
+	c := s.counts[msg]
+	if c == nil || now.Sub(c.start) >= s.tick {
+		c = &logCount{start: now}
+		s.counts[msg] = c
+	}
+	c.n++
+	switch {
+	case c.n <= s.first:
+		return true
+	case s.thereafter <= 0:
+		return false
+	default:
+		return (c.n-s.first)%s.thereafter == 0
+	}
+}
+
+// log logs msg at level with args if the store has a logger.
+func (v *MemVFS) log(level slog.Level, msg string, args ...any) {
+	if v.logger != nil {
+		v.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// logSampled is log for high-frequency events.
+func (v *MemVFS) logSampled(level slog.Level, msg string, args ...any) {
+	if v.logger == nil || !v.logger.Enabled(context.Background(), level) {
+		return
+	}
+	if v.sampler.sample(msg, time.Now()) {
+		v.logger.Log(context.Background(), level, msg, args...)
+	}
+}
@@ -0,0 +1,69 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	lv := memvfs.New(
+		memvfs.WithLogger(logger),
+		memvfs.WithLogSampling(time.Hour, 1, 2),
+		memvfs.WithRetainOnClose(),
+		memvfs.WithEviction(1000, nil),
+	)
+	if err := lv.Register("memvfs-log"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if err := lv.PutFile("evicted.db", make([]byte, 4096)); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	if _, err := lv.Evict(); err != nil {
+		t.Fatalf("Evict error: %v", err)
+	}
+
+	// Five busy locks are sampled down to the first, third and fifth.
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	writer, _, err := lv.Open("busy.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer writer.Close()
+	reader, _, err := lv.Open("busy.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer reader.Close()
+	if err := writer.Lock(sqlite3vfs.LockShared); err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	if err := writer.Lock(sqlite3vfs.LockReserved); err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	if err := reader.Lock(sqlite3vfs.LockShared); err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	for range 5 {
+		if err := reader.Lock(sqlite3vfs.LockReserved); err == nil {
+			t.Fatalf("Expected the second RESERVED lock to be busy")
+		}
+	}
+
+	out := buf.String()
+	for _, want := range []string{"msg=registered vfs=memvfs-log", "msg=\"file evicted\" file=evicted.db bytes=4096"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the log, got:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, `msg="lock busy"`); n != 3 {
+		t.Errorf("Expected 3 busy locks logged, got %d:\n%s", n, out)
+	}
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package memvfs
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// MapDiskFile exposes the database at path as name without reading it into
+// memory: the file is mapped read-only and reads are served from the
+// mapping. Writes through SQLite go to an in-memory overlay of the blocks
+// they touch and never reach path. The file at path must not be modified
+// while mapped. Delete name to unmap it. Linux only.
+func (v *MemVFS) MapDiskFile(path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if info.Size() > 0 {
+		data, err = syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+		if err != nil {
+			return err
+		}
+	}
+	return v.Overlay(&mapping{data: data}, info.Size(), name)
+}
+
+// mapping is the read-only base of a mapped disk file.
+type mapping struct {
+	data []byte
+}
+
+func (m *mapping) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mapping) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	return syscall.Munmap(m.data)
+}
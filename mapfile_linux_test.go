@@ -0,0 +1,64 @@
+//go:build linux
+
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMapDiskFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.db")
+	disk, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open disk DB: %v", err)
+	}
+	_, err = disk.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := disk.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	disk.Close()
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+
+	name := "test-mapped.db"
+	if err := v.MapDiskFile(path, name); err != nil {
+		t.Fatalf("MapDiskFile error: %v", err)
+	}
+	defer v.Delete(name, false)
+
+	if n := countRows(t, name); n != 100 {
+		t.Errorf("Expected 100 rows in the mapped file, got %d", n)
+	}
+	db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert on mapped file error: %v", err)
+		}
+	}
+	db.Close()
+	if n := countRows(t, name); n != 150 {
+		t.Errorf("Expected 150 rows after writing through the overlay, got %d", n)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("Writes reached the mapped disk file")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package memvfs
+
+import "errors"
+
+// MapDiskFile is only supported on Linux.
+func (v *MemVFS) MapDiskFile(path, name string) error {
+	return errors.ErrUnsupported
+}
@@ -1,62 +1,645 @@
 package memvfs
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/psanford/sqlite3vfs"
 )
 
+// ErrNotFound is returned when a named file is not present in the store.
+var ErrNotFound = errors.New("file not found in memvfs")
+
+// ErrExist is returned when creating a file whose name is already taken.
+var ErrExist = errors.New("file already exists in memvfs")
+
+// ErrBusy is returned by administrative operations on files that are in use
+// by a connection.
+var ErrBusy = errors.New("file is in use")
+
 type MemVFS struct {
-	mu    sync.Mutex
-	files map[string][]byte
+	// mu guards the store. Operations on the contents of a single file only
+	// read-lock it and lock the file's entry instead; see lockEntry.
+	mu      sync.RWMutex
+	files   map[string]*entry
+	tempSeq uint64
+	vfsName string
+
+	// roleIO accumulates IO per role across the lifetime of the store,
+	// including files that have since been deleted.
+	roleIO [numRoles]ioCounters
+
+	// readOnlyAll rejects new write transactions on every file; see
+	// SetReadOnlyAll.
+	readOnlyAll   bool
+	abortInFlight bool
+
+	// draining rejects Opens; closed is closed whenever a handle is closed
+	// so that Drain can check again.
+	draining bool
+	closed   chan struct{}
+
+	// profile, if set, simulates a storage device; see SetStorageProfile.
+	profile atomic.Pointer[StorageProfile]
+
+	// fault, if set, fails operations deterministically; see InjectFault.
+	fault atomic.Pointer[fault]
+
+	snapshots map[SnapshotID]*snapshot
+	snapSeq   uint64
+
+	// snapRetention, if positive, caps the number of unlabeled snapshots.
+	snapRetention int
+
+	branches map[string]*Branch
+
+	// handleSeq numbers handles. watches and recorders observe them; see
+	// ConnHandle and RecordPages.
+	handleSeq uint64
+	watches   []*handleWatch
+	recorders map[HandleID]*PageRecorder
+
+	labelIO map[string]*ioCounters
+
+	// tempLimit, spillDirs and placement configure WithTempBudget and
+	// WithSpillDirs.
+	tempLimit    int64
+	spillDirs    []string
+	placement    Placement
+	tempSpilled  atomic.Int64
+	tempRejected atomic.Int64
+
+	// memLimit is the budget set with WithMemoryBudget and memUsed the
+	// bytes charged against it.
+	memLimit int64
+	memUsed  atomic.Int64
+
+	// evictLimit and evictPersist configure WithEviction; evicting is set
+	// while an eviction runs in the background and evicted counts the
+	// files dropped.
+	evictLimit   int64
+	evictPersist func(name string, data []byte) error
+	evicting     atomic.Bool
+	evicted      atomic.Int64
+
+	ioDeadline time.Duration
+
+	breakerFailures int
+	breakerCooldown time.Duration
+
+	copyOnRead       bool
+	lazyCreate       bool
+	deleteOnAnyClose bool
+	retainOnClose    bool
+
+	// sectorSize and characteristics are reported to SQLite for every
+	// file; see WithSectorSize and WithDeviceCharacteristics.
+	sectorSize      int64
+	characteristics sqlite3vfs.DeviceCharacteristic
+
+	names      nameMap
+	hooks      Hooks
+	validators []Validator
+	redactors  []Redactor
+
+	// logger and sampler are set with WithLogger and WithLogSampling.
+	logger  *slog.Logger
+	sampler logSampler
+
+	// backing is the VFS the store caches; see WithBackingVFS.
+	backing sqlite3vfs.VFS
+
+	// store is set with WithBackingStore, and storeOnSync with
+	// WithWriteThrough.
+	store       BackingStore
+	storeOnSync bool
+
+	// encryptKey is the key set with WithEncryption.
+	encryptKey []byte
+
+	crashSim bool
+
+	// checkReads, if set, reports reads that miss a committed write; see
+	// WithReadYourWritesCheck.
+	checkReads func(StaleRead)
+}
+
+// Option configures a MemVFS created by New.
+type Option func(*MemVFS)
+
+// WithCopyOnRead makes v never share its internal buffers: GetFile returns
+// a private copy, and ReadAt copies pages into SQLite's buffer under the
+// store lock rather than after releasing it. It costs a copy per GetFile and
+// some lock contention on reads, in exchange for containing a caller or
+// driver that holds on to a slice longer than it should.
+func WithCopyOnRead() Option {
+	return func(v *MemVFS) {
+		v.copyOnRead = true
+	}
+}
+
+// WithLazyCreate restores the behavior of earlier versions, where Open
+// created a missing file even without OpenCreate, so that a read-only
+// connection to a database that does not exist yet sees an empty one rather
+// than failing with SQLITE_CANTOPEN. It is meant for callers that relied on
+// that; OpenExclusive is still honored.
+func WithLazyCreate() Option {
+	return func(v *MemVFS) {
+		v.lazyCreate = true
+	}
+}
+
+// WithDeleteOnAnyClose restores the behavior of earlier versions, where
+// closing any handle on a file deleted it, even while other connections
+// still had it open. By default a file is only freed when its last handle
+// closes.
+func WithDeleteOnAnyClose() Option {
+	return func(v *MemVFS) {
+		v.deleteOnAnyClose = true
+	}
+}
+
+// WithRetainOnClose keeps files after their last handle closes, so that a
+// database outlives its connections until it is deleted with Batch, the
+// way a file on disk would. SQLite's temporary files are still freed.
+func WithRetainOnClose() Option {
+	return func(v *MemVFS) {
+		v.retainOnClose = true
+	}
+}
+
+// WithSectorSize sets the sector size reported to SQLite, 512 by default,
+// which is the least unit it assumes a write can tear. SQLite pads its
+// journal records to it.
+func WithSectorSize(size int64) Option {
+	return func(v *MemVFS) {
+		v.sectorSize = size
+	}
+}
+
+// WithDeviceCharacteristics sets the SQLITE_IOCAP_* flags reported to
+// SQLite, none by default. They are promises about the storage that SQLite
+// relies on to skip work: sqlite3vfs.IocapSequential, for instance, lets it
+// omit the syncs that only order writes, and
+// sqlite3vfs.IocapPowersafeOverwrite spares it journaling whole sectors
+// around the pages it changes.
+func WithDeviceCharacteristics(dc sqlite3vfs.DeviceCharacteristic) Option {
+	return func(v *MemVFS) {
+		v.characteristics = dc
+	}
+}
+
+// entry is the stored state of a single named file, shared by every handle
+// opened on it.
+type entry struct {
+	// mu guards the entry together with a read lock on v.mu, so that IO on
+	// different files does not contend; holding v.mu alone also guards
+	// every entry. Reads of the file share it; see rlockFile.
+	mu sync.RWMutex
+
+	// uuid is the file's identity; see UUID.
+	uuid UUID
+
+	data  []byte
+	flags sqlite3vfs.OpenFlag
+	role  Role
+	io    ioCounters
+
+	// owner is the main database a journal or WAL belongs to. sideIO and
+	// sideFiles accumulate the IO of those short-lived files on the owner.
+	owner     *entry
+	sideIO    ioCounters
+	sideFiles int64
+
+	// txWritten are the ranges of a main database written by the current
+	// transaction, and committed sums them over past transactions; see
+	// FileInfo.WriteAmplification.
+	txWritten spans
+	committed int64
+
+	// written is the version last stored on sync, see WithWriteThrough,
+	// and flushed the version last flushed, see Flush. storing serializes
+	// the stores on sync, which run without the file locked.
+	written atomic.Uint64
+	storing sync.Mutex
+	flushed uint64
+
+	// lastCommit is the last transaction committed to a main database and
+	// commitSeq numbers them; see WithReadYourWritesCheck.
+	lastCommit *lastCommit
+	commitSeq  uint64
+
+	// handles are the open handles on the file. While frozen is non-empty no
+	// handle may take RESERVED; unlocked is closed whenever a handle lowers
+	// its lock so that waiters can check again.
+	handles  map[*MemFile]struct{}
+	frozen   []*freezeGroup
+	unlocked chan struct{}
+
+	// version is bumped on every modification of data, which happened at
+	// modTime.
+	version uint64
+	modTime time.Time
+
+	// opens and closes count the handles opened and closed on the file since
+	// firstOpen; see churn.
+	opens, closes int64
+	firstOpen     time.Time
+
+	// lastClose is when the file's last handle closed; see IdleFiles.
+	lastClose time.Time
+
+	// unsynced are the ranges written since the file was last synced, and
+	// undo their synced contents; see WithCrashSimulation.
+	unsynced spans
+	undo     *undoLog
+
+	// readOnly files reject writes. retain files outlive their handles
+	// instead of being freed when the last one closes. expired files are
+	// freed by their last close even WithRetainOnClose; see CreateEphemeral.
+	readOnly bool
+	retain   bool
+	expired  bool
+
+	// src, if set, serves the file's contents in place of data.
+	src source
+
+	// disk, if set, holds the contents of a temporary file spilled out of
+	// memory in place of data; see WithTempBudget. cold is set if the file
+	// was moved there by tiering instead; see StorageCold.
+	disk     *os.File
+	diskSize int64
+	cold     bool
+
+	// charged is the part of the store's memory usage attributed to data;
+	// see WithMemoryBudget.
+	charged int64
+
+	// growth is the size history of the file; see Forecast.
+	growth growth
+
+	// degraded is set when an operation on the file ran past the IO
+	// deadline; see WithIODeadline.
+	degraded bool
+	breaker  breaker
+
+	// guarded is set while canary bytes follow data; see setGuard.
+	guarded bool
+
+	// exports are the buffers WriteFileTo is writing out without holding
+	// the lock; see own.
+	exports [][]byte
+
+	// ops logs the file's last operations; see RecentOps.
+	ops *opRing
+
+	// pins are the ranges kept hot with Pin.
+	pins []*pin
+
+	// compressed holds the contents, of rawSize bytes, in place of data
+	// while the file is warm; see StorageWarm. compressTried is version+1
+	// once compressing this version did not pay off.
+	compressed    []byte
+	rawSize       int64
+	compressTried uint64
+
+	// fixedClass is the storage class set with SetStorageClass.
+	fixedClass StorageClass
+}
+
+// source serves the contents of a read-only file held outside the store,
+// such as a shared-memory segment.
+type source interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+
+	// Pin keeps the contents stable until Unpin. Handles pin the source
+	// while they hold a SHARED lock or higher.
+	Pin() error
+	Unpin()
+}
+
+// writableSource is a source that takes writes, keeping them apart from
+// the contents it serves from outside the store.
+type writableSource interface {
+	source
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// modified records a change to data.
+func (e *entry) modified() {
+	e.version++
+	e.modTime = time.Now()
+	e.growth.record(e.modTime, e.size())
+	e.setGuard()
+}
+
+// size returns the length of the file's contents.
+func (e *entry) size() int64 {
+	if e.src != nil {
+		return e.src.Size()
+	}
+	if e.disk != nil {
+		return e.diskSize
+	}
+	if e.compressed != nil {
+		return e.rawSize
+	}
+	return int64(len(e.data))
+}
+
+// reader returns what serves the file's contents when they are not held in
+// data, or nil.
+func (e *entry) reader() io.ReaderAt {
+	if e.src != nil {
+		return e.src
+	}
+	if e.disk != nil {
+		return e.disk
+	}
+	return nil
+}
+
+// locked reports whether any handle holds lockType or higher.
+func (e *entry) locked(lockType sqlite3vfs.LockType) bool {
+	for f := range e.handles {
+		if f.lockLevel >= lockType {
+			return true
+		}
+	}
+	return false
 }
 
 type MemFile struct {
+	id        HandleID
 	store     *MemVFS
 	fileName  string
+	flags     sqlite3vfs.OpenFlag
 	lockLevel sqlite3vfs.LockType
 	mu        sync.Mutex
+
+	// revoked handles were cut off by Drain and fail all IO.
+	revoked bool
+
+	// ops is the operation log of the handle's file.
+	ops *opRing
+
+	// label and labelIO attribute the handle's IO to an application label;
+	// see Annotate. Guarded by mu.
+	label   string
+	labelIO *ioCounters
+
+	// pending holds main database writes not yet published to the entry;
+	// see buffers. Guarded by mu.
+	pending      []pendingWrite
+	pendingBytes int64
+	pendingEnd   int64
+
+	// readSince is the commitSeq of the file when f last took SHARED; see
+	// WithReadYourWritesCheck. Guarded by the store's mu.
+	readSince uint64
 }
 
-func New() *MemVFS {
-	return &MemVFS{
-		files: make(map[string][]byte),
+func New(opts ...Option) *MemVFS {
+	v := &MemVFS{
+		files:           make(map[string]*entry),
+		breakerFailures: DefaultBreakerFailures,
+		breakerCooldown: DefaultBreakerCooldown,
+		sampler: logSampler{
+			tick:       DefaultLogSampleTick,
+			first:      DefaultLogSampleFirst,
+			thereafter: DefaultLogSampleThereafter,
+		},
+	}
+	for _, opt := range opts {
+		opt(v)
 	}
+	return v
 }
 
-// getFile returns an existing []byte for the given fileName
-// or creates a new zero-length slice if it doesn’t exist yet.
-func (v *MemVFS) getFile(fileName string) []byte {
-	v.mu.Lock()
-	defer v.mu.Unlock()
-
-	data, ok := v.files[fileName]
+// lookup returns the entry for fileName, creating an empty one opened with
+// flags if it doesn’t exist yet. v.mu must be held.
+func (v *MemVFS) lookup(fileName string, flags sqlite3vfs.OpenFlag) *entry {
+	e, ok := v.files[fileName]
 	if !ok {
-		data = []byte{}
-		v.files[fileName] = data
+		e = &entry{
+			uuid:    newUUID(),
+			data:    []byte{},
+			flags:   flags,
+			role:    roleFromFlags(flags),
+			handles: make(map[*MemFile]struct{}),
+			modTime: time.Now(),
+		}
+		if owner, ok := v.files[ownerName(fileName, e.role)]; ok && owner != e {
+			e.owner = owner
+			owner.sideFiles++
+		}
+		v.files[fileName] = e
 	}
-	return data
+	return e
 }
 
+// lockEntry locks e for an operation on its contents alone. Holders of
+// v.mu are excluded by the read lock, other handles on the file by e.mu.
+func (v *MemVFS) lockEntry(e *entry) {
+	v.mu.RLock()
+	e.mu.Lock()
+}
+
+func (v *MemVFS) unlockEntry(e *entry) {
+	e.mu.Unlock()
+	v.mu.RUnlock()
+}
+
+// lockFile returns the entry of f's file, creating it if needed, locked
+// with lockEntry. It returns nil, with nothing locked, if f was revoked.
+func (v *MemVFS) lockFile(f *MemFile) *entry {
+	return v.findFile(f, (*sync.RWMutex).Lock)
+}
+
+// rlockFile is lockFile for operations that only read the entry, which
+// locks it shared, so that connections reading one file do not wait for
+// each other. Release it with runlockEntry.
+func (v *MemVFS) rlockFile(f *MemFile) *entry {
+	return v.findFile(f, (*sync.RWMutex).RLock)
+}
+
+func (v *MemVFS) runlockEntry(e *entry) {
+	e.mu.RUnlock()
+	v.mu.RUnlock()
+}
+
+// findFile returns the entry of f's file, creating it if needed, with v.mu
+// read-locked and the entry's mu locked by lock.
+func (v *MemVFS) findFile(f *MemFile, lock func(*sync.RWMutex)) *entry {
+	v.mu.RLock()
+	for {
+		if f.revoked {
+			v.mu.RUnlock()
+			return nil
+		}
+		if e, ok := v.files[f.fileName]; ok {
+			lock(&e.mu)
+			return e
+		}
+		v.mu.RUnlock()
+		v.mu.Lock()
+		v.lookup(f.fileName, f.flags)
+		v.mu.Unlock()
+		v.mu.RLock()
+	}
+}
+
+// GetFile returns the contents of fileName. Unless v was created with
+// WithCopyOnRead, the slice of an in-memory file is the file's own buffer
+// and must not be modified or kept across writes.
 func (v *MemVFS) GetFile(fileName string) ([]byte, error) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
-	data, ok := v.files[fileName]
+	e, ok := v.files[fileName]
 	if !ok {
-		return nil, errors.New("file not found in memvfs")
+		return nil, ErrNotFound
+	}
+	data, err := v.contents(e)
+	if err != nil {
+		return nil, err
+	}
+	if v.copyOnRead && e.reader() == nil {
+		return bytes.Clone(data), nil
 	}
-
 	return data, nil
 }
 
-func (f *MemFile) ReadAt(p []byte, off int64) (int, error) {
+// contents returns the contents of e: its own buffer if it is held in
+// memory, or a fresh copy read through its source, such as the plaintext
+// of an encrypted file. v.mu must be held exclusively.
+func (v *MemVFS) contents(e *entry) ([]byte, error) {
+	if err := v.thaw(e); err != nil {
+		return nil, err
+	}
+	src := e.reader()
+	if src == nil {
+		return e.data, nil
+	}
+	data := make([]byte, e.size())
+	n, err := src.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// PutFile stores a copy of data, such as a serialized database fetched from
+// object storage, as the main database fileName; see AdminTx.Put, which it
+// runs in a Batch of its own once the validators set with WithValidators
+// accept data. ReadFileFrom does the same from an io.Reader. Like a file
+// created through SQLite, it is freed when the last connection to it closes
+// unless v was created WithRetainOnClose.
+func (v *MemVFS) PutFile(fileName string, data []byte) error {
+	if err := v.Validate(fileName, data); err != nil {
+		return err
+	}
+	return v.Batch(func(tx *AdminTx) error {
+		return tx.Put(fileName, data)
+	})
+}
+
+// Rename atomically moves oldName to newName, replacing newName, so that a
+// database prepared under a staging name can be promoted in place of the
+// live one without copying it; see AdminTx.Rename, which it runs in a
+// Batch of its own.
+func (v *MemVFS) Rename(oldName, newName string) error {
+	return v.Batch(func(tx *AdminTx) error {
+		return tx.Rename(oldName, newName)
+	})
+}
+
+// ReadAt reads from the file. Reads within the file's bounds do not
+// allocate; TestReadAtAllocs holds it to that.
+func (f *MemFile) ReadAt(p []byte, off int64) (n int, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpRead, Handle: f.id, Offset: off, Length: int64(len(p))}, &err)
+
+	if err := f.store.simulateRead(f); err != nil {
+		return 0, err
+	}
+	if hook := f.store.hooks.OnRead; hook != nil {
+		if err := hook(f.fileName, off, len(p)); err != nil {
+			return 0, err
+		}
+	}
 
-	data := f.store.getFile(f.fileName)
+	v := f.store
+	// The writer only reads back its own pages when SQLite spills its
+	// cache mid-transaction; publishing is simpler than overlaying. Every
+	// other read leaves the entry alone and shares it.
+	lock, unlock := v.rlockFile, v.runlockEntry
+	if len(f.pending) > 0 {
+		lock, unlock = v.lockFile, v.unlockEntry
+	}
+	e := lock(f)
+	if e == nil {
+		return 0, sqlite3vfs.IOError
+	}
+	if err := e.checkGuard(f.fileName); err != nil {
+		unlock(e)
+		panic(err)
+	}
+	f.publish(e)
+	if err := v.checkRead(f, e, off, len(p)); err != nil {
+		unlock(e)
+		return 0, sqlite3vfs.IOError
+	}
+	v.record(f, e, off, len(p), false)
+	data := e.data
+	src := e.reader()
+	if v.copyOnRead && src == nil {
+		// Copy while still holding the lock, so a write racing outside
+		// SQLite's locking protocol cannot tear the page.
+		n, err := readData(data, p, off)
+		unlock(e)
+		v.countRead(f, e, len(p))
+		return n, err
+	}
+	if src == nil {
+		e.beginRead(f, off, off+int64(len(p)))
+		defer e.endRead(f)
+	}
+	unlock(e)
+	v.countRead(f, e, len(p))
+
+	if src != nil {
+		n, err := v.readBackend(f, e, src, p, off)
+		if n < len(p) {
+			for i := n; i < len(p); i++ {
+				p[i] = 0
+			}
+			if err == nil || err == io.EOF {
+				err = sqlite3vfs.IOErrorShortRead
+			}
+		}
+		return len(p), err
+	}
+
+	return readData(data, p, off)
+}
+
+// readData reads p from data at off, zero-filling past its end.
+func readData(data, p []byte, off int64) (int, error) {
 	fileLen := int64(len(data))
 
 	// If xRead() returns SQLITE_IOERR_SHORT_READ it must also fill in the
@@ -86,15 +669,37 @@ func (f *MemFile) ReadAt(p []byte, off int64) (int, error) {
 	return len(p), nil
 }
 
-func (f *MemFile) WriteAt(p []byte, off int64) (int, error) {
+func (f *MemFile) WriteAt(p []byte, off int64) (n int, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpWrite, Handle: f.id, Offset: off, Length: int64(len(p))}, &err)
+
+	if err := f.store.simulateWrite(f); err != nil {
+		return 0, err
+	}
+	if hook := f.store.hooks.OnWrite; hook != nil {
+		if err := hook(f.fileName, off, p); err != nil {
+			return 0, err
+		}
+	}
 
 	v := f.store
-	v.mu.Lock()
-	defer v.mu.Unlock()
+	e := v.lockFile(f)
+	if e == nil {
+		return 0, sqlite3vfs.IOError
+	}
+	defer v.unlockEntry(e)
+	defer v.account(e)
 
-	data := v.files[f.fileName]
+	if err := e.checkGuard(f.fileName); err != nil {
+		// The deferred unlocks release e on the way out.
+		panic(err)
+	}
+	if v.readOnlyWrite(e) || f.readOnly() {
+		return 0, sqlite3vfs.ReadOnlyError
+	}
+	data := e.data
 	oldLen := int64(len(data))
 	newEnd := off + int64(len(p))
 
@@ -102,42 +707,168 @@ func (f *MemFile) WriteAt(p []byte, off int64) (int, error) {
 		return 0, errors.New("negative offset + length")
 	}
 
+	e.unsynced = e.unsynced.add(off, newEnd)
+	if e.role == RoleMainDB {
+		e.txWritten = e.txWritten.add(off, newEnd)
+	}
+	v.record(f, e, off, len(p), true)
+	if f.buffers(e) {
+		f.buffer(p, off)
+		if f.pendingBytes > maxPendingBytes {
+			f.publish(e)
+		}
+		v.countWrite(f, e, len(p))
+		return len(p), nil
+	}
+
+	if v.overBudget(e, f.fileName, newEnd) {
+		return 0, sqlite3vfs.FullError
+	}
+	if w, ok := e.src.(writableSource); ok {
+		e.modified()
+		v.countWrite(f, e, len(p))
+		return w.WriteAt(p, off)
+	}
+	if e.disk != nil {
+		e.modified()
+		v.countWrite(f, e, len(p))
+		return e.writeDisk(p, off)
+	}
+	v.saveUndo(e, off, newEnd)
 	if newEnd > oldLen {
 		newData := make([]byte, newEnd)
 		copy(newData, data)
 		copy(newData[off:], p)
 
-		v.files[f.fileName] = newData
+		e.data = newData
 	} else {
+		e.mutating(f, off, newEnd)
 		copy(data[off:], p)
-		v.files[f.fileName] = data
 	}
+	e.modified()
+	v.countWrite(f, e, len(p))
 
 	return len(p), nil
 }
 
-func (f *MemFile) Truncate(size int64) error {
+// readOnly reports whether f was opened without write access.
+func (f *MemFile) readOnly() bool {
+	return f.flags&sqlite3vfs.OpenReadOnly != 0
+}
+
+func (f *MemFile) Truncate(size int64) (err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpTruncate, Handle: f.id, Offset: size}, &err)
 
 	v := f.store
-	v.mu.Lock()
-	defer v.mu.Unlock()
+	if err := v.simulateTruncate(f); err != nil {
+		return err
+	}
+	if v.hooks.OnTruncate != nil {
+		if err := v.hooks.OnTruncate(f.fileName, size); err != nil {
+			return err
+		}
+	}
+	e := v.lockFile(f)
+	if e == nil {
+		return sqlite3vfs.IOError
+	}
+	defer v.unlockEntry(e)
+	defer v.account(e)
 
-	data := v.files[f.fileName]
+	if e.readOnly || f.readOnly() {
+		return sqlite3vfs.ReadOnlyError
+	}
+	f.publish(e)
+	e.unsynced = e.unsynced.clip(size)
+	e.txWritten = e.txWritten.clip(size)
+	if v.overBudget(e, f.fileName, size) {
+		return sqlite3vfs.FullError
+	}
+	if w, ok := e.src.(writableSource); ok {
+		e.modified()
+		v.countTruncate(f, e)
+		return w.Truncate(size)
+	}
+	if e.disk != nil {
+		e.modified()
+		v.countTruncate(f, e)
+		return e.truncateDisk(size)
+	}
+	if v.overMemory(e, size) {
+		return sqlite3vfs.FullError
+	}
+	v.saveUndo(e, size, int64(len(e.data)))
+	data := e.data
 	currentLen := int64(len(data))
 
 	if size < currentLen {
-		v.files[f.fileName] = data[:size]
+		e.data = data[:size]
 	} else if size > currentLen {
 		newData := make([]byte, size)
 		copy(newData, data)
-		v.files[f.fileName] = newData
+		e.data = newData
 	}
+	e.modified()
+	v.countTruncate(f, e)
 	return nil
 }
 
-func (f *MemFile) Sync(flags sqlite3vfs.SyncType) error {
+// Sync publishes the writes the handle has buffered.
+func (f *MemFile) Sync(flags sqlite3vfs.SyncType) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpSync, Handle: f.id}, &err)
+
+	if err := f.store.simulateSync(f); err != nil {
+		return err
+	}
+
+	v := f.store
+	v.mu.RLock()
+	e, ok := v.files[f.fileName]
+	if !ok || f.revoked {
+		v.mu.RUnlock()
+		return nil
+	}
+	e.mu.Lock()
+	if v.syncOverMemory(f, e) {
+		// The transaction rolls back, rewriting the pages it changed, so
+		// what it buffered can go.
+		f.discard()
+		e.mu.Unlock()
+		v.mu.RUnlock()
+		return sqlite3vfs.FullError
+	}
+	f.publish(e)
+	e.unsynced = nil
+	e.undo = nil
+	v.recordCommit(f, e)
+	e.commitWrites()
+	v.countSync(f, e)
+	var syncErr error
+	if s, ok := e.src.(syncer); ok {
+		syncErr = s.sync()
+	}
+	image, version, store := v.writeThroughImage(e)
+	e.mu.Unlock()
+	v.mu.RUnlock()
+
+	if syncErr != nil {
+		v.log(slog.LevelError, "write back failed", "file", v.LogName(f.fileName), "error", syncErr)
+		return sqlite3vfs.IOError
+	}
+	if store {
+		// The commit still waits for the store, but other connections
+		// no longer wait with it.
+		if err := v.writeThrough(f.fileName, e, image, version); err != nil {
+			v.log(slog.LevelError, "write-through failed", "file", v.LogName(f.fileName), "error", err)
+			return sqlite3vfs.IOError
+		}
+	}
 	return nil
 }
 
@@ -145,60 +876,301 @@ func (f *MemFile) FileSize() (int64, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	v := f.store
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	e, ok := v.files[f.fileName]
+	if !ok {
+		return 0, nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return max(e.size(), f.pendingEnd), nil
+}
+
+func (f *MemFile) Lock(lockType sqlite3vfs.LockType) (err error) {
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpLock, Handle: f.id, Lock: lockType}, &err)
 	v := f.store
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
-	data := v.files[f.fileName]
-	return int64(len(data)), nil
+	if f.revoked {
+		return sqlite3vfs.IOError
+	}
+	if f.lockLevel >= lockType {
+		return nil
+	}
+
+	e := v.lookup(f.fileName, f.flags)
+	e.handles[f] = struct{}{}
+	v.watchLock(f, lockType)
+	if v.readOnlyLock(f, lockType) {
+		return sqlite3vfs.ReadOnlyError
+	}
+	if f.lockLevel < sqlite3vfs.LockReserved && lockType >= sqlite3vfs.LockReserved {
+		if e.freezeBlocks() {
+			return sqlite3vfs.BusyError
+		}
+		if v.overMemoryBy(e, 0) {
+			return sqlite3vfs.FullError
+		}
+	}
+	granted, err := e.arbitrate(f, lockType)
+	if err != nil {
+		v.logSampled(slog.LevelDebug, "lock busy", "file", v.LogName(f.fileName), "handle", f.id, "lock", lockType)
+	}
+	if granted == f.lockLevel {
+		return err
+	}
+	if f.lockLevel == sqlite3vfs.LockNone && e.src != nil {
+		if err := e.src.Pin(); err != nil {
+			return err
+		}
+	}
+	if f.lockLevel == sqlite3vfs.LockNone {
+		f.readSince = e.commitSeq
+	}
+	f.lockLevel = granted
+	return err
 }
 
-func (f *MemFile) Lock(lockType sqlite3vfs.LockType) error {
-	if f.lockLevel < lockType {
-		f.lockLevel = lockType
+// arbitrate returns the lock f may take on e toward lockType, following
+// SQLite's locking protocol across every handle on the file: SHARED is
+// refused while another handle holds PENDING or above, only one handle holds
+// RESERVED or above, and EXCLUSIVE waits for the other SHARED holders to
+// leave, keeping PENDING meanwhile so no new ones arrive. The error is
+// SQLITE_BUSY if lockType was not granted in full. v.mu must be held.
+//
+// https://www.sqlite.org/lockingv3.html
+func (e *entry) arbitrate(f *MemFile, lockType sqlite3vfs.LockType) (sqlite3vfs.LockType, error) {
+	highest, shared := sqlite3vfs.LockNone, false
+	for h := range e.handles {
+		if h != f {
+			highest = max(highest, h.lockLevel)
+			shared = shared || h.lockLevel >= sqlite3vfs.LockShared
+		}
 	}
-	return nil
+
+	switch {
+	case lockType == sqlite3vfs.LockShared && highest >= sqlite3vfs.LockPending:
+		return f.lockLevel, sqlite3vfs.BusyError
+	case lockType > sqlite3vfs.LockShared && f.lockLevel < sqlite3vfs.LockReserved && highest >= sqlite3vfs.LockReserved:
+		return f.lockLevel, sqlite3vfs.BusyError
+	case lockType == sqlite3vfs.LockExclusive && shared:
+		return sqlite3vfs.LockPending, sqlite3vfs.BusyError
+	}
+	return lockType, nil
 }
 
-func (f *MemFile) Unlock(lockType sqlite3vfs.LockType) error {
+// Unlock publishes any writes still buffered, which with synchronous=OFF are
+// never synced.
+func (f *MemFile) Unlock(lockType sqlite3vfs.LockType) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpUnlock, Handle: f.id, Lock: lockType}, &err)
+
+	v := f.store
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if f.revoked {
+		return nil
+	}
+	if e, ok := v.files[f.fileName]; ok && lockType < f.lockLevel {
+		if lockType < sqlite3vfs.LockReserved {
+			f.publish(e)
+			v.recordCommit(f, e)
+			e.commitWrites()
+		}
+		if e.unlocked != nil {
+			close(e.unlocked)
+			e.unlocked = nil
+		}
+		if lockType == sqlite3vfs.LockNone && e.src != nil {
+			e.src.Unpin()
+		}
+	}
 	f.lockLevel = lockType
 	return nil
 }
 
+// CheckReservedLock reports whether any handle on the file holds RESERVED or
+// above.
 func (f *MemFile) CheckReservedLock() (bool, error) {
-	return f.lockLevel >= sqlite3vfs.LockReserved, nil
+	v := f.store
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if f.lockLevel >= sqlite3vfs.LockReserved {
+		return true, nil
+	}
+	if e, ok := v.files[f.fileName]; ok {
+		for h := range e.handles {
+			if h.lockLevel >= sqlite3vfs.LockReserved {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
 }
 
 func (f *MemFile) SectorSize() int64 {
+	if f.store.sectorSize > 0 {
+		return f.store.sectorSize
+	}
 	return 512
 }
 
 func (f *MemFile) DeviceCharacteristics() sqlite3vfs.DeviceCharacteristic {
-	return 0
+	return f.store.characteristics
 }
 
 // Close guarantees that the buffer is freed on db.Close() in consistency with
 // in-memory sqlite db behavior.
-func (f *MemFile) Close() error {
-	return f.store.Delete(f.fileName, true)
+func (f *MemFile) Close() (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer translateErr(&err)
+	defer f.ops.log(Op{Kind: OpClose, Handle: f.id}, &err)
+
+	v := f.store
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if f.revoked {
+		return nil
+	}
+	if v.closed != nil {
+		close(v.closed)
+		v.closed = nil
+	}
+	if e, ok := v.files[f.fileName]; ok {
+		f.publish(e)
+		delete(e.handles, f)
+		e.closes++
+		if len(e.handles) == 0 {
+			e.lastClose = time.Now()
+		}
+		if len(e.handles) > 0 && !v.deleteOnAnyClose {
+			return nil
+		}
+		if e.retain || v.retainOnClose && !e.temporary() && !e.expired {
+			v.evictSoon()
+			return nil
+		}
+		if err := e.release(); err != nil {
+			v.log(slog.LevelError, "releasing closed file failed", "file", v.LogName(f.fileName), "error", err)
+		}
+		v.forget(e)
+	}
+	delete(v.files, f.fileName)
+	return nil
 }
 
 func (v *MemVFS) FullPathname(name string) string {
 	return name
 }
 
-func (v *MemVFS) Open(name string, flags sqlite3vfs.OpenFlag) (sqlite3vfs.File, sqlite3vfs.OpenFlag, error) {
-	return &MemFile{
+// Open returns a handle on name. A missing file is created only if flags
+// include OpenCreate, or v was created WithLazyCreate, and
+// OpenExclusive|OpenCreate fails if the file already exists. Handles opened
+// with OpenReadOnly reject writes. SQLite passes an empty name for temporary
+// files (sort spills, temp databases), so those get a unique generated name
+// to keep them apart.
+func (v *MemVFS) Open(name string, flags sqlite3vfs.OpenFlag) (_ sqlite3vfs.File, _ sqlite3vfs.OpenFlag, err error) {
+	defer translateErr(&err)
+	if v.hooks.OnOpen != nil {
+		if err := v.hooks.OnOpen(name, flags); err != nil {
+			return nil, 0, err
+		}
+	}
+	stored, found, err := v.load(name, flags)
+	if err != nil {
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.draining {
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+	if name == "" {
+		v.tempSeq++
+		name = fmt.Sprintf("memvfs-temp-%d", v.tempSeq)
+	}
+	_, exists := v.files[name]
+	if !exists && found {
+		v.hydrate(name, flags, stored)
+		exists = true
+	}
+	switch {
+	case !exists && v.backed(flags):
+		if err := v.openBacked(name, flags); err != nil {
+			return nil, 0, err
+		}
+	case !exists && flags&sqlite3vfs.OpenCreate == 0 && !v.lazyCreate:
+		return nil, 0, sqlite3vfs.CantOpenError
+	case exists && flags&(sqlite3vfs.OpenExclusive|sqlite3vfs.OpenCreate) == sqlite3vfs.OpenExclusive|sqlite3vfs.OpenCreate:
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+	e := v.lookup(name, flags)
+	if err := v.thaw(e); err != nil {
+		return nil, 0, err
+	}
+	if err := v.seal(e); err != nil {
+		return nil, 0, err
+	}
+	v.handleSeq++
+	f := &MemFile{
+		id:       HandleID(v.handleSeq),
 		store:    v,
 		fileName: name,
-	}, flags, nil
+		flags:    flags,
+		ops:      e.opRing(),
+	}
+	e.handles[f] = struct{}{}
+	e.opened()
+	f.ops.log(Op{Kind: OpOpen, Handle: f.id}, nil)
+
+	if e.readOnly {
+		flags = flags&^(sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate) | sqlite3vfs.OpenReadOnly
+	}
+	return f, flags, nil
 }
 
-func (v *MemVFS) Delete(name string, syncDir bool) error {
+func (v *MemVFS) Delete(name string, syncDir bool) (err error) {
+	defer translateErr(&err)
+	if v.hooks.OnDelete != nil {
+		if err := v.hooks.OnDelete(name); err != nil {
+			return err
+		}
+	}
 	v.mu.Lock()
-	defer v.mu.Unlock()
-
+	e, ok := v.files[name]
+	backed, stored := !ok, !ok && !sideFile(name)
+	if ok {
+		_, backed = e.src.(*cacheSource)
+		stored = e.role == RoleMainDB
+		if err := e.release(); err != nil {
+			v.log(slog.LevelError, "releasing deleted file failed", "file", v.LogName(name), "error", err)
+		}
+		v.forget(e)
+	}
 	delete(v.files, name)
+	v.mu.Unlock()
+
+	if stored && v.store != nil {
+		if err := v.store.Delete(context.Background(), name); err != nil {
+			v.log(slog.LevelError, "deleting from backing store failed", "file", v.LogName(name), "error", err)
+			return err
+		}
+	}
+	if backed && v.backing != nil {
+		return v.backing.Delete(name, syncDir)
+	}
 	return nil
 }
 
@@ -213,6 +1185,9 @@ func (v *MemVFS) Access(name string, flag sqlite3vfs.AccessFlag) (bool, error) {
 	defer v.mu.Unlock()
 
 	_, ok := v.files[name]
+	if !ok && v.backing != nil {
+		return v.backing.Access(name, flag)
+	}
 	return ok, nil
 }
 
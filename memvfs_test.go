@@ -1,13 +1,16 @@
 package memvfs_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/hleng1/memvfs"
@@ -20,7 +23,7 @@ var v *memvfs.MemVFS
 func TestMain(m *testing.M) {
 	v = memvfs.New()
 
-	if err := sqlite3vfs.RegisterVFS("memvfs", v); err != nil {
+	if err := v.Register("memvfs"); err != nil {
 		log.Fatalf("Failed to register VFS: %v", err)
 	}
 
@@ -86,6 +89,42 @@ func TestMemVFS(t *testing.T) {
 	}
 }
 
+func TestPutFile(t *testing.T) {
+	src, err := sql.Open("sqlite3", "file:test-putfile-src.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY); INSERT INTO demo VALUES (1), (2)`); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	image, err := v.GetFile("test-putfile-src.db")
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+
+	if err := v.PutFile("test-putfile.db", image); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:test-putfile.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("Expected 2 rows in the preloaded database, got %d, %v", n, err)
+	}
+	if err := v.PutFile("test-putfile.db", image); !errors.Is(err, memvfs.ErrBusy) {
+		t.Errorf("Expected ErrBusy replacing a database in use, got %v", err)
+	}
+}
+
 func TestConcurrentSingleDB(t *testing.T) {
 	const (
 		goroutineCount = 10
@@ -243,6 +282,346 @@ func TestStressInsertion(t *testing.T) {
 	}
 }
 
+func TestReadAtAllocs(t *testing.T) {
+	v := memvfs.New()
+	f, _, err := v.Open("test-readat-allocs.db", sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(make([]byte, 64*1024), 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	f.ReadAt(buf, 0)
+	allocs := testing.AllocsPerRun(1000, func() {
+		f.ReadAt(buf, 8192)
+	})
+	if allocs != 0 {
+		t.Errorf("In-bounds ReadAt allocates %v times per call", allocs)
+	}
+}
+
+func TestOpenFlags(t *testing.T) {
+	v := memvfs.New()
+	name := "test-open-flags.db"
+	if _, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite); err != sqlite3vfs.CantOpenError {
+		t.Errorf("Expected SQLITE_CANTOPEN for a missing file without OpenCreate, got %v", err)
+	}
+
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate | sqlite3vfs.OpenExclusive
+	f, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, _, err := v.Open(name, flags); err != sqlite3vfs.CantOpenError {
+		t.Errorf("Expected SQLITE_CANTOPEN for an exclusive create of an existing file, got %v", err)
+	}
+	if _, err := f.WriteAt([]byte("data"), 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+
+	ro, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadOnly)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer ro.Close()
+	if _, err := ro.WriteAt([]byte("x"), 0); err != sqlite3vfs.ReadOnlyError {
+		t.Errorf("Expected SQLITE_READONLY writing a read-only handle, got %v", err)
+	}
+	if err := ro.Truncate(0); err != sqlite3vfs.ReadOnlyError {
+		t.Errorf("Expected SQLITE_READONLY truncating a read-only handle, got %v", err)
+	}
+	p := make([]byte, 4)
+	if _, err := ro.ReadAt(p, 0); err != nil || string(p) != "data" {
+		t.Errorf("Expected read-only handle to read %q, got %q (%v)", "data", p, err)
+	}
+
+	lv := memvfs.New(memvfs.WithLazyCreate())
+	lf, _, err := lv.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadOnly)
+	if err != nil {
+		t.Fatalf("Expected WithLazyCreate to create the missing file, got %v", err)
+	}
+	lf.Close()
+}
+
+func TestCloseLastHandle(t *testing.T) {
+	for _, eager := range []bool{false, true} {
+		var opts []memvfs.Option
+		if eager {
+			opts = append(opts, memvfs.WithDeleteOnAnyClose())
+		}
+		v := memvfs.New(opts...)
+		name := "test-close-last.db"
+		flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+		a, _, err := v.Open(name, flags)
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		b, _, err := v.Open(name, flags)
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		if _, err := a.WriteAt([]byte("data"), 0); err != nil {
+			t.Fatalf("WriteAt error: %v", err)
+		}
+		a.Close()
+		if _, err := v.Stat(name); (err == nil) == eager {
+			t.Errorf("Expected the file to exist after closing one of two handles unless deleted on any close (eager %v), got %v", eager, err)
+		}
+		if !eager {
+			p := make([]byte, 4)
+			if _, err := b.ReadAt(p, 0); err != nil || string(p) != "data" {
+				t.Errorf("Expected the remaining handle to read %q, got %q (%v)", "data", p, err)
+			}
+		}
+		b.Close()
+		if _, err := v.Stat(name); err != memvfs.ErrNotFound {
+			t.Errorf("Expected the file to be freed with its last handle, got %v", err)
+		}
+	}
+}
+
+func TestDeviceOptions(t *testing.T) {
+	dc := sqlite3vfs.IocapSequential | sqlite3vfs.IocapPowersafeOverwrite
+	v := memvfs.New(memvfs.WithSectorSize(4096), memvfs.WithDeviceCharacteristics(dc), memvfs.WithRetainOnClose(), memvfs.WithMaxMemory(1<<30))
+	if err := v.Register("memvfs-device-options"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	f, _, err := v.Open("test-device.db", sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if got := f.SectorSize(); got != 4096 {
+		t.Errorf("Expected sector size 4096, got %d", got)
+	}
+	if got := f.DeviceCharacteristics(); got != dc {
+		t.Errorf("Expected characteristics %#x, got %#x", dc, got)
+	}
+	if got := v.Stats().MemoryBudget; got != 1<<30 {
+		t.Errorf("Expected memory budget %d, got %d", 1<<30, got)
+	}
+	f.Close()
+	if _, err := v.Stat("test-device.db"); err != nil {
+		t.Errorf("Expected the file to be retained after its last close, got %v", err)
+	}
+
+	for i := range 2 {
+		db, err := v.OpenDB("test-retain.db", memvfs.ProfileNone)
+		if err != nil {
+			t.Fatalf("OpenDB error: %v", err)
+		}
+		if i == 0 {
+			if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY); INSERT INTO demo VALUES (1)`); err != nil {
+				t.Fatalf("Exec error: %v", err)
+			}
+		} else {
+			var n int
+			if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 1 {
+				t.Errorf("Expected the database to survive its connections, got %d rows, %v", n, err)
+			}
+		}
+		db.Close()
+	}
+}
+
+func TestLockConflicts(t *testing.T) {
+	v := memvfs.New()
+	name := "test-lock-conflicts.db"
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	a, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer a.Close()
+	b, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer b.Close()
+
+	for _, f := range []sqlite3vfs.File{a, b} {
+		if err := f.Lock(sqlite3vfs.LockShared); err != nil {
+			t.Fatalf("Shared lock error: %v", err)
+		}
+	}
+	if err := a.Lock(sqlite3vfs.LockReserved); err != nil {
+		t.Fatalf("Reserved lock error: %v", err)
+	}
+	if reserved, _ := b.CheckReservedLock(); !reserved {
+		t.Errorf("Expected other handle to see the reserved lock")
+	}
+	if err := b.Lock(sqlite3vfs.LockReserved); err != sqlite3vfs.BusyError {
+		t.Errorf("Expected SQLITE_BUSY for a second reserved lock, got %v", err)
+	}
+	if err := a.Lock(sqlite3vfs.LockExclusive); err != sqlite3vfs.BusyError {
+		t.Errorf("Expected SQLITE_BUSY for exclusive while another handle reads, got %v", err)
+	}
+
+	// a now holds PENDING, which keeps new readers out.
+	c, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer c.Close()
+	if err := c.Lock(sqlite3vfs.LockShared); err != sqlite3vfs.BusyError {
+		t.Errorf("Expected SQLITE_BUSY for shared while a writer is pending, got %v", err)
+	}
+
+	b.Unlock(sqlite3vfs.LockNone)
+	if err := a.Lock(sqlite3vfs.LockExclusive); err != nil {
+		t.Errorf("Exclusive lock error after reader left: %v", err)
+	}
+	a.Unlock(sqlite3vfs.LockNone)
+	if err := c.Lock(sqlite3vfs.LockShared); err != nil {
+		t.Errorf("Shared lock error after writer left: %v", err)
+	}
+}
+
+func TestWriteAtBuffered(t *testing.T) {
+	v := memvfs.New()
+	name := "test-writeat-buffered.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.Lock(sqlite3vfs.LockExclusive); err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	page := bytes.Repeat([]byte{'x'}, 4096)
+	f.WriteAt(page, 0)
+	f.WriteAt(page, 4096)
+
+	if data, _ := v.GetFile(name); len(data) != 0 {
+		t.Errorf("Expected writes to be buffered, file has %d bytes", len(data))
+	}
+	if size, _ := f.FileSize(); size != 8192 {
+		t.Errorf("Expected writer to see size 8192, got %d", size)
+	}
+
+	if err := f.Sync(sqlite3vfs.SyncNormal); err != nil {
+		t.Fatalf("Sync error: %v", err)
+	}
+	if data, _ := v.GetFile(name); !bytes.Equal(data, append(page, page...)) {
+		t.Errorf("Expected both pages after Sync, got %d bytes", len(data))
+	}
+
+	f.WriteAt([]byte("y"), 0)
+	if err := f.Unlock(sqlite3vfs.LockNone); err != nil {
+		t.Fatalf("Unlock error: %v", err)
+	}
+	if data, _ := v.GetFile(name); data[0] != 'y' {
+		t.Errorf("Expected Unlock to publish the last write")
+	}
+}
+
+func TestCopyOnRead(t *testing.T) {
+	v := memvfs.New(memvfs.WithCopyOnRead())
+	name := "test-copy-on-read.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+
+	data, _ := v.GetFile(name)
+	data[0] = 'j'
+
+	buf := make([]byte, 5)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt error: %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("Expected GetFile to return a copy, file now reads %q", buf)
+	}
+}
+
+func BenchmarkReadAt(b *testing.B) {
+	v := memvfs.New()
+	f, _, err := v.Open("bench-readat.db", sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		b.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(make([]byte, 1<<20), 0); err != nil {
+		b.Fatalf("WriteAt error: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.ReadAt(buf, int64(i%256)*4096)
+	}
+}
+
+// BenchmarkReadAtParallelFiles reads a separate file from each goroutine,
+// which should not contend.
+func BenchmarkReadAtParallelFiles(b *testing.B) {
+	v := memvfs.New()
+	var seq atomic.Int64
+	buf := make([]byte, 1<<20)
+
+	b.SetBytes(4096)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		name := fmt.Sprintf("bench-readat-parallel-%d.db", seq.Add(1))
+		f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+		if err != nil {
+			b.Errorf("Open error: %v", err)
+			return
+		}
+		defer f.Close()
+		if _, err := f.WriteAt(buf, 0); err != nil {
+			b.Errorf("WriteAt error: %v", err)
+			return
+		}
+
+		page := make([]byte, 4096)
+		for i := 0; pb.Next(); i++ {
+			f.ReadAt(page, int64(i%256)*4096)
+		}
+	})
+}
+
+// BenchmarkReadAtParallelHandles reads one file through a handle per
+// goroutine, as read-only connections sharing a database do.
+func BenchmarkReadAtParallelHandles(b *testing.B) {
+	v := memvfs.New()
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	f, _, err := v.Open("bench-readat-handles.db", flags)
+	if err != nil {
+		b.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(make([]byte, 1<<20), 0); err != nil {
+		b.Fatalf("WriteAt error: %v", err)
+	}
+
+	b.SetBytes(4096)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		f, _, err := v.Open("bench-readat-handles.db", flags)
+		if err != nil {
+			b.Errorf("Open error: %v", err)
+			return
+		}
+		page := make([]byte, 4096)
+		for i := 0; pb.Next(); i++ {
+			f.ReadAt(page, int64(i%256)*4096)
+			f.FileSize()
+		}
+	})
+}
+
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func randSeq(n int) string {
@@ -0,0 +1,73 @@
+package memvfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// WriteMetrics writes the store's Stats to w in the Prometheus text
+// exposition format, as metrics named memvfs_*, with the IO and size of
+// each file role under a role label. Services that already export
+// Prometheus metrics can serve it with MetricsHandler or append it to their
+// own scrape output.
+func (v *MemVFS) WriteMetrics(w io.Writer) error {
+	s := v.Stats()
+	roles := make([]Role, 0, len(s.ByRole))
+	for r := range s.ByRole {
+		roles = append(roles, r)
+	}
+	slices.Sort(roles)
+
+	var b strings.Builder
+	byRole := func(name, typ, help string, value func(RoleStats) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, r := range roles {
+			fmt.Fprintf(&b, "%s{role=%q} %d\n", name, r.String(), value(s.ByRole[r]))
+		}
+	}
+	single := func(name, typ, help string, value int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, typ, name, value)
+	}
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+	}
+
+	byRole("memvfs_files", "gauge", "Files stored.", func(r RoleStats) int64 { return int64(r.Files) })
+	byRole("memvfs_stored_bytes", "gauge", "Bytes held in memory.", func(r RoleStats) int64 { return r.Bytes })
+	byRole("memvfs_reads_total", "counter", "Reads issued by SQLite.", func(r RoleStats) int64 { return r.Reads })
+	byRole("memvfs_writes_total", "counter", "Writes issued by SQLite.", func(r RoleStats) int64 { return r.Writes })
+	byRole("memvfs_read_bytes_total", "counter", "Bytes read by SQLite.", func(r RoleStats) int64 { return r.BytesRead })
+	byRole("memvfs_written_bytes_total", "counter", "Bytes written by SQLite.", func(r RoleStats) int64 { return r.BytesWritten })
+	byRole("memvfs_truncates_total", "counter", "Truncates issued by SQLite.", func(r RoleStats) int64 { return r.Truncates })
+	byRole("memvfs_syncs_total", "counter", "Syncs issued by SQLite.", func(r RoleStats) int64 { return r.Syncs })
+	single("memvfs_memory_budget_bytes", "gauge", "Memory budget, 0 if unlimited.", s.MemoryBudget)
+	single("memvfs_open_handles", "gauge", "Handles SQLite has open.", int64(s.Handles))
+	single("memvfs_evictions_total", "counter", "Files dropped by eviction.", s.Evicted)
+	single("memvfs_temp_spilled_total", "counter", "Temporary files moved to disk.", s.TempSpilled)
+	single("memvfs_temp_rejected_total", "counter", "Temporary files that failed to grow.", s.TempRejected)
+	single("memvfs_degraded_files", "gauge", "Files whose backend missed an IO deadline.", int64(s.Degraded))
+	single("memvfs_circuit_open_files", "gauge", "Files whose backend circuit breaker is open.", int64(s.CircuitOpen))
+	single("memvfs_compressed_files", "gauge", "Files held compressed.", int64(s.Compressed))
+	single("memvfs_cold_files", "gauge", "Files moved to disk by tiering.", int64(s.Cold))
+	single("memvfs_cache_hits_total", "counter", "Backend blocks read from cache.", s.Cache.Hits)
+	single("memvfs_cache_misses_total", "counter", "Backend blocks fetched.", s.Cache.Misses)
+	gauge("memvfs_growth_bytes_per_second", "How fast files are growing.", s.GrowthRate)
+	if s.BudgetLeft > 0 {
+		gauge("memvfs_budget_left_seconds", "How long the memory budget lasts at the current growth.", s.BudgetLeft.Seconds())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// MetricsHandler serves WriteMetrics over HTTP as a Prometheus scrape
+// target.
+func (v *MemVFS) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		v.WriteMetrics(w)
+	})
+}
@@ -0,0 +1,61 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	mv := memvfs.New(memvfs.WithMemoryBudget(1 << 30))
+	if err := mv.Register("memvfs-metrics"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:test-metrics.db?vfs=memvfs-metrics")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE demo (data TEXT); INSERT INTO demo VALUES ('v1')`); err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	mv.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	metrics := make(map[string]string)
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("Malformed line %q", line)
+		}
+		metrics[name] = value
+	}
+
+	for name, want := range map[string]string{
+		"memvfs_open_handles":            "1",
+		"memvfs_files{role=\"main-db\"}": "1",
+		"memvfs_memory_budget_bytes":     "1073741824",
+		"memvfs_evictions_total":         "0",
+	} {
+		if got := metrics[name]; got != want {
+			t.Errorf("Expected %s %s, got %q", name, want, got)
+		}
+	}
+	for _, name := range []string{"memvfs_writes_total{role=\"main-db\"}", "memvfs_written_bytes_total{role=\"main-db\"}"} {
+		if got := metrics[name]; got == "" || got == "0" {
+			t.Errorf("Expected %s to count the writes, got %q", name, got)
+		}
+	}
+}
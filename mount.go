@@ -0,0 +1,166 @@
+package memvfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// BackupStore gives random access to stored database backups.
+type BackupStore interface {
+	// OpenBackup returns the contents of backup id and their size. If the
+	// reader is an io.Closer it is closed when the mount is deleted.
+	OpenBackup(id string) (io.ReaderAt, int64, error)
+}
+
+// DirBackupStore is a BackupStore holding each backup as a file named by
+// its id in a directory.
+type DirBackupStore string
+
+func (d DirBackupStore) OpenBackup(id string) (io.ReaderAt, int64, error) {
+	if !filepath.IsLocal(id) {
+		return nil, 0, errors.New("memvfs: invalid backup id")
+	}
+	f, err := os.Open(filepath.Join(string(d), id))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// mountBlockSize is the granularity at which mounted backups are fetched.
+const mountBlockSize = 64 << 10
+
+// MountBackup exposes backup backupID of store as the read-only database
+// asName without restoring it: blocks are fetched from the store the first
+// time a query reads them and kept afterwards. Delete asName to unmount.
+func (v *MemVFS) MountBackup(store BackupStore, backupID, asName string) error {
+	r, size, err := store.OpenBackup(backupID)
+	if err != nil {
+		return err
+	}
+	src := &mountSource{r: r, size: size, blocks: make(map[int64][]byte)}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.files[asName]; ok {
+		src.Close()
+		return ErrExist
+	}
+	e := v.lookup(asName, sqlite3vfs.OpenReadOnly|sqlite3vfs.OpenMainDB)
+	e.readOnly = true
+	e.retain = true
+	e.src = src
+	return nil
+}
+
+// mountSource serves a mounted backup, caching the blocks it has fetched.
+type mountSource struct {
+	r    io.ReaderAt
+	size int64
+
+	mu     sync.Mutex
+	blocks map[int64][]byte
+
+	// The cache counters are atomic so that stats are not held up by a
+	// fetch from a slow backend, which happens under mu.
+	hits, misses, fetched atomic.Int64
+}
+
+func (s *mountSource) block(i int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.blocks[i]; ok {
+		s.hits.Add(1)
+		return b, nil
+	}
+	s.misses.Add(1)
+	off := i * mountBlockSize
+	b := make([]byte, min(mountBlockSize, s.size-off))
+	if _, err := s.r.ReadAt(b, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+	s.blocks[i] = b
+	s.fetched.Add(int64(len(b)))
+	return b, nil
+}
+
+func (s *mountSource) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) && off < s.size {
+		b, err := s.block(off / mountBlockSize)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], b[off%mountBlockSize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *mountSource) readCached(p []byte, off int64) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end := min(off+int64(len(p)), s.size)
+	first := off / mountBlockSize
+	for i := first; i*mountBlockSize < end; i++ {
+		if _, ok := s.blocks[i]; !ok {
+			return 0, false
+		}
+	}
+	s.hits.Add((end+mountBlockSize-1)/mountBlockSize - first)
+	n := 0
+	for n < len(p) && off < s.size {
+		c := copy(p[n:], s.blocks[off/mountBlockSize][off%mountBlockSize:])
+		n += c
+		off += int64(c)
+	}
+	return n, true
+}
+
+// cacheStats reports every fetched block as cached, as blocks are kept
+// until the mount is deleted.
+func (s *mountSource) cacheStats() CacheStats {
+	fetched := s.fetched.Load()
+	return CacheStats{
+		Hits:         s.hits.Load(),
+		Misses:       s.misses.Load(),
+		BytesFetched: fetched,
+		CachedBytes:  fetched,
+	}
+}
+
+func (s *mountSource) Size() int64 {
+	return s.size
+}
+
+// Pin and Unpin are no-ops: backups do not change.
+func (s *mountSource) Pin() error {
+	return nil
+}
+
+func (s *mountSource) Unpin() {}
+
+func (s *mountSource) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+// countingStore counts the bytes fetched from a DirBackupStore.
+type countingStore struct {
+	memvfs.DirBackupStore
+	fetched atomic.Int64
+}
+
+type countingReader struct {
+	io.ReaderAt
+	s *countingStore
+}
+
+func (r countingReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReaderAt.ReadAt(p, off)
+	r.s.fetched.Add(int64(n))
+	return n, err
+}
+
+func (s *countingStore) OpenBackup(id string) (io.ReaderAt, int64, error) {
+	r, size, err := s.DirBackupStore.OpenBackup(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return countingReader{r, s}, size, nil
+}
+
+func TestMountBackup(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := sql.Open("sqlite3", filepath.Join(dir, "backup-1"))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	_, err = disk.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 2000; i++ {
+		_, err = disk.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	disk.Close()
+	info, err := os.Stat(filepath.Join(dir, "backup-1"))
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+
+	store := &countingStore{DirBackupStore: memvfs.DirBackupStore(dir)}
+	name := "test-mount.db"
+	if err := v.MountBackup(store, "backup-1", name); err != nil {
+		t.Fatalf("MountBackup error: %v", err)
+	}
+	defer v.Delete(name, false)
+	if err := v.MountBackup(store, "backup-1", name); err == nil {
+		t.Errorf("Expected error mounting over an existing name")
+	}
+	if err := v.MountBackup(store, "../backup-1", "test-mount-escape.db"); err == nil {
+		t.Errorf("Expected error for a backup id outside the store")
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	var data string
+	if err := db.QueryRow(`SELECT data FROM demo WHERE id = 1000`).Scan(&data); err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if fetched := store.fetched.Load(); fetched == 0 || fetched >= info.Size() {
+		t.Errorf("Expected a point query to fetch part of the %d byte backup, fetched %d", info.Size(), fetched)
+	}
+	if err := db.QueryRow(`SELECT data FROM demo WHERE id = 1000`).Scan(&data); err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	fi, _ := v.Stat(name)
+	if c := fi.Cache; c.Misses == 0 || c.Hits == 0 || c.BytesFetched != store.fetched.Load() || c.CachedBytes != c.BytesFetched {
+		t.Errorf("Unexpected cache stats after repeating a query: %+v", c)
+	}
+	if c := v.Stats().Cache; c.Hits < fi.Cache.Hits {
+		t.Errorf("Expected store cache stats to include the mount, got %+v", c)
+	}
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('rewrite history')`); err == nil {
+		t.Errorf("Expected mounted backup to be read-only")
+	}
+}
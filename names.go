@@ -0,0 +1,77 @@
+package memvfs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// NameMapper replaces file names wherever the store puts them in text that
+// may leave the process, such as error messages, so that tenant identifiers
+// embedded in database names do not end up in logs.
+type NameMapper interface {
+	MapName(name string) string
+}
+
+// HashNames maps each name to a keyed hash of it. Without the key the hash
+// cannot be reversed or recomputed from a guessed name.
+func HashNames(key []byte) NameMapper {
+	return hashNames{key: key}
+}
+
+type hashNames struct {
+	key []byte
+}
+
+func (h hashNames) MapName(name string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(name))
+	return "h-" + hex.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// WithNameMapper makes the store refer to files by m's mapping of their
+// names in errors. LogName applies the same mapping for the caller's own
+// logs and metrics, and ResolveName maps back; the reverse mapping is only
+// kept in memory.
+func WithNameMapper(m NameMapper) Option {
+	return func(v *MemVFS) {
+		v.names.mapper = m
+	}
+}
+
+// nameMap is the state of WithNameMapper. It has its own lock as names are
+// mapped under every other one.
+type nameMap struct {
+	mapper NameMapper
+
+	mu      sync.Mutex
+	reverse map[string]string
+}
+
+// LogName returns name as it should appear in logs and metrics: mapped if
+// the store was created WithNameMapper, and unchanged otherwise.
+func (v *MemVFS) LogName(name string) string {
+	n := &v.names
+	if n.mapper == nil {
+		return name
+	}
+	mapped := n.mapper.MapName(name)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.reverse == nil {
+		n.reverse = make(map[string]string)
+	}
+	n.reverse[mapped] = name
+	return mapped
+}
+
+// ResolveName returns the name that LogName mapped to mapped, if the store
+// has mapped it since it was created.
+func (v *MemVFS) ResolveName(mapped string) (string, bool) {
+	n := &v.names
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	name, ok := n.reverse[mapped]
+	return name, ok
+}
@@ -0,0 +1,37 @@
+package memvfs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestNameMapper(t *testing.T) {
+	nv := memvfs.New(memvfs.WithNameMapper(memvfs.HashNames([]byte("secret"))))
+	name := "tenant-acme.db"
+	err := nv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Delete(name)
+	})
+	if !errors.Is(err, memvfs.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if strings.Contains(err.Error(), "acme") {
+		t.Errorf("Expected the error not to name the tenant, got %v", err)
+	}
+
+	mapped := nv.LogName(name)
+	if !strings.Contains(err.Error(), mapped) {
+		t.Errorf("Expected the error to carry %s, got %v", mapped, err)
+	}
+	if other := memvfs.New(memvfs.WithNameMapper(memvfs.HashNames([]byte("other")))).LogName(name); other == mapped {
+		t.Errorf("Expected the mapping to depend on the key")
+	}
+	if got, ok := nv.ResolveName(mapped); !ok || got != name {
+		t.Errorf("Expected %s to resolve to %s, got %q", mapped, name, got)
+	}
+	if got := v.LogName(name); got != name {
+		t.Errorf("Expected names to be unchanged without a mapper, got %s", got)
+	}
+}
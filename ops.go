@@ -0,0 +1,128 @@
+package memvfs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// recentOps is the number of operations kept per file for RecentOps.
+const recentOps = 32
+
+// OpKind is the kind of a VFS operation logged for RecentOps.
+type OpKind int
+
+const (
+	OpOpen OpKind = iota
+	OpRead
+	OpWrite
+	OpTruncate
+	OpSync
+	OpLock
+	OpUnlock
+	OpClose
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpOpen:
+		return "open"
+	case OpRead:
+		return "read"
+	case OpWrite:
+		return "write"
+	case OpTruncate:
+		return "truncate"
+	case OpSync:
+		return "sync"
+	case OpLock:
+		return "lock"
+	case OpUnlock:
+		return "unlock"
+	case OpClose:
+		return "close"
+	default:
+		return fmt.Sprintf("OpKind<%d>", int(k))
+	}
+}
+
+// Op is a VFS operation on a file, as returned by RecentOps.
+type Op struct {
+	// Time is when the operation finished.
+	Time   time.Time
+	Kind   OpKind
+	Handle HandleID
+
+	// Offset and Length locate reads and writes; a truncate has the new
+	// size as Offset. Lock is the level asked for by locks and unlocks.
+	Offset int64
+	Length int64
+	Lock   sqlite3vfs.LockType
+
+	Err error
+}
+
+// opRing holds the last recentOps operations on a file. It has its own
+// lock so that logging does not depend on which other locks are held.
+type opRing struct {
+	mu   sync.Mutex
+	ops  [recentOps]Op
+	next uint64
+}
+
+// opRing returns e's ring, creating it on first use. v.mu must be held.
+func (e *entry) opRing() *opRing {
+	if e.ops == nil {
+		e.ops = new(opRing)
+	}
+	return e.ops
+}
+
+// log records op, failed with *err if not nil. It is meant to be deferred
+// with the address of the caller's error result.
+func (r *opRing) log(op Op, err *error) {
+	if r == nil {
+		return
+	}
+	op.Time = time.Now()
+	if err != nil {
+		op.Err = *err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops[r.next%recentOps] = op
+	r.next++
+}
+
+// RecentOps returns the last operations performed through handles on the
+// named file, oldest first. Every file keeps its last few dozen, so what
+// led up to a failure can be seen after the fact without tracing having
+// been set up in advance.
+func (v *MemVFS) RecentOps(name string) ([]Op, error) {
+	v.mu.Lock()
+	e, ok := v.files[name]
+	var r *opRing
+	if ok {
+		r = e.ops
+	}
+	v.mu.Unlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if r == nil {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := min(r.next, recentOps)
+	ops := make([]Op, 0, n)
+	for i := r.next - n; i < r.next; i++ {
+		ops = append(ops, r.ops[i%recentOps])
+	}
+	return ops, nil
+}
@@ -0,0 +1,43 @@
+package memvfs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestRecentOps(t *testing.T) {
+	v := memvfs.New()
+	name := "test-recent-ops.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 100; i++ {
+		f.WriteAt(make([]byte, 512), int64(i)*512)
+	}
+	f.ReadAt(make([]byte, 512), 1<<20)
+
+	ops, err := v.RecentOps(name)
+	if err != nil {
+		t.Fatalf("RecentOps error: %v", err)
+	}
+	if len(ops) == 0 || len(ops) > 100 {
+		t.Fatalf("Expected a bounded number of ops, got %d", len(ops))
+	}
+	last := ops[len(ops)-1]
+	if last.Kind != memvfs.OpRead || last.Offset != 1<<20 || !errors.Is(last.Err, sqlite3vfs.IOErrorShortRead) {
+		t.Errorf("Expected the short read last, got %+v", last)
+	}
+	if prev := ops[len(ops)-2]; prev.Kind != memvfs.OpWrite || prev.Offset != 99*512 {
+		t.Errorf("Expected the last write before it, got %+v", prev)
+	}
+
+	if _, err := v.RecentOps("test-recent-ops-missing.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package memvfs
+
+import (
+	"io"
+	"sync"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// overlayBlockSize is the granularity at which writes to an overlaid file
+// are copied into its overlay.
+const overlayBlockSize = 4096
+
+// Overlay exposes the size bytes of base as the database name. base is only
+// ever read: writes through SQLite go to an in-memory overlay of the blocks
+// they touch, so a reference database shipped in the binary, for instance
+// with bytes.NewReader, can be opened read-write by every process, or
+// several times in one store, at the cost of the pages each one changes.
+//
+// base must not change while in use. If it is an io.Closer it is closed
+// when name is deleted.
+func (v *MemVFS) Overlay(base io.ReaderAt, size int64, name string) error {
+	src := newOverlaySource(base, size)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.files[name]; ok {
+		src.Close()
+		return ErrExist
+	}
+	e := v.lookup(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite)
+	e.retain = true
+	e.src = src
+	return nil
+}
+
+// overlaySource serves a read-only base, overlaid with the blocks written
+// since it was opened.
+type overlaySource struct {
+	base io.ReaderAt
+
+	mu sync.Mutex
+	// based is the prefix of base still part of the file; a truncate below
+	// it hides the rest for good.
+	based  int64
+	size   int64
+	blocks map[int64][]byte
+}
+
+func newOverlaySource(base io.ReaderAt, size int64) *overlaySource {
+	return &overlaySource{
+		base:   base,
+		based:  size,
+		size:   size,
+		blocks: make(map[int64][]byte),
+	}
+}
+
+// read copies the file's contents at off into p, which must lie within one
+// block. s.mu must be held.
+func (s *overlaySource) read(p []byte, off int64) error {
+	if b, ok := s.blocks[off/overlayBlockSize]; ok {
+		copy(p, b[off%overlayBlockSize:])
+		return nil
+	}
+	n := 0
+	if off < s.based {
+		var err error
+		n, err = s.base.ReadAt(p[:min(int64(len(p)), s.based-off)], off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	clear(p[n:])
+	return nil
+}
+
+func (s *overlaySource) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for n < len(p) && off < s.size {
+		c := min(int64(len(p)-n), overlayBlockSize-off%overlayBlockSize, s.size-off)
+		if err := s.read(p[n:n+int(c)], off); err != nil {
+			return n, err
+		}
+		n += int(c)
+		off += c
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block returns the overlay block i, copying it from the base on first
+// write. s.mu must be held.
+func (s *overlaySource) block(i int64) ([]byte, error) {
+	b, ok := s.blocks[i]
+	if !ok {
+		b = make([]byte, overlayBlockSize)
+		if off := i * overlayBlockSize; off < s.size {
+			if err := s.read(b[:min(overlayBlockSize, s.size-off)], off); err != nil {
+				return nil, err
+			}
+		}
+		s.blocks[i] = b
+	}
+	return b, nil
+}
+
+func (s *overlaySource) WriteAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		b, err := s.block(off / overlayBlockSize)
+		if err != nil {
+			return n, err
+		}
+		c := copy(b[off%overlayBlockSize:], p[n:])
+		n += c
+		off += int64(c)
+	}
+	s.size = max(s.size, off)
+	return n, nil
+}
+
+func (s *overlaySource) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size < s.size {
+		for i, b := range s.blocks {
+			switch off := i * overlayBlockSize; {
+			case off >= size:
+				delete(s.blocks, i)
+			case off+overlayBlockSize > size:
+				clear(b[size-off:])
+			}
+		}
+		s.based = min(s.based, size)
+	}
+	s.size = size
+	return nil
+}
+
+func (s *overlaySource) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *overlaySource) Close() error {
+	if c, ok := s.base.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Pin and Unpin are no-ops: the base does not change.
+func (s *overlaySource) Pin() error { return nil }
+
+func (s *overlaySource) Unpin() {}
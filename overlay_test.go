@@ -0,0 +1,67 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "base.db")
+	disk, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("Failed to open disk DB: %v", err)
+	}
+	_, err = disk.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := disk.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	disk.Close()
+	base, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	before := bytes.Clone(base)
+
+	names := []string{"test-overlay-a.db", "test-overlay-b.db"}
+	for _, name := range names {
+		if err := v.Overlay(bytes.NewReader(base), int64(len(base)), name); err != nil {
+			t.Fatalf("Overlay error: %v", err)
+		}
+		defer v.Delete(name, false)
+	}
+	if err := v.Overlay(bytes.NewReader(base), int64(len(base)), names[0]); err == nil {
+		t.Errorf("Expected overlaying an existing name to fail")
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+names[0]+"?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert on overlay error: %v", err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM demo WHERE id <= 10`); err != nil {
+		t.Fatalf("Delete on overlay error: %v", err)
+	}
+	db.Close()
+
+	if n := countRows(t, names[0]); n != 140 {
+		t.Errorf("Expected 140 rows in the written overlay, got %d", n)
+	}
+	if n := countRows(t, names[1]); n != 100 {
+		t.Errorf("Expected the other overlay to keep 100 rows, got %d", n)
+	}
+	if !bytes.Equal(base, before) {
+		t.Errorf("Writes reached the base")
+	}
+}
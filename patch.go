@@ -0,0 +1,234 @@
+package memvfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// PatchBlockSize is the granularity at which CreatePatch compares images.
+const PatchBlockSize = 4096
+
+// ErrPatchBase is returned by ApplyPatch when the file is not the image the
+// patch was created against.
+var ErrPatchBase = errors.New("memvfs: patch does not apply to this file")
+
+// Patch is a block-level delta turning one database image into another.
+type Patch struct {
+	// BaseSize and BaseSum identify the image the patch applies to.
+	BaseSize int64
+	BaseSum  [sha256.Size]byte
+
+	// Size is the length of the resulting image and Blocks the regions of
+	// it that differ from the base.
+	Size   int64
+	Blocks []PatchBlock
+}
+
+type PatchBlock struct {
+	Offset int64
+	Data   []byte
+}
+
+// CreatePatch compares old and new, both read until io.EOF, and returns the
+// blocks of new that differ from old.
+func CreatePatch(old, new io.ReaderAt) (Patch, error) {
+	var (
+		p        Patch
+		sum      = sha256.New()
+		oldBlock = make([]byte, PatchBlockSize)
+		newBlock = make([]byte, PatchBlockSize)
+		oldDone  bool
+		newDone  bool
+	)
+	for off := int64(0); !oldDone || !newDone; off += PatchBlockSize {
+		var on, nn int
+		var err error
+		if !oldDone {
+			on, err = readBlock(old, oldBlock, off)
+			if err != nil {
+				return Patch{}, err
+			}
+			sum.Write(oldBlock[:on])
+			p.BaseSize += int64(on)
+			oldDone = on < PatchBlockSize
+		}
+		if !newDone {
+			nn, err = readBlock(new, newBlock, off)
+			if err != nil {
+				return Patch{}, err
+			}
+			p.Size += int64(nn)
+			newDone = nn < PatchBlockSize
+		}
+		if nn > 0 && (on < nn || !bytes.Equal(oldBlock[:nn], newBlock[:nn])) {
+			p.Blocks = append(p.Blocks, PatchBlock{
+				Offset: off,
+				Data:   bytes.Clone(newBlock[:nn]),
+			})
+		}
+	}
+	copy(p.BaseSum[:], sum.Sum(nil))
+	return p, nil
+}
+
+// readBlock fills p from r at off, returning fewer bytes only at io.EOF.
+func readBlock(r io.ReaderAt, p []byte, off int64) (int, error) {
+	n, err := r.ReadAt(p, off)
+	if err == io.EOF || err == nil && n < len(p) {
+		return n, nil
+	}
+	return n, err
+}
+
+// ApplyPatch applies p to the named file, which must be the image the patch
+// was created against, and fails with ErrBusy while a connection is using
+// it. Only the changed blocks are written.
+func (v *MemVFS) ApplyPatch(name string, p Patch) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if e.readOnly || e.src != nil && !e.sealed() {
+		return sqlite3vfs.ReadOnlyError
+	}
+	if e.locked(sqlite3vfs.LockShared) {
+		return fmt.Errorf("%w: %s", ErrBusy, v.LogName(name))
+	}
+	if err := v.thaw(e); err != nil {
+		return err
+	}
+	if err := v.unseal(e); err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, v.seal(e))
+	}()
+	if int64(len(e.data)) != p.BaseSize || sha256.Sum256(e.data) != p.BaseSum {
+		return ErrPatchBase
+	}
+	for _, b := range p.Blocks {
+		if b.Offset < 0 || b.Offset+int64(len(b.Data)) > p.Size {
+			return fmt.Errorf("memvfs: patch block at %d out of range", b.Offset)
+		}
+	}
+
+	e.own()
+	data := e.data
+	if int64(cap(data)) < p.Size {
+		data = make([]byte, p.Size)
+		copy(data, e.data)
+	}
+	data = data[:p.Size]
+	if p.Size > int64(len(e.data)) {
+		clear(data[len(e.data):])
+	}
+	for _, b := range p.Blocks {
+		e.mutating(nil, b.Offset, b.Offset+int64(len(b.Data)))
+		copy(data[b.Offset:], b.Data)
+	}
+	e.data = data
+	e.modified()
+	v.account(e)
+	return nil
+}
+
+var patchMagic = [8]byte{'M', 'V', 'P', 'A', 'T', 'C', 'H', '1'}
+
+// WriteTo encodes the patch for shipping, to be decoded with ReadPatch.
+func (p Patch) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	cw.Write(patchMagic[:])
+	binary.Write(cw, binary.BigEndian, uint64(p.BaseSize))
+	cw.Write(p.BaseSum[:])
+	binary.Write(cw, binary.BigEndian, uint64(p.Size))
+	binary.Write(cw, binary.BigEndian, uint32(len(p.Blocks)))
+	for _, b := range p.Blocks {
+		binary.Write(cw, binary.BigEndian, uint64(b.Offset))
+		binary.Write(cw, binary.BigEndian, uint32(len(b.Data)))
+		cw.Write(b.Data)
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+// ReadPatch decodes a patch written by Patch.WriteTo.
+func ReadPatch(r io.Reader) (Patch, error) {
+	var (
+		p     Patch
+		magic [8]byte
+		size  uint64
+		n     uint32
+	)
+	br := bufio.NewReader(r)
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return Patch{}, err
+	}
+	if magic != patchMagic {
+		return Patch{}, errors.New("memvfs: not a patch")
+	}
+	if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+		return Patch{}, err
+	}
+	p.BaseSize = int64(size)
+	if _, err := io.ReadFull(br, p.BaseSum[:]); err != nil {
+		return Patch{}, err
+	}
+	if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+		return Patch{}, err
+	}
+	p.Size = int64(size)
+	if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+		return Patch{}, err
+	}
+	for i := uint32(0); i < n; i++ {
+		var (
+			off    uint64
+			length uint32
+		)
+		if err := binary.Read(br, binary.BigEndian, &off); err != nil {
+			return Patch{}, err
+		}
+		if err := binary.Read(br, binary.BigEndian, &length); err != nil {
+			return Patch{}, err
+		}
+		if length > PatchBlockSize {
+			return Patch{}, fmt.Errorf("memvfs: patch block of %d bytes too large", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return Patch{}, err
+		}
+		p.Blocks = append(p.Blocks, PatchBlock{Offset: int64(off), Data: data})
+	}
+	return p, nil
+}
+
+// countWriter counts bytes written and remembers the first error.
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,83 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestPatch(t *testing.T) {
+	dbName := "test-patch-src.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(100))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	old, _ := v.GetFile(dbName)
+	old = bytes.Clone(old)
+
+	_, err = db.Exec(`UPDATE demo SET data = 'patched' WHERE id = 250`)
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(5000))
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	new, _ := v.GetFile(dbName)
+	new = bytes.Clone(new)
+
+	p, err := memvfs.CreatePatch(bytes.NewReader(old), bytes.NewReader(new))
+	if err != nil {
+		t.Fatalf("CreatePatch error: %v", err)
+	}
+	if len(p.Blocks) == 0 || len(p.Blocks)*memvfs.PatchBlockSize >= len(new)/2 {
+		t.Errorf("Patch has %d blocks for a %d byte image", len(p.Blocks), len(new))
+	}
+
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo error: %v", err)
+	}
+	p, err = memvfs.ReadPatch(&buf)
+	if err != nil {
+		t.Fatalf("ReadPatch error: %v", err)
+	}
+
+	edge := "test-patch-edge.db"
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put(edge, old)
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	defer v.Delete(edge, false)
+
+	if err := v.ApplyPatch(edge, p); err != nil {
+		t.Fatalf("ApplyPatch error: %v", err)
+	}
+	got, _ := v.GetFile(edge)
+	if !bytes.Equal(got, new) {
+		t.Fatalf("Patched image does not match")
+	}
+
+	// The edge now holds the new version, which the patch no longer applies to.
+	if err := v.ApplyPatch(edge, p); !errors.Is(err, memvfs.ErrPatchBase) {
+		t.Errorf("Expected ErrPatchBase, got %v", err)
+	}
+}
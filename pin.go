@@ -0,0 +1,117 @@
+package memvfs
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PageRange is the pages First through Last of a database, numbered from 1
+// as SQLite numbers them.
+type PageRange struct {
+	First, Last int64
+}
+
+// pin is a set of byte ranges of a file kept hot by Pin.
+type pin struct {
+	spans spans
+}
+
+// prefetcher is a source that can fetch ranges from its backend ahead of
+// the reads that need them.
+type prefetcher interface {
+	prefetch(start, end int64) error
+}
+
+// Pin keeps the given pages of name hot for d ahead of a known heavy job,
+// such as a scheduled report, so that the job does not pay for bringing
+// them in: pages of a mounted backup are fetched from the store now, and
+// pinned pages are exempt from being moved out of memory until the pin is
+// released. The pin releases itself after d, or earlier when unpin is
+// called.
+func (v *MemVFS) Pin(name string, ranges []PageRange, d time.Duration) (unpin func(), err error) {
+	v.mu.Lock()
+	e, ok := v.files[name]
+	if !ok {
+		v.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if err := v.thaw(e); err != nil {
+		v.mu.Unlock()
+		return nil, err
+	}
+	var header []byte
+	if e.reader() == nil {
+		header = e.data[:min(100, len(e.data))]
+	}
+	v.mu.Unlock()
+	// Reading a sourced header may go to a slow backend, so it is done
+	// without holding the store.
+	if header == nil {
+		header = make([]byte, 100)
+		n, _ := e.reader().ReadAt(header, 0)
+		header = header[:n]
+	}
+	pageSize := int64(headerPageSize(header))
+	if pageSize == 0 {
+		return nil, errors.New("memvfs: cannot pin pages of a file without a database header")
+	}
+
+	p := &pin{}
+	for _, r := range ranges {
+		if r.First < 1 || r.Last < r.First {
+			return nil, errors.New("memvfs: invalid page range")
+		}
+		p.spans = p.spans.add((r.First-1)*pageSize, r.Last*pageSize)
+	}
+	if src, ok := e.src.(prefetcher); ok {
+		for _, sp := range p.spans {
+			if err := src.prefetch(sp.start, sp.end); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	v.mu.Lock()
+	e.pins = append(e.pins, p)
+	// The file may have been demoted before the pin was in place.
+	err = v.thaw(e)
+	v.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	unpin = func() {
+		once.Do(func() {
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			e.pins = slices.DeleteFunc(e.pins, func(q *pin) bool { return q == p })
+		})
+	}
+	time.AfterFunc(d, unpin)
+	return unpin, nil
+}
+
+// pinned returns the union of e's pinned ranges. v.mu must be held.
+func (e *entry) pinned() spans {
+	var s spans
+	for _, p := range e.pins {
+		for _, sp := range p.spans {
+			s = s.add(sp.start, sp.end)
+		}
+	}
+	return s
+}
+
+// prefetch fetches the blocks covering [start, end) into the cache.
+func (s *mountSource) prefetch(start, end int64) error {
+	end = min(end, s.size)
+	for i := start / mountBlockSize; i*mountBlockSize < end; i++ {
+		if _, err := s.block(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestPin(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := sql.Open("sqlite3", filepath.Join(dir, "backup-pin"))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if _, err := disk.Exec(`PRAGMA page_size = 4096`); err != nil {
+		t.Fatalf("Pragma error: %v", err)
+	}
+	_, err = disk.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 2000; i++ {
+		if _, err := disk.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	disk.Close()
+
+	store := &countingStore{DirBackupStore: memvfs.DirBackupStore(dir)}
+	name := "test-pin.db"
+	if err := v.MountBackup(store, "backup-pin", name); err != nil {
+		t.Fatalf("MountBackup error: %v", err)
+	}
+	defer v.Delete(name, false)
+
+	if _, err := v.Pin(name, []memvfs.PageRange{{First: 2, Last: 1}}, time.Minute); err == nil {
+		t.Errorf("Expected an error for an empty page range")
+	}
+	if _, err := v.Pin("test-pin-missing.db", nil, time.Minute); err == nil {
+		t.Errorf("Expected an error pinning a missing file")
+	}
+
+	// Pages 17 through 32 lie in the second 64 KiB block of the backup.
+	unpin, err := v.Pin(name, []memvfs.PageRange{{First: 17, Last: 24}, {First: 25, Last: 32}}, time.Minute)
+	if err != nil {
+		t.Fatalf("Pin error: %v", err)
+	}
+	info, _ := v.Stat(name)
+	if info.Pinned != 16*4096 {
+		t.Errorf("Expected 16 pinned pages, got %d bytes", info.Pinned)
+	}
+	if info.Cache.BytesFetched != 2*64<<10 {
+		t.Errorf("Expected the header and pinned blocks fetched, got %d bytes", info.Cache.BytesFetched)
+	}
+
+	fetched := store.fetched.Load()
+	buf := make([]byte, 4096)
+	mf, _, err := v.Open(name, info.Flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer mf.Close()
+	if _, err := mf.ReadAt(buf, 20*4096); err != nil {
+		t.Fatalf("ReadAt error: %v", err)
+	}
+	if n := store.fetched.Load(); n != fetched {
+		t.Errorf("Expected a pinned page to be read without a fetch, fetched %d bytes", n-fetched)
+	}
+
+	unpin()
+	unpin()
+	if info, _ := v.Stat(name); info.Pinned != 0 {
+		t.Errorf("Expected no pinned bytes after unpin, got %d", info.Pinned)
+	}
+
+	if _, err := v.Pin(name, []memvfs.PageRange{{First: 1, Last: 1}}, 10*time.Millisecond); err != nil {
+		t.Fatalf("Pin error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if info, _ := v.Stat(name); info.Pinned != 0 {
+		t.Errorf("Expected the pin to expire, got %d pinned bytes", info.Pinned)
+	}
+}
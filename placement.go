@@ -0,0 +1,51 @@
+package memvfs
+
+import "hash/fnv"
+
+// Placement decides which of n targets, such as spill directories, a file
+// is placed on. It must be deterministic so that a file is always found
+// where it was put.
+type Placement interface {
+	Place(name string, n int) int
+}
+
+// HashPlacement places files by rendezvous hashing of their names: each
+// name goes to the target with the highest hash of name and target index.
+// Names spread evenly, and adding a target only moves the names that now
+// hash highest on it, about 1/n of them.
+type HashPlacement struct{}
+
+func (HashPlacement) Place(name string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	nameHash := h.Sum64()
+
+	best, bestHash := 0, uint64(0)
+	for i := 0; i < n; i++ {
+		if sum := mix64(nameHash ^ mix64(uint64(i)+1)); i == 0 || sum > bestHash {
+			best, bestHash = i, sum
+		}
+	}
+	return best
+}
+
+// mix64 is the splitmix64 finalizer, spreading every input bit over the
+// output.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// WithSpillDirs spreads temporary files spilled under WithTempBudget over
+// dirs, such as directories on different disks, choosing one per file with
+// placement. A nil placement selects HashPlacement.
+func WithSpillDirs(placement Placement, dirs ...string) Option {
+	return func(v *MemVFS) {
+		v.spillDirs = append(v.spillDirs, dirs...)
+		v.placement = placement
+	}
+}
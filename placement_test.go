@@ -0,0 +1,38 @@
+package memvfs_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestHashPlacement(t *testing.T) {
+	var p memvfs.HashPlacement
+	const names = 10000
+
+	counts := make([]int, 4)
+	moved := 0
+	for i := 0; i < names; i++ {
+		name := fmt.Sprintf("tenant-%d.db", i)
+		target := p.Place(name, 4)
+		if again := p.Place(name, 4); again != target {
+			t.Fatalf("Place(%s) is not deterministic: %d then %d", name, target, again)
+		}
+		counts[target]++
+		if grown := p.Place(name, 5); grown != target {
+			if grown != 4 {
+				t.Fatalf("Adding a target moved %s from %d to %d", name, target, grown)
+			}
+			moved++
+		}
+	}
+	for i, n := range counts {
+		if n < names/4*9/10 || n > names/4*11/10 {
+			t.Errorf("Target %d got %d of %d names", i, n, names)
+		}
+	}
+	if moved < names/5*9/10 || moved > names/5*11/10 {
+		t.Errorf("Adding a fifth target moved %d of %d names", moved, names)
+	}
+}
@@ -0,0 +1,203 @@
+package memvfs
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// StorageProfile simulates the latency and failures of a storage device, so
+// that code can be tested against realistic device behavior in memory.
+type StorageProfile struct {
+	Name string
+
+	// ReadLatency, WriteLatency, SyncLatency and TruncateLatency are added
+	// to every operation of that kind.
+	ReadLatency     time.Duration
+	WriteLatency    time.Duration
+	SyncLatency     time.Duration
+	TruncateLatency time.Duration
+
+	// Jitter adds a random extra latency of up to Jitter times the base
+	// latency.
+	Jitter float64
+
+	// ReadDistribution, WriteDistribution, SyncDistribution and
+	// TruncateDistribution, if set, draw the latency of every operation of
+	// that kind in place of the fixed latency and Jitter, to model devices
+	// with long tails.
+	ReadDistribution     LatencyDistribution
+	WriteDistribution    LatencyDistribution
+	SyncDistribution     LatencyDistribution
+	TruncateDistribution LatencyDistribution
+
+	// ReadFailure, WriteFailure and SyncFailure are the probabilities, from
+	// 0 to 1, of an operation failing with an IO error.
+	ReadFailure  float64
+	WriteFailure float64
+	SyncFailure  float64
+}
+
+// storageProfiles are the presets returned by StorageProfileByName.
+var storageProfiles = map[string]StorageProfile{
+	"laptop-ssd": {
+		ReadLatency:  50 * time.Microsecond,
+		WriteLatency: 80 * time.Microsecond,
+		SyncLatency:  time.Millisecond,
+		Jitter:       0.5,
+	},
+	"network-fs": {
+		ReadLatency:  time.Millisecond,
+		WriteLatency: 2 * time.Millisecond,
+		SyncLatency:  20 * time.Millisecond,
+		Jitter:       1,
+	},
+	"flaky-sd-card": {
+		ReadLatency:  200 * time.Microsecond,
+		WriteLatency: 2 * time.Millisecond,
+		SyncLatency:  50 * time.Millisecond,
+		Jitter:       2,
+		ReadFailure:  0.001,
+		WriteFailure: 0.005,
+		SyncFailure:  0.01,
+	},
+}
+
+// StorageProfileByName returns a preset profile: "laptop-ssd",
+// "network-fs" or "flaky-sd-card".
+func StorageProfileByName(name string) (*StorageProfile, error) {
+	p, ok := storageProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("memvfs: unknown storage profile %q", name)
+	}
+	p.Name = name
+	return &p, nil
+}
+
+// SetStorageProfile makes every file of the store behave like p. A nil p
+// restores plain memory speed.
+func (v *MemVFS) SetStorageProfile(p *StorageProfile) {
+	v.profile.Store(p)
+}
+
+// WithStorageProfile makes the store behave like p from the start; see
+// SetStorageProfile.
+func WithStorageProfile(p *StorageProfile) Option {
+	return func(v *MemVFS) {
+		v.profile.Store(p)
+	}
+}
+
+// LatencyDistribution draws the latency of a simulated operation; see
+// StorageProfile.
+type LatencyDistribution func() time.Duration
+
+// FixedLatency always takes d.
+func FixedLatency(d time.Duration) LatencyDistribution {
+	return func() time.Duration { return d }
+}
+
+// UniformLatency takes between lo and hi, evenly spread.
+func UniformLatency(lo, hi time.Duration) LatencyDistribution {
+	return func() time.Duration {
+		return lo + time.Duration(rand.Float64()*float64(hi-lo))
+	}
+}
+
+// ExponentialLatency takes mean on average, most operations being quicker
+// and a few much slower.
+func ExponentialLatency(mean time.Duration) LatencyDistribution {
+	return func() time.Duration {
+		return time.Duration(rand.ExpFloat64() * float64(mean))
+	}
+}
+
+// LogNormalLatency takes median for half the operations, with a tail that
+// grows with sigma: at sigma 1 one operation in a hundred takes over ten
+// times the median.
+func LogNormalLatency(median time.Duration, sigma float64) LatencyDistribution {
+	return func() time.Duration {
+		return time.Duration(float64(median) * math.Exp(sigma*rand.NormFloat64()))
+	}
+}
+
+// latency draws the latency of an operation from dist, or adds jitter to
+// the fixed base latency.
+func (p *StorageProfile) latency(base time.Duration, dist LatencyDistribution) time.Duration {
+	if dist != nil {
+		return dist()
+	}
+	if base <= 0 {
+		return 0
+	}
+	return base + time.Duration(rand.Float64()*p.Jitter*float64(base))
+}
+
+// simulate sleeps for latency, cut short at deadline if that is positive,
+// and reports whether the operation should fail with probability failure
+// and whether it timed out.
+func (p *StorageProfile) simulate(latency time.Duration, failure float64, deadline time.Duration) (fail, timedOut bool) {
+	if latency > 0 {
+		if deadline > 0 && latency > deadline {
+			time.Sleep(deadline)
+			return true, true
+		}
+		time.Sleep(latency)
+	}
+	return failure > 0 && rand.Float64() < failure, false
+}
+
+// simulated runs an operation through f under profile p, marking the file
+// degraded if it runs past the IO deadline, and reports whether it fails.
+// The simulate helpers check for injected faults and apply the store's
+// profile this way; they must be called without v.mu held.
+func (v *MemVFS) simulated(f *MemFile, p *StorageProfile, latency time.Duration, failure float64) bool {
+	fail, timedOut := p.simulate(latency, failure, v.ioDeadline)
+	if timedOut {
+		v.degrade(f)
+	}
+	return fail
+}
+
+func (v *MemVFS) simulateRead(f *MemFile) error {
+	if err := v.injected(f, OpRead); err != nil {
+		return err
+	}
+	if p := v.profile.Load(); p != nil && v.simulated(f, p, p.latency(p.ReadLatency, p.ReadDistribution), p.ReadFailure) {
+		return sqlite3vfs.IOErrorRead
+	}
+	return nil
+}
+
+func (v *MemVFS) simulateWrite(f *MemFile) error {
+	if err := v.injected(f, OpWrite); err != nil {
+		return err
+	}
+	if p := v.profile.Load(); p != nil && v.simulated(f, p, p.latency(p.WriteLatency, p.WriteDistribution), p.WriteFailure) {
+		return sqlite3vfs.IOErrorWrite
+	}
+	return nil
+}
+
+func (v *MemVFS) simulateSync(f *MemFile) error {
+	if err := v.injected(f, OpSync); err != nil {
+		return err
+	}
+	if p := v.profile.Load(); p != nil && v.simulated(f, p, p.latency(p.SyncLatency, p.SyncDistribution), p.SyncFailure) {
+		return sqlite3vfs.IOError
+	}
+	return nil
+}
+
+func (v *MemVFS) simulateTruncate(f *MemFile) error {
+	if err := v.injected(f, OpTruncate); err != nil {
+		return err
+	}
+	if p := v.profile.Load(); p != nil {
+		v.simulated(f, p, p.latency(p.TruncateLatency, p.TruncateDistribution), 0)
+	}
+	return nil
+}
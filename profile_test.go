@@ -0,0 +1,104 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestStorageProfile(t *testing.T) {
+	pv := memvfs.New()
+	if err := pv.Register("memvfs-profile"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:test-profile.db?vfs=memvfs-profile&_sync=FULL")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	if _, err := memvfs.StorageProfileByName("floppy"); err == nil {
+		t.Errorf("Expected error for unknown profile")
+	}
+	p, err := memvfs.StorageProfileByName("network-fs")
+	if err != nil {
+		t.Fatalf("StorageProfileByName error: %v", err)
+	}
+	pv.SetStorageProfile(p)
+	start := time.Now()
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('slow')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < p.SyncLatency {
+		t.Errorf("Expected commit to take at least %v, took %v", p.SyncLatency, elapsed)
+	}
+
+	pv.SetStorageProfile(&memvfs.StorageProfile{Name: "broken", WriteFailure: 1})
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('lost')`); err == nil {
+		t.Errorf("Expected insert to fail on a failing device")
+	}
+
+	pv.SetStorageProfile(nil)
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected 1 row, got %d (%v)", count, err)
+	}
+}
+
+func TestLatencyDistribution(t *testing.T) {
+	var syncs int
+	slowSync := func() time.Duration {
+		syncs++
+		return 5 * time.Millisecond
+	}
+	pv := memvfs.New(memvfs.WithStorageProfile(&memvfs.StorageProfile{
+		SyncLatency:      time.Hour,
+		SyncDistribution: slowSync,
+	}))
+	if err := pv.Register("memvfs-latency"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:test-latency.db?vfs=memvfs-latency&_sync=FULL")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	start := time.Now()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	// The distribution replaces the fixed latency rather than adding to it.
+	if elapsed := time.Since(start); syncs == 0 || elapsed < time.Duration(syncs)*5*time.Millisecond || elapsed > time.Minute {
+		t.Errorf("Expected %d syncs of 5ms, took %v", syncs, elapsed)
+	}
+
+	for name, dist := range map[string]memvfs.LatencyDistribution{
+		"fixed":       memvfs.FixedLatency(time.Millisecond),
+		"uniform":     memvfs.UniformLatency(500*time.Microsecond, 1500*time.Microsecond),
+		"exponential": memvfs.ExponentialLatency(time.Millisecond),
+		"log-normal":  memvfs.LogNormalLatency(time.Millisecond, 1),
+	} {
+		var sum time.Duration
+		const n = 10000
+		for range n {
+			d := dist()
+			if d < 0 {
+				t.Fatalf("%s drew a negative latency %v", name, d)
+			}
+			sum += d
+		}
+		// All four center around a millisecond.
+		if mean := sum / n; mean < 800*time.Microsecond || mean > 2*time.Millisecond {
+			t.Errorf("%s: unexpected mean latency %v", name, mean)
+		}
+	}
+}
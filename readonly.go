@@ -0,0 +1,45 @@
+package memvfs
+
+import "github.com/psanford/sqlite3vfs"
+
+// InFlight selects what SetReadOnlyAll does with write transactions that are
+// already in progress.
+type InFlight int
+
+const (
+	// FinishInFlight lets transactions holding a RESERVED lock commit.
+	FinishInFlight InFlight = iota
+
+	// AbortInFlight fails their remaining rollback journal and WAL writes,
+	// so that a transaction which has not yet reached its commit point
+	// fails and rolls back. Transactions without a journal file
+	// (journal_mode=MEMORY or OFF) cannot be rolled back safely from the VFS
+	// and finish regardless.
+	AbortInFlight
+)
+
+// SetReadOnlyAll switches the whole store in or out of read-only maintenance
+// mode. While it is on, starting a write transaction on any database fails
+// with SQLITE_READONLY; reads, including temporary files used by queries,
+// keep working. inFlight defaults to FinishInFlight.
+//
+// Use Freeze to wait for a file's in-flight transactions to finish.
+func (v *MemVFS) SetReadOnlyAll(readOnly bool, inFlight ...InFlight) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.readOnlyAll = readOnly
+	v.abortInFlight = readOnly && len(inFlight) > 0 && inFlight[len(inFlight)-1] == AbortInFlight
+}
+
+// readOnlyWrite reports whether read-only mode rejects a write to e.
+// e must be locked.
+func (v *MemVFS) readOnlyWrite(e *entry) bool {
+	return e.readOnly || v.abortInFlight && (e.role == RoleMainJournal || e.role == RoleWAL)
+}
+
+// readOnlyLock reports whether read-only mode rejects f taking lockType.
+// v.mu must be held.
+func (v *MemVFS) readOnlyLock(f *MemFile, lockType sqlite3vfs.LockType) bool {
+	return v.readOnlyAll && f.lockLevel < sqlite3vfs.LockReserved && lockType >= sqlite3vfs.LockReserved
+}
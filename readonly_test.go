@@ -0,0 +1,72 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestSetReadOnlyAll(t *testing.T) {
+	dbName := "test-readonly-all.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	v.SetReadOnlyAll(true)
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES ('rejected')`)
+	if err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("Expected readonly error, got %v", err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil {
+		t.Errorf("Read in read-only mode failed: %v", err)
+	}
+	v.SetReadOnlyAll(false)
+
+	// An in-flight transaction finishes by default.
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO demo(data) VALUES ('in flight')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	v.SetReadOnlyAll(true)
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Expected in-flight commit to succeed, got %v", err)
+	}
+	v.SetReadOnlyAll(false)
+
+	// With AbortInFlight it fails and rolls back.
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO demo(data) VALUES ('aborted')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	v.SetReadOnlyAll(true, memvfs.AbortInFlight)
+	if err := tx.Commit(); err == nil {
+		t.Errorf("Expected in-flight commit to fail")
+	}
+	tx.Rollback()
+	v.SetReadOnlyAll(false)
+
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected 1 row, got %d (%v)", count, err)
+	}
+	if _, err := db.Exec(`INSERT INTO demo(data) VALUES ('writable')`); err != nil {
+		t.Errorf("Insert after read-only mode error: %v", err)
+	}
+}
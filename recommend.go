@@ -0,0 +1,121 @@
+package memvfs
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// defaultCacheBytes is SQLite's default page cache size, cache_size=-2000.
+const defaultCacheBytes = 2000 * 1024
+
+// Recommendation is a pragma suggested by Recommendations, together with the
+// observed numbers that motivated it.
+type Recommendation struct {
+	Pragma string
+	Value  string
+	Reason string
+}
+
+func (r Recommendation) String() string {
+	return fmt.Sprintf("PRAGMA %s = %s; -- %s", r.Pragma, r.Value, r.Reason)
+}
+
+// Recommendations suggests pragmas for the named database based on the IO
+// memvfs has observed on it and its journal or WAL so far. It returns nothing
+// until there is enough traffic to say anything.
+//
+// WAL is never suggested: memvfs does not implement the shared-memory
+// methods, so journal_mode=WAL only works together with
+// locking_mode=EXCLUSIVE.
+func (v *MemVFS) Recommendations(name string) ([]Recommendation, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := v.thaw(e); err != nil {
+		return nil, err
+	}
+
+	io, sideIO := e.io.load(), e.sideIO.load()
+	var recs []Recommendation
+
+	// Rollback journals live in the same memory as the database, so writing
+	// them through the VFS buys no durability over journal_mode=MEMORY.
+	if e.sideFiles > 0 && sideIO.BytesWritten > 0 {
+		recs = append(recs, Recommendation{
+			Pragma: "journal_mode",
+			Value:  "MEMORY",
+			Reason: fmt.Sprintf("%d journal files written with %d bytes (%.1fx the database's %d bytes written), with no durability gained",
+				e.sideFiles, sideIO.BytesWritten,
+				ratio(sideIO.BytesWritten, io.BytesWritten), io.BytesWritten),
+		})
+	}
+
+	if syncs := io.Syncs + sideIO.Syncs; syncs > 0 {
+		recs = append(recs, Recommendation{
+			Pragma: "synchronous",
+			Value:  "OFF",
+			Reason: fmt.Sprintf("%d syncs issued, each of which is a no-op in memvfs", syncs),
+		})
+	}
+
+	header := e.data
+	if src := e.reader(); src != nil {
+		header = make([]byte, 100)
+		n, _ := src.ReadAt(header, 0)
+		header = header[:n]
+	}
+	size := e.size()
+	pageSize := headerPageSize(header)
+	if pageSize == 0 {
+		return recs, nil
+	}
+
+	if pageSize < 4096 {
+		recs = append(recs, Recommendation{
+			Pragma: "page_size",
+			Value:  "4096",
+			Reason: fmt.Sprintf("%d-byte pages split the %d-byte database into %d pages, each costing a separate read or write",
+				pageSize, size, size/int64(pageSize)),
+		})
+	}
+
+	// Reads repeatedly hitting the same pages of a database larger than
+	// the default cache mean the page cache cannot hold the working set.
+	pages := size / int64(pageSize)
+	if size > defaultCacheBytes && io.Reads > 10*pages {
+		recs = append(recs, Recommendation{
+			Pragma: "cache_size",
+			Value:  fmt.Sprintf("-%d", (size+1023)/1024),
+			Reason: fmt.Sprintf("%d reads against a %d-page database (%.1f reads per page) suggest the default %d KiB cache is evicting its working set",
+				io.Reads, pages, ratio(io.Reads, pages), defaultCacheBytes/1024),
+		})
+	}
+
+	return recs, nil
+}
+
+// headerPageSize returns the page size recorded in a SQLite database header,
+// or 0 if data does not hold a header.
+//
+// https://www.sqlite.org/fileformat.html#the_database_header
+func headerPageSize(data []byte) int {
+	if len(data) < 100 || string(data[:16]) != "SQLite format 3\x00" {
+		return 0
+	}
+	size := int(binary.BigEndian.Uint16(data[16:18]))
+	if size == 1 {
+		return 65536
+	}
+	return size
+}
+
+func ratio(a, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
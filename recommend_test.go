@@ -0,0 +1,44 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func TestRecommendations(t *testing.T) {
+	dbName := "test-recommend.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(100))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	recs, err := v.Recommendations(dbName)
+	if err != nil {
+		t.Fatalf("Recommendations error: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, r := range recs {
+		t.Log(r)
+		got[r.Pragma] = r.Value
+	}
+	if got["journal_mode"] != "MEMORY" {
+		t.Errorf("Expected journal_mode=MEMORY recommendation, got %v", recs)
+	}
+	if got["synchronous"] != "OFF" {
+		t.Errorf("Expected synchronous=OFF recommendation, got %v", recs)
+	}
+}
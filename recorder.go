@@ -0,0 +1,181 @@
+package memvfs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// HandleID identifies an open file handle. Without shared cache, each
+// connection has its own handle on the main database.
+type HandleID uint64
+
+// ErrAmbiguous is returned by ConnHandle when several handles locked the
+// database while it was identifying the connection's, and by FileByUUID
+// when several files share a UUID.
+var ErrAmbiguous = errors.New("memvfs: ambiguous match")
+
+// handleWatch collects the handles that take a SHARED lock on name.
+type handleWatch struct {
+	name    string
+	handles map[HandleID]bool
+}
+
+// ConnHandle returns the handle through which conn accesses the database
+// name. It runs a trivial read on conn and sees which handle takes a lock on
+// name meanwhile, so it fails with ErrAmbiguous if other connections are
+// reading name at the same time; retrying usually helps.
+func (v *MemVFS) ConnHandle(ctx context.Context, conn *sql.Conn, name string) (HandleID, error) {
+	w := &handleWatch{name: name, handles: make(map[HandleID]bool)}
+	v.mu.Lock()
+	v.watches = append(v.watches, w)
+	v.mu.Unlock()
+
+	_, err := conn.ExecContext(ctx, `SELECT count(*) FROM sqlite_master`)
+
+	v.mu.Lock()
+	for i, other := range v.watches {
+		if other == w {
+			v.watches = append(v.watches[:i], v.watches[i+1:]...)
+			break
+		}
+	}
+	v.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	if len(w.handles) != 1 {
+		return 0, fmt.Errorf("%w: %d handles locked %s", ErrAmbiguous, len(w.handles), v.LogName(name))
+	}
+	var h HandleID
+	for id := range w.handles {
+		h = id
+	}
+	return h, nil
+}
+
+// watchLock reports a lock taken by f to the active watches. v.mu must be
+// held.
+func (v *MemVFS) watchLock(f *MemFile, lockType sqlite3vfs.LockType) {
+	for _, w := range v.watches {
+		if w.name == f.fileName && f.lockLevel == sqlite3vfs.LockNone && lockType >= sqlite3vfs.LockShared {
+			w.handles[f.id] = true
+		}
+	}
+}
+
+// PageAccess is a page read or written through a recorded handle.
+type PageAccess struct {
+	Time  time.Time
+	Page  int64 // 1-based, as in SQLite
+	Write bool
+
+	// Label is the handle's label at the time; see Annotate.
+	Label string
+}
+
+// PageRecorder records the pages a handle touches; see RecordPages.
+type PageRecorder struct {
+	v      *MemVFS
+	handle HandleID
+	limit  int
+
+	mu       sync.Mutex
+	accesses []PageAccess
+	dropped  int
+}
+
+// RecordPages starts recording the pages read and written through handle,
+// keeping the first limit accesses. Call Stop to end the recording.
+func (v *MemVFS) RecordPages(handle HandleID, limit int) *PageRecorder {
+	r := &PageRecorder{v: v, handle: handle, limit: limit}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.recorders == nil {
+		v.recorders = make(map[HandleID]*PageRecorder)
+	}
+	v.recorders[handle] = r
+	return r
+}
+
+// Stop ends the recording and returns the accesses recorded, together with
+// the number dropped after limit was reached.
+func (r *PageRecorder) Stop() (accesses []PageAccess, dropped int) {
+	r.v.mu.Lock()
+	if r.v.recorders[r.handle] == r {
+		delete(r.v.recorders, r.handle)
+	}
+	r.v.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.accesses, r.dropped
+}
+
+// record logs an access of n bytes at off through f to e, if f is being
+// recorded. f.mu must be held and e locked.
+func (v *MemVFS) record(f *MemFile, e *entry, off int64, n int, write bool) {
+	if len(v.recorders) == 0 {
+		return
+	}
+	r, ok := v.recorders[f.id]
+	if !ok {
+		return
+	}
+	pageSize := int64(headerPageSize(e.data))
+	if pageSize == 0 {
+		pageSize = int64(max(n, 1))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.accesses) >= r.limit {
+		r.dropped++
+		return
+	}
+	r.accesses = append(r.accesses, PageAccess{Time: time.Now(), Page: off/pageSize + 1, Write: write, Label: f.label})
+}
+
+// Annotate labels the IO of handle, typically a connection's main database
+// handle found with ConnHandle, with an application-meaningful label such
+// as "job=nightly-report". IO through the handle is counted under the label
+// in Stats and carried by page recordings. An empty label removes it.
+func (v *MemVFS) Annotate(handle HandleID, label string) error {
+	v.mu.Lock()
+	var f *MemFile
+	for _, e := range v.files {
+		for h := range e.handles {
+			if h.id == handle {
+				f = h
+			}
+		}
+	}
+	var labelIO *ioCounters
+	if label != "" {
+		if v.labelIO == nil {
+			v.labelIO = make(map[string]*ioCounters)
+		}
+		labelIO = v.labelIO[label]
+		if labelIO == nil {
+			labelIO = new(ioCounters)
+			v.labelIO[label] = labelIO
+		}
+	}
+	v.mu.Unlock()
+
+	if f == nil {
+		return fmt.Errorf("%w: handle %d", ErrNotFound, handle)
+	}
+	f.mu.Lock()
+	f.label = label
+	f.labelIO = labelIO
+	f.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,107 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func TestRecordPages(t *testing.T) {
+	dbName := "test-recorder.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn error: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		_, err = conn.ExecContext(ctx, `INSERT INTO demo(data) VALUES (?)`, randSeq(200))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	h, err := v.ConnHandle(ctx, conn, dbName)
+	if err != nil {
+		t.Fatalf("ConnHandle error: %v", err)
+	}
+
+	rec := v.RecordPages(h, 1)
+	if _, err := conn.ExecContext(ctx, `INSERT INTO demo(data) VALUES ('recorded')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	accesses, dropped := rec.Stop()
+	if len(accesses) != 1 || dropped == 0 {
+		t.Fatalf("Expected 1 access and some dropped, got %d and %d", len(accesses), dropped)
+	}
+	for _, a := range accesses {
+		if a.Page < 1 {
+			t.Errorf("Unexpected page %d", a.Page)
+		}
+	}
+
+	rec = v.RecordPages(h, 100)
+	rec.Stop()
+	if _, err := conn.ExecContext(ctx, `INSERT INTO demo(data) VALUES ('unrecorded')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	if accesses, _ := rec.Stop(); len(accesses) != 0 {
+		t.Errorf("Expected nothing recorded after Stop, got %d", len(accesses))
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	dbName := "test-annotate.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn error: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	h, err := v.ConnHandle(ctx, conn, dbName)
+	if err != nil {
+		t.Fatalf("ConnHandle error: %v", err)
+	}
+	if err := v.Annotate(h, "job=nightly-report"); err != nil {
+		t.Fatalf("Annotate error: %v", err)
+	}
+
+	rec := v.RecordPages(h, 10)
+	if _, err := conn.ExecContext(ctx, `INSERT INTO demo(data) VALUES ('labeled')`); err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	accesses, _ := rec.Stop()
+	if len(accesses) == 0 || accesses[0].Label != "job=nightly-report" {
+		t.Errorf("Expected labeled accesses, got %+v", accesses)
+	}
+	if io := v.Stats().ByLabel["job=nightly-report"]; io.Writes == 0 {
+		t.Errorf("Expected writes counted under the label, got %+v", io)
+	}
+
+	if err := v.Annotate(0, "nobody"); err == nil {
+		t.Errorf("Expected error for unknown handle")
+	}
+}
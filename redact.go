@@ -0,0 +1,64 @@
+package memvfs
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Redactor scrubs data that must not leave the store, such as personal
+// details, from the database name being exported. db is a single
+// connection on a private copy of the database; the original is not
+// affected.
+type Redactor func(name string, db *sql.DB) error
+
+// RedactSQL returns a Redactor that runs stmts in order, such as
+// UPDATE users SET email = NULL or DELETE FROM audit_log.
+func RedactSQL(stmts ...string) Redactor {
+	return func(name string, db *sql.DB) error {
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("%s: %w", stmt, err)
+			}
+		}
+		return nil
+	}
+}
+
+// WithExportRedaction runs redactors, in order, on a private copy of every
+// database exported with ExportArchive, ExportSnapshot, ExportSnapshotFile
+// or Dataset.Export, and exports the redacted copy, so that databases can be
+// shared with support or vendors without leaking customer data. The copy
+// is vacuumed afterwards so that scrubbed rows do not linger in free pages.
+// An export fails if a redactor does. Exports meant to replicate a
+// database, such as WriteFileTo, GetFile and CopyTo, are not redacted.
+func WithExportRedaction(redactors ...Redactor) Option {
+	return func(v *MemVFS) {
+		v.redactors = append(v.redactors, redactors...)
+	}
+}
+
+// redact returns data, the image of name, as v's redactors leave it. data
+// is returned as is, and not copied, if there are none.
+func (v *MemVFS) redact(name string, data []byte) ([]byte, error) {
+	if len(v.redactors) == 0 {
+		return data, nil
+	}
+	p, err := privateStore()
+	if err != nil {
+		return nil, err
+	}
+	scratch, db, done, err := p.openScratchFile("redact", data)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	for _, redact := range v.redactors {
+		if err := redact(name, db); err != nil {
+			return nil, fmt.Errorf("memvfs: redacting %s: %w", v.LogName(name), err)
+		}
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return nil, fmt.Errorf("memvfs: redacting %s: %w", v.LogName(name), err)
+	}
+	return p.copyFile(scratch)
+}
@@ -0,0 +1,75 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestExportRedaction(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:test-redact-src.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE users (name TEXT, email TEXT);
+		INSERT INTO users VALUES ('ann', 'ann@example.com'), ('bob', 'bob@example.com')`)
+	if err != nil {
+		t.Fatalf("Exec error: %v", err)
+	}
+	image, err := v.GetFile("test-redact-src.db")
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+
+	rv := memvfs.New(memvfs.WithExportRedaction(memvfs.RedactSQL(`UPDATE users SET email = NULL`)))
+	if err := rv.PutFile("users.db", image); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	ds, err := rv.Dataset("users.db")
+	if err != nil {
+		t.Fatalf("Dataset error: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := ds.Export(&buf); err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("@example.com")) {
+		t.Errorf("Expected the export to hold no email addresses")
+	}
+	if err := v.PutFile("test-redact-export.db", buf.Bytes()); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	exported, err := sql.Open("sqlite3", "file:test-redact-export.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer exported.Close()
+	var names, emails int
+	if err := exported.QueryRow(`SELECT count(name), count(email) FROM users`).Scan(&names, &emails); err != nil {
+		t.Fatalf("Query error: %v", err)
+	}
+	if names != 2 || emails != 0 {
+		t.Errorf("Expected 2 names and no emails, got %d and %d", names, emails)
+	}
+	if got, _ := rv.GetFile("users.db"); !bytes.Equal(got, image) {
+		t.Errorf("Expected redaction to leave the stored database alone")
+	}
+
+	// A failing redactor fails the export rather than leaking data.
+	errScrub := errors.New("scrub failed")
+	fv := memvfs.New(memvfs.WithExportRedaction(func(name string, db *sql.DB) error {
+		return fmt.Errorf("%s: %w", name, errScrub)
+	}))
+	if err := fv.PutFile("users.db", image); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+	buf.Reset()
+	if _, err := fv.ExportArchive(&buf, memvfs.NamespaceRules{}); !errors.Is(err, errScrub) {
+		t.Errorf("Expected the redactor's error, got %v", err)
+	}
+}
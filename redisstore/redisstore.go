@@ -0,0 +1,318 @@
+// Package redisstore is a memvfs.BackingStore keeping databases in Redis,
+// so that small databases can be shared by the instances of a service and
+// rehydrated quickly when one starts.
+//
+// A database is kept as chunks of at most Config.ChunkSize bytes, each a
+// value of its own so that no value grows past what Redis handles well,
+// and a manifest key naming the chunks. Storing writes a new generation of
+// chunks before switching the manifest over to it, so readers never see a
+// half-stored database. It speaks RESP over a single connection and needs
+// no client library.
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+// DefaultChunkSize is the size of the chunks databases are split into.
+const DefaultChunkSize = 512 << 10
+
+// Config locates the Redis server.
+type Config struct {
+	// Addr is the host:port of the server.
+	Addr string
+
+	// Username and Password authenticate with AUTH if Password is set; DB
+	// is the database selected.
+	Username string
+	Password string
+	DB       int
+
+	// Prefix is prepended to every key, so that several stores can share
+	// a server.
+	Prefix string
+
+	// ChunkSize is DefaultChunkSize if zero.
+	ChunkSize int
+
+	// Dial connects to Addr, a net.Dialer if nil; set it to use TLS.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Store is a memvfs.BackingStore in Redis.
+type Store struct {
+	cfg Config
+
+	// mu serializes commands on conn, which is dialed on first use and
+	// dropped after any error.
+	mu   sync.Mutex
+	conn *conn
+}
+
+var _ memvfs.BackingStore = (*Store)(nil)
+
+// New returns a Store for the server cfg describes. It does not connect.
+func New(cfg Config) *Store {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.Dial == nil {
+		var d net.Dialer
+		cfg.Dial = d.DialContext
+	}
+	return &Store{cfg: cfg}
+}
+
+// Close closes the connection to the server, if any.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.c.Close()
+	s.conn = nil
+	return err
+}
+
+// manifest describes the stored chunks of a database.
+type manifest struct {
+	Generation string `json:"generation"`
+	Size       int64  `json:"size"`
+	Chunks     int    `json:"chunks"`
+	SHA256     string `json:"sha256"`
+}
+
+func (s *Store) manifestKey(name string) string {
+	return s.cfg.Prefix + "manifest:" + name
+}
+
+func (s *Store) chunkKey(name string, m manifest, i int) string {
+	return s.cfg.Prefix + "chunk:" + name + ":" + m.Generation + ":" + strconv.Itoa(i)
+}
+
+func (s *Store) chunkKeys(name string, m manifest) []string {
+	keys := make([]string, m.Chunks)
+	for i := range keys {
+		keys[i] = s.chunkKey(name, m, i)
+	}
+	return keys
+}
+
+func (s *Store) Load(ctx context.Context, name string) ([]byte, error) {
+	// A concurrent Store deletes the chunks it replaces, possibly before
+	// they were read; the manifest is read again then.
+	for attempt := 0; ; attempt++ {
+		m, err := s.manifest(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if m.Chunks == 0 {
+			return []byte{}, nil
+		}
+		reply, err := s.do(ctx, append(args("MGET"), args(s.chunkKeys(name, m)...)...)...)
+		if err != nil {
+			return nil, err
+		}
+		chunks, _ := reply.([]any)
+		data := make([]byte, 0, m.Size)
+		complete := len(chunks) == m.Chunks
+		for _, c := range chunks {
+			b, ok := c.([]byte)
+			complete = complete && ok
+			data = append(data, b...)
+		}
+		if !complete && attempt < 3 {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if !complete || int64(len(data)) != m.Size || hex.EncodeToString(sum[:]) != m.SHA256 {
+			return nil, fmt.Errorf("redisstore: %s: stored chunks do not match their manifest", name)
+		}
+		return data, nil
+	}
+}
+
+// manifest returns the manifest of name.
+func (s *Store) manifest(ctx context.Context, name string) (manifest, error) {
+	var m manifest
+	reply, err := s.do(ctx, args("GET", s.manifestKey(name))...)
+	if err != nil {
+		return m, err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return m, fmt.Errorf("%w: %s", memvfs.ErrNotFound, name)
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("redisstore: %s: manifest: %w", name, err)
+	}
+	return m, nil
+}
+
+func (s *Store) Store(ctx context.Context, name string, data []byte) error {
+	old, err := s.manifest(ctx, name)
+	if err != nil && !errors.Is(err, memvfs.ErrNotFound) {
+		return err
+	}
+
+	var gen [8]byte
+	if _, err := rand.Read(gen[:]); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	m := manifest{
+		Generation: hex.EncodeToString(gen[:]),
+		Size:       int64(len(data)),
+		Chunks:     (len(data) + s.cfg.ChunkSize - 1) / s.cfg.ChunkSize,
+		SHA256:     hex.EncodeToString(sum[:]),
+	}
+	for i := range m.Chunks {
+		chunk := data[i*s.cfg.ChunkSize : min((i+1)*s.cfg.ChunkSize, len(data))]
+		if _, err := s.do(ctx, []byte("SET"), []byte(s.chunkKey(name, m, i)), chunk); err != nil {
+			return err
+		}
+	}
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := s.do(ctx, []byte("SET"), []byte(s.manifestKey(name)), encoded); err != nil {
+		return err
+	}
+	if old.Chunks > 0 {
+		if _, err := s.do(ctx, append(args("DEL"), args(s.chunkKeys(name, old)...)...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, name string) error {
+	m, err := s.manifest(ctx, name)
+	if errors.Is(err, memvfs.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keys := append([]string{s.manifestKey(name)}, s.chunkKeys(name, m)...)
+	_, err = s.do(ctx, append(args("DEL"), args(keys...)...)...)
+	return err
+}
+
+// List finds the manifests with SCAN, which walks every key of the
+// server's database however few match.
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	pattern := globEscape(s.manifestKey(prefix)) + "*"
+	var names []string
+	cursor := "0"
+	for {
+		reply, err := s.do(ctx, args("SCAN", cursor, "MATCH", pattern, "COUNT", "1000")...)
+		if err != nil {
+			return nil, err
+		}
+		page, _ := reply.([]any)
+		if len(page) != 2 {
+			return nil, errors.New("redisstore: malformed SCAN reply")
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]any)
+		for _, k := range keys {
+			if key, ok := k.([]byte); ok {
+				names = append(names, strings.TrimPrefix(string(key), s.manifestKey("")))
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+	// SCAN may return a key more than once.
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// globEscape escapes the characters SCAN's MATCH treats specially.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// do runs a command on the connection, dialing it first if needed.
+func (s *Store) do(ctx context.Context, cmd ...[]byte) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		c, err := s.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = c
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.c.SetDeadline(deadline)
+	} else {
+		s.conn.c.SetDeadline(time.Time{})
+	}
+	reply, err := s.conn.do(cmd...)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		// The connection is in an unknown state.
+		s.conn.c.Close()
+		s.conn = nil
+	}
+	return reply, err
+}
+
+// dial connects to the server, authenticates and selects the database.
+func (s *Store) dial(ctx context.Context) (*conn, error) {
+	nc, err := s.cfg.Dial(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	c := &conn{c: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	var setup [][][]byte
+	switch {
+	case s.cfg.Password != "" && s.cfg.Username != "":
+		setup = append(setup, args("AUTH", s.cfg.Username, s.cfg.Password))
+	case s.cfg.Password != "":
+		setup = append(setup, args("AUTH", s.cfg.Password))
+	}
+	if s.cfg.DB != 0 {
+		setup = append(setup, args("SELECT", strconv.Itoa(s.cfg.DB)))
+	}
+	for _, cmd := range setup {
+		if _, err := c.do(cmd...); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
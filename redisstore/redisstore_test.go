@@ -0,0 +1,229 @@
+package redisstore_test
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/hleng1/memvfs/redisstore"
+)
+
+// fakeRedis serves the commands the store uses from memory.
+type fakeRedis struct {
+	mu       sync.Mutex
+	values   map[string][]byte
+	password string
+}
+
+func (f *fakeRedis) serve(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(c)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func (f *fakeRedis) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(string(cmd[0]))
+		if !authed && name != "AUTH" {
+			io.WriteString(c, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		f.mu.Lock()
+		switch name {
+		case "AUTH":
+			authed = string(cmd[len(cmd)-1]) == f.password
+			if authed {
+				io.WriteString(c, "+OK\r\n")
+			} else {
+				io.WriteString(c, "-WRONGPASS invalid password\r\n")
+			}
+		case "GET":
+			writeBulk(c, f.values[string(cmd[1])])
+		case "SET":
+			f.values[string(cmd[1])] = cmd[2]
+			io.WriteString(c, "+OK\r\n")
+		case "MGET":
+			fmt.Fprintf(c, "*%d\r\n", len(cmd)-1)
+			for _, key := range cmd[1:] {
+				writeBulk(c, f.values[string(key)])
+			}
+		case "DEL":
+			for _, key := range cmd[1:] {
+				delete(f.values, string(key))
+			}
+			fmt.Fprintf(c, ":%d\r\n", len(cmd)-1)
+		case "SCAN":
+			// Everything in one page; only trailing * patterns are used.
+			prefix := strings.TrimSuffix(string(cmd[3]), "*")
+			prefix = strings.ReplaceAll(prefix, `\`, "")
+			var keys []string
+			for key := range f.values {
+				if strings.HasPrefix(key, prefix) {
+					keys = append(keys, key)
+				}
+			}
+			fmt.Fprintf(c, "*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+			for _, key := range keys {
+				writeBulk(c, []byte(key))
+			}
+		default:
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", name)
+		}
+		f.mu.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([][]byte, n)
+	for i := range cmd {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		cmd[i] = buf[:size]
+	}
+	return cmd, nil
+}
+
+func writeBulk(w io.Writer, data []byte) {
+	if data == nil {
+		io.WriteString(w, "$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(data), data)
+}
+
+func TestStore(t *testing.T) {
+	fake := &fakeRedis{values: make(map[string][]byte), password: "secret"}
+	addr := fake.serve(t)
+	store := redisstore.New(redisstore.Config{
+		Addr:      addr,
+		Password:  "secret",
+		Prefix:    "memvfs:",
+		ChunkSize: 4096,
+	})
+	defer store.Close()
+	ctx := context.Background()
+	if _, err := store.Load(ctx, "missing.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	rv := memvfs.New(memvfs.WithBackingStore(store), memvfs.WithRetainOnClose())
+	if err := rv.Register("memvfs-redisstore"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:shared.db?vfs=memvfs-redisstore")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data BLOB)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (randomblob(300))`); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	db.Close()
+	if _, err := rv.Flush(nil, nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	want, err := rv.GetFile("shared.db")
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+
+	// Storing again replaces the chunks rather than adding to them.
+	if err := store.Store(ctx, "shared.db", want); err != nil {
+		t.Fatalf("Store error: %v", err)
+	}
+	fake.mu.Lock()
+	chunks := 0
+	for key := range fake.values {
+		if strings.HasPrefix(key, "memvfs:chunk:shared.db:") {
+			chunks++
+		}
+	}
+	fake.mu.Unlock()
+	if wantChunks := (len(want) + 4095) / 4096; chunks != wantChunks {
+		t.Errorf("Expected %d chunks, got %d", wantChunks, chunks)
+	}
+
+	// Another instance rehydrates it.
+	other := memvfs.New(memvfs.WithBackingStore(redisstore.New(redisstore.Config{
+		Addr:     addr,
+		Password: "secret",
+		Prefix:   "memvfs:",
+	})))
+	loaded, err := other.Hydrate(ctx, "")
+	if err != nil {
+		t.Fatalf("Hydrate error: %v", err)
+	}
+	if !slices.Equal(loaded, []string{"shared.db"}) {
+		t.Errorf("Hydrated %v", loaded)
+	}
+	got, err := other.GetFile("shared.db")
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("Expected the rehydrated database to match, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "shared.db"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	fake.mu.Lock()
+	if len(fake.values) != 0 {
+		t.Errorf("Expected Delete to remove every key, %d left", len(fake.values))
+	}
+	fake.mu.Unlock()
+
+	wrong := redisstore.New(redisstore.Config{Addr: addr, Password: "wrong"})
+	defer wrong.Close()
+	if _, err := wrong.Load(ctx, "shared.db"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected authentication to fail, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package redisstore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// conn is a connection speaking RESP, the Redis protocol, one command at a
+// time.
+//
+// https://redis.io/docs/latest/develop/reference/protocol-spec/
+type conn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// redisError is an error reply.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redisstore: " + string(e)
+}
+
+// do sends a command and returns its reply: a string for simple strings, a
+// []byte or nil for bulk strings, an int64 for integers and an []any or nil
+// for arrays. An error reply is returned as a redisError.
+func (c *conn) do(args ...[]byte) (any, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n", len(arg))
+		c.w.Write(arg)
+		c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	reply, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// read reads a reply. Error replies are returned as values so that those
+// nested in arrays are read in full.
+func (c *conn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redisstore: malformed reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return redisError(line), nil
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redisstore: unexpected reply type %q", kind)
+	}
+}
+
+// args converts strings to command arguments.
+func args(s ...string) [][]byte {
+	b := make([][]byte, len(s))
+	for i := range s {
+		b[i] = []byte(s[i])
+	}
+	return b
+}
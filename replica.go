@@ -0,0 +1,84 @@
+package memvfs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ReplicaCheck is the outcome of comparing a replica with its source.
+type ReplicaCheck struct {
+	Name string
+	// Diverged lists the chunks of the source's manifest that the replica
+	// did not hold. Healed reports that they were pulled again.
+	Diverged []int
+	Healed   bool
+	Progress TransferProgress
+}
+
+// VerifyReplica compares name, a copy pulled from src, with src chunk by
+// chunk and, if they differ, pulls src into name again. Only the diverged
+// chunks are fetched, as Pull reuses the ones name still holds. A replica
+// that is in use cannot be replaced, so healing then fails with ErrBusy and
+// is left to a later check.
+func (v *MemVFS) VerifyReplica(ctx context.Context, name string, src ChunkSource) (ReplicaCheck, error) {
+	c := ReplicaCheck{Name: name}
+	m, err := src.Manifest(ctx)
+	if err != nil {
+		return c, err
+	}
+	local, err := v.Manifest(name, m.ChunkSize)
+	if err != nil {
+		return c, err
+	}
+	for i, sum := range m.Chunks {
+		if i >= len(local.Chunks) || local.Chunks[i] != sum {
+			c.Diverged = append(c.Diverged, i)
+		}
+	}
+	if len(c.Diverged) == 0 && local.Size == m.Size {
+		return c, nil
+	}
+
+	c.Progress, err = v.Pull(ctx, name, src)
+	c.Healed = err == nil
+	return c, err
+}
+
+// WatchReplica runs VerifyReplica on name every interval and passes each
+// check that found a divergence or failed to report, so silent divergence
+// from src is noticed and healed. stop ends the checks.
+func (v *MemVFS) WatchReplica(name string, src ChunkSource, interval time.Duration, report func(ReplicaCheck, error)) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, errInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c, err := v.VerifyReplica(ctx, name, src)
+				if (err != nil || len(c.Diverged) > 0 || c.Healed) && ctx.Err() == nil {
+					if err != nil {
+						v.log(slog.LevelWarn, "replica check failed", "file", v.LogName(name), "error", err)
+					} else {
+						v.log(slog.LevelInfo, "replica diverged", "file", v.LogName(name), "chunks", len(c.Diverged), "healed", c.Healed)
+					}
+					if report != nil {
+						report(c, err)
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
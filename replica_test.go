@@ -0,0 +1,75 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestVerifyReplica(t *testing.T) {
+	sv := memvfs.New()
+	data := []byte(randSeq(4 * 4096))
+	err := sv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("primary.db", data)
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	src := memvfs.FileChunkSource{V: sv, Name: "primary.db", ChunkSize: 4096}
+
+	rv := memvfs.New()
+	ctx := context.Background()
+	if _, err := rv.Pull(ctx, "replica.db", src); err != nil {
+		t.Fatalf("Pull error: %v", err)
+	}
+	c, err := rv.VerifyReplica(ctx, "replica.db", src)
+	if err != nil || len(c.Diverged) != 0 || c.Healed {
+		t.Errorf("Expected a matching replica, got %+v (%v)", c, err)
+	}
+
+	corrupt := func() {
+		bad := bytes.Clone(data)
+		bad[2*4096+10] ^= 0xff
+		err := rv.Batch(func(tx *memvfs.AdminTx) error {
+			return tx.Put("replica.db", bad)
+		})
+		if err != nil {
+			t.Fatalf("Batch error: %v", err)
+		}
+	}
+	corrupt()
+	c, err = rv.VerifyReplica(ctx, "replica.db", src)
+	if err != nil {
+		t.Fatalf("VerifyReplica error: %v", err)
+	}
+	if len(c.Diverged) != 1 || c.Diverged[0] != 2 || !c.Healed || c.Progress.ChunksFetched != 1 {
+		t.Errorf("Expected chunk 2 to be healed, got %+v", c)
+	}
+	if got, _ := rv.GetFile("replica.db"); !bytes.Equal(got, data) {
+		t.Errorf("Healed replica differs from primary")
+	}
+
+	corrupt()
+	reports := make(chan memvfs.ReplicaCheck, 1)
+	stop, err := rv.WatchReplica("replica.db", src, 10*time.Millisecond, func(c memvfs.ReplicaCheck, err error) {
+		select {
+		case reports <- c:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("WatchReplica error: %v", err)
+	}
+	defer stop()
+	select {
+	case c := <-reports:
+		if !c.Healed {
+			t.Errorf("Expected the watcher to heal the replica, got %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Divergence was not reported")
+	}
+}
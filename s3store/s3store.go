@@ -0,0 +1,295 @@
+// Package s3store is a memvfs.BackingStore keeping databases as objects in
+// an S3 bucket, or in any service speaking the S3 API such as MinIO, so that
+// a store can load databases lazily from object storage and persist them
+// back with Flush or AutoFlush.
+//
+// It talks to the service over plain HTTP with Signature Version 4 and
+// path-style addressing, and needs no SDK.
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+// Config locates the bucket and holds the credentials to access it.
+type Config struct {
+	// Endpoint is the base URL of the service, such as
+	// https://s3.us-east-1.amazonaws.com or http://localhost:9000.
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	// Prefix is prepended to database names to make object keys, so that
+	// several stores can share a bucket; "databases/" keeps a.db as
+	// databases/a.db.
+	Prefix string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Store is a memvfs.BackingStore in an S3 bucket.
+type Store struct {
+	cfg      Config
+	endpoint *url.URL
+}
+
+var _ memvfs.BackingStore = (*Store)(nil)
+
+// New returns a Store for the bucket cfg describes. It does not contact the
+// service.
+func New(cfg Config) (*Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3store: endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3store: endpoint %q is not an absolute URL", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("s3store: bucket and region are required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Store{cfg: cfg, endpoint: endpoint}, nil
+}
+
+func (s *Store) Load(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.cfg.Prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (s *Store) Store(ctx context.Context, name string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, s.cfg.Prefix+name, nil, data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Delete deletes name's object. S3 reports success for missing objects.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.cfg.Prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// listResult is the part of a ListObjectsV2 response used by List.
+type listResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {s.cfg.Prefix + prefix},
+	}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3store: list: %w", err)
+		}
+		for _, c := range result.Contents {
+			names = append(names, strings.TrimPrefix(c.Key, s.cfg.Prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string // such as NoSuchKey or AccessDenied
+	Message    string
+	Key        string `xml:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3store: %s: HTTP %d", e.Key, e.StatusCode)
+	}
+	return fmt.Sprintf("s3store: %s: %s: %s", e.Key, e.Code, e.Message)
+}
+
+// Is makes a missing object match memvfs.ErrNotFound.
+func (e *Error) Is(target error) bool {
+	return target == memvfs.ErrNotFound && e.StatusCode == http.StatusNotFound && e.Code != "NoSuchBucket"
+}
+
+// do sends a signed request for key, or the bucket if key is empty, and
+// returns the response if it succeeded.
+func (s *Store) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, body, time.Now())
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode, Key: key}
+	if key == "" {
+		e.Key = s.cfg.Bucket
+	}
+	// Error responses carry an XML body naming the error; a missing or
+	// malformed one leaves the status code to speak for itself.
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(e)
+	return nil, e
+}
+
+// sign adds Signature Version 4 authentication to req, sent at now.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *Store) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath escapes path as Signature Version 4 requires of S3 keys:
+// every byte but unreserved characters and slashes is percent-encoded.
+func escapePath(path string) string {
+	return escape(path, true)
+}
+
+// canonicalQuery encodes query sorted by key, escaped as Signature Version
+// 4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var parts []string
+	for _, k := range keys {
+		values := slices.Clone(query[k])
+		slices.Sort(values)
+		for _, v := range values {
+			parts = append(parts, escape(k, false)+"="+escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
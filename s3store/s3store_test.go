@@ -0,0 +1,152 @@
+package s3store_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/hleng1/memvfs/s3store"
+)
+
+// fakeS3 serves one bucket from memory, listing two keys per page.
+type fakeS3 struct {
+	t       *testing.T
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		f.t.Errorf("Unsigned request: %s %s", r.Method, r.URL)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	switch {
+	case !ok && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r)
+	case !ok:
+		http.Error(w, "", http.StatusBadRequest)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+	end := min(start+2, len(keys))
+	type content struct{ Key string }
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{IsTruncated: end < len(keys)}
+	for _, key := range keys[start:end] {
+		result.Contents = append(result.Contents, content{key})
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func TestStore(t *testing.T) {
+	fake := &fakeS3{t: t, bucket: "dbs", objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := s3store.New(s3store.Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "dbs",
+		Prefix:          "prod/",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := store.Load(ctx, "missing.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	sv := memvfs.New(memvfs.WithBackingStore(store), memvfs.WithRetainOnClose())
+	if err := sv.Register("memvfs-s3store"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	for _, name := range []string{"a.db", "b.db", "tenant one/c.db"} {
+		db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-s3store")
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		if _, err := db.Exec(`CREATE TABLE demo (name TEXT); INSERT INTO demo VALUES (?)`, name); err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+		db.Close()
+	}
+	if _, err := sv.Flush(nil, nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if _, ok := fake.objects["prod/tenant one/c.db"]; !ok {
+		t.Errorf("Expected objects under the prefix, got %d objects", len(fake.objects))
+	}
+
+	// Listing pages through the bucket.
+	names, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if want := []string{"a.db", "b.db", "tenant one/c.db"}; !slices.Equal(names, want) {
+		t.Errorf("Listed %v, want %v", names, want)
+	}
+
+	restored := memvfs.New(memvfs.WithBackingStore(store))
+	loaded, err := restored.Hydrate(ctx, "tenant one/")
+	if err != nil || len(loaded) != 1 {
+		t.Fatalf("Hydrate returned %v, %v", loaded, err)
+	}
+	data, err := restored.GetFile("tenant one/c.db")
+	if err != nil || !slices.Equal(data, fake.objects["prod/tenant one/c.db"]) {
+		t.Errorf("Expected the hydrated database to match its object, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "a.db"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := store.Load(ctx, "a.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+}
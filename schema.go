@@ -0,0 +1,119 @@
+package memvfs
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+)
+
+// SchemaObject is a table, index, view or trigger of a database schema.
+type SchemaObject struct {
+	Type  string
+	Name  string
+	Table string
+	SQL   string
+}
+
+// SchemaChange is an object defined differently in two schemas.
+type SchemaChange struct {
+	A, B SchemaObject
+}
+
+// SchemaDiff is the outcome of CompareSchemas. Each list is sorted by type
+// and name.
+type SchemaDiff struct {
+	OnlyInA []SchemaObject
+	OnlyInB []SchemaObject
+	Changed []SchemaChange
+}
+
+// Equal reports whether the schemas compared alike.
+func (d SchemaDiff) Equal() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+// CompareSchemas compares the schemas of the databases nameA and nameB, read
+// from consistent copies, to find databases that drifted from the one
+// taken as reference. Objects are matched by type and name and compared by
+// their SQL, ignoring differences in whitespace; SQLite's internal objects
+// are left out. v must have been registered with Register.
+func (v *MemVFS) CompareSchemas(ctx context.Context, nameA, nameB string) (SchemaDiff, error) {
+	var diff SchemaDiff
+	a, err := v.schema(ctx, nameA)
+	if err != nil {
+		return diff, err
+	}
+	b, err := v.schema(ctx, nameB)
+	if err != nil {
+		return diff, err
+	}
+
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		var c int
+		switch {
+		case i == len(a):
+			c = 1
+		case j == len(b):
+			c = -1
+		default:
+			c = compareSchemaObjects(a[i], b[j])
+		}
+		switch {
+		case c < 0:
+			diff.OnlyInA = append(diff.OnlyInA, a[i])
+			i++
+		case c > 0:
+			diff.OnlyInB = append(diff.OnlyInB, b[j])
+			j++
+		default:
+			if normalizeSQL(a[i].SQL) != normalizeSQL(b[j].SQL) || a[i].Table != b[j].Table {
+				diff.Changed = append(diff.Changed, SchemaChange{A: a[i], B: b[j]})
+			}
+			i++
+			j++
+		}
+	}
+	return diff, nil
+}
+
+// schema returns the objects of name's schema, sorted by type and name.
+func (v *MemVFS) schema(ctx context.Context, name string) ([]SchemaObject, error) {
+	db, done, err := v.scratchDB(name, "schema")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return schemaObjects(ctx, db)
+}
+
+// schemaObjects returns the objects of db's schema, sorted by type and name.
+func schemaObjects(ctx context.Context, db *sql.DB) ([]SchemaObject, error) {
+	rows, err := db.QueryContext(ctx, `SELECT type, name, tbl_name, coalesce(sql, '')
+		FROM sqlite_schema WHERE name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var objects []SchemaObject
+	for rows.Next() {
+		var o SchemaObject
+		if err := rows.Scan(&o.Type, &o.Name, &o.Table, &o.SQL); err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	slices.SortFunc(objects, compareSchemaObjects)
+	return objects, rows.Err()
+}
+
+func compareSchemaObjects(a, b SchemaObject) int {
+	return cmp.Or(strings.Compare(a.Type, b.Type), strings.Compare(a.Name, b.Name))
+}
+
+// normalizeSQL collapses the whitespace in sql, which SQLite keeps as
+// written.
+func normalizeSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,69 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	ctx := context.Background()
+	create := func(name string, stmts ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name))
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+		return db
+	}
+	a := create("test-schema-a.db",
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)`,
+		`CREATE INDEX users_email ON users(email)`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER)`)
+	defer a.Close()
+	b := create("test-schema-b.db",
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY,   user_id INTEGER)`,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)`,
+		`CREATE INDEX users_email ON users(email)`)
+	defer b.Close()
+
+	diff, err := v.CompareSchemas(ctx, "test-schema-a.db", "test-schema-b.db")
+	if err != nil {
+		t.Fatalf("CompareSchemas error: %v", err)
+	}
+	if !diff.Equal() {
+		t.Errorf("Expected schemas differing in order and whitespace to match, got %+v", diff)
+	}
+
+	for _, stmt := range []string{
+		`DROP INDEX users_email`,
+		`ALTER TABLE orders ADD COLUMN total REAL`,
+		`CREATE INDEX orders_user ON orders(user_id)`,
+	} {
+		if _, err := b.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	diff, err = v.CompareSchemas(ctx, "test-schema-a.db", "test-schema-b.db")
+	if err != nil {
+		t.Fatalf("CompareSchemas error: %v", err)
+	}
+	if len(diff.OnlyInA) != 1 || diff.OnlyInA[0].Name != "users_email" || diff.OnlyInA[0].Type != "index" {
+		t.Errorf("Expected the dropped index only in A, got %+v", diff.OnlyInA)
+	}
+	if len(diff.OnlyInB) != 1 || diff.OnlyInB[0].Name != "orders_user" || diff.OnlyInB[0].Table != "orders" {
+		t.Errorf("Expected the new index only in B, got %+v", diff.OnlyInB)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].A.Name != "orders" || diff.Changed[0].B.SQL == diff.Changed[0].A.SQL {
+		t.Errorf("Expected the altered table to be changed, got %+v", diff.Changed)
+	}
+
+	if _, err := v.CompareSchemas(ctx, "test-schema-a.db", "test-schema-missing.db"); err == nil {
+		t.Errorf("Expected an error comparing with a missing database")
+	}
+}
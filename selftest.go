@@ -0,0 +1,271 @@
+package memvfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SelfTestReport is the outcome of SelfTest.
+type SelfTestReport struct {
+	// SQLiteVersion is the version of the SQLite library linked in, and
+	// Platform the GOOS/GOARCH pair the process runs on.
+	SQLiteVersion string
+	Platform      string
+
+	Checks []SelfTestCheck
+
+	// The capabilities only some platforms have.
+	MapDiskFile  bool
+	Handoff      bool
+	SharedMemory bool
+}
+
+// SelfTestCheck is the outcome of one operation exercised by SelfTest.
+type SelfTestCheck struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// OK reports whether every check passed.
+func (r SelfTestReport) OK() bool {
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// selfTestSeq numbers the VFS names registered by SelfTest, as SQLite
+// offers no way to unregister one.
+var selfTestSeq atomic.Int64
+
+// SelfTest registers a fresh store under a temporary VFS name and runs a
+// battery of SQLite operations on it: transactions, rollback, VACUUM, large
+// blobs and concurrent writers. It reports the outcome of each along with
+// the SQLite version and the platform capabilities available, so that a
+// service can catch a broken driver or platform integration at startup
+// rather than in production queries. The returned error joins the errors of
+// the failed checks.
+func SelfTest(ctx context.Context) (SelfTestReport, error) {
+	report := SelfTestReport{Platform: runtime.GOOS + "/" + runtime.GOARCH}
+
+	v := New()
+	err := v.Register(fmt.Sprintf("memvfs-selftest-%d", selfTestSeq.Add(1)))
+	if err != nil {
+		return report, err
+	}
+	// The VFS stays registered, so its files are dropped once done.
+	defer func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		for name, e := range v.files {
+			e.release()
+			v.forget(e)
+			delete(v.files, name)
+		}
+	}()
+	// Overlays outlive their connections, so each check can open and
+	// close as many as it needs.
+	if err := v.Overlay(bytes.NewReader(nil), 0, "selftest.db"); err != nil {
+		return report, err
+	}
+	db, err := v.openDB("selftest.db", "")
+	if err != nil {
+		return report, err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := db.QueryRowContext(ctx, `SELECT sqlite_version()`).Scan(&report.SQLiteVersion); err != nil {
+		return report, err
+	}
+
+	checks := []struct {
+		name string
+		run  func(ctx context.Context, v *MemVFS, db *sql.DB) error
+	}{
+		{"transaction", selfTestTransaction},
+		{"rollback", selfTestRollback},
+		{"vacuum", selfTestVacuum},
+		{"big-blob", selfTestBigBlob},
+		{"concurrent-writers", selfTestConcurrentWriters},
+	}
+	var errs []error
+	for _, c := range checks {
+		start := time.Now()
+		err := c.run(ctx, v, db)
+		report.Checks = append(report.Checks, SelfTestCheck{
+			Name:     c.name,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+
+	// The platform specific features return ErrUnsupported where they are
+	// missing, and fail on the bogus arguments otherwise.
+	report.MapDiskFile = !errors.Is(v.MapDiskFile(filepath.Join("memvfs-selftest", "missing"), "probe.db"), errors.ErrUnsupported)
+	report.Handoff = !errors.Is(New().Handoff(&net.UnixConn{}), errors.ErrUnsupported)
+	report.SharedMemory = !errors.Is(v.AttachShm("memvfs-selftest-missing", "probe.db"), errors.ErrUnsupported)
+
+	return report, errors.Join(errs...)
+}
+
+func selfTestCount(ctx context.Context, db *sql.DB, table string) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM `+table).Scan(&n)
+	return n, err
+}
+
+func selfTestTransaction(ctx context.Context, v *MemVFS, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE t (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := range 1000 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO t(data) VALUES (?)`, fmt.Sprint(i)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if n, err := selfTestCount(ctx, db, "t"); err != nil || n != 1000 {
+		return errors.Join(err, fmt.Errorf("found %d of 1000 committed rows", n))
+	}
+	return nil
+}
+
+func selfTestRollback(ctx context.Context, v *MemVFS, db *sql.DB) error {
+	before, err := selfTestCount(ctx, db, "t")
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM t`); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	if n, err := selfTestCount(ctx, db, "t"); err != nil || n != before {
+		return errors.Join(err, fmt.Errorf("found %d of %d rows after rollback", n, before))
+	}
+	return nil
+}
+
+func selfTestVacuum(ctx context.Context, v *MemVFS, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM t WHERE id % 2 = 0`); err != nil {
+		return err
+	}
+	before, err := v.Stat("selftest.db")
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM`); err != nil {
+		return err
+	}
+	after, err := v.Stat("selftest.db")
+	if err != nil {
+		return err
+	}
+	if after.Size >= before.Size {
+		return fmt.Errorf("VACUUM left the file at %d bytes, from %d", after.Size, before.Size)
+	}
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check after VACUUM: %s", result)
+	}
+	return nil
+}
+
+func selfTestBigBlob(ctx context.Context, v *MemVFS, db *sql.DB) error {
+	blob := make([]byte, 8<<20)
+	rand.Read(blob)
+	if _, err := db.ExecContext(ctx, `CREATE TABLE blobs (data BLOB)`); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO blobs VALUES (?)`, blob); err != nil {
+		return err
+	}
+	var got []byte
+	if err := db.QueryRowContext(ctx, `SELECT data FROM blobs`).Scan(&got); err != nil {
+		return err
+	}
+	if !bytes.Equal(got, blob) {
+		return errors.New("blob read back differs")
+	}
+	return nil
+}
+
+// selfTestConcurrentWriters writes from separate connections without a
+// shared cache, so that they contend through the VFS locks.
+func selfTestConcurrentWriters(ctx context.Context, v *MemVFS, _ *sql.DB) error {
+	const writers, rows = 4, 50
+	if err := v.Overlay(bytes.NewReader(nil), 0, "selftest-writers.db"); err != nil {
+		return err
+	}
+	dsn := fmt.Sprintf("file:selftest-writers.db?vfs=%s&_busy_timeout=10000&_txlock=immediate", v.vfsName)
+	setup, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return err
+	}
+	defer setup.Close()
+	if _, err := setup.ExecContext(ctx, `CREATE TABLE w (writer INTEGER, n INTEGER)`); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := sql.Open("sqlite3", dsn)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer db.Close()
+			for n := range rows {
+				if _, err := db.ExecContext(ctx, `INSERT INTO w VALUES (?, ?)`, i, n); err != nil {
+					errs[i] = err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if n, err := selfTestCount(ctx, setup, "w"); err != nil || n != writers*rows {
+		return errors.Join(err, fmt.Errorf("found %d of %d rows", n, writers*rows))
+	}
+	return nil
+}
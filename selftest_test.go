@@ -0,0 +1,31 @@
+package memvfs_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestSelfTest(t *testing.T) {
+	report, err := memvfs.SelfTest(context.Background())
+	if err != nil {
+		t.Fatalf("SelfTest error: %v", err)
+	}
+	if !report.OK() || len(report.Checks) != 5 {
+		t.Errorf("Expected 5 passing checks, got %+v", report.Checks)
+	}
+	if report.SQLiteVersion == "" {
+		t.Errorf("Expected the SQLite version to be reported")
+	}
+	linux := runtime.GOOS == "linux"
+	if report.MapDiskFile != linux || report.Handoff != linux || report.SharedMemory != linux {
+		t.Errorf("Unexpected capabilities for %s: %+v", report.Platform, report)
+	}
+
+	// Each run registers its own VFS.
+	if _, err := memvfs.SelfTest(context.Background()); err != nil {
+		t.Errorf("Second SelfTest error: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package memvfs
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// errInterval is returned by the helpers that work on a timer, such as
+// Shadow, when given an interval they cannot tick at.
+var errInterval = errors.New("memvfs: interval must be positive")
+
+// Shadow maintains shadowName as a read-only copy of name, refreshed every
+// interval, so that heavy analytical readers can query the copy without
+// contending with writers on the original. Connections opening the shadow
+// are read-only, and the shadow is kept when they close.
+//
+// A refresh only happens once name has changed, and while no connection is
+// reading the shadow. It copies a consistent image of name, frozen as for
+// Flush, so writers to name may see SQLITE_BUSY while it is taken. Readers
+// see a new version on their next transaction.
+//
+// stop ends the refreshing; the shadow stays in place until deleted.
+func (v *MemVFS) Shadow(name, shadowName string, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, errInterval
+	}
+	v.mu.Lock()
+	e, ok := v.files[name]
+	if !ok {
+		v.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if _, ok := v.files[shadowName]; ok {
+		v.mu.Unlock()
+		return nil, ErrExist
+	}
+	version := e.version
+	v.mu.Unlock()
+
+	// The image is taken like Flush takes one, frozen but without holding
+	// the store, which is only locked to install it.
+	data, err := v.image(name)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	if _, ok := v.files[shadowName]; ok {
+		v.mu.Unlock()
+		return nil, ErrExist
+	}
+	shadow := v.lookup(shadowName, sqlite3vfs.OpenReadOnly|sqlite3vfs.OpenMainDB)
+	shadow.readOnly = true
+	shadow.retain = true
+
+	// install makes data the shadow's contents. v.mu must be held.
+	install := func(data []byte) {
+		if v.thaw(shadow) != nil || v.unseal(shadow) != nil {
+			return
+		}
+		shadow.data = data
+		shadow.modified()
+		v.account(shadow)
+		if err := v.seal(shadow); err != nil {
+			v.log(slog.LevelError, "sealing shadow failed", "file", v.LogName(shadowName), "error", err)
+		}
+	}
+	install(data)
+	v.mu.Unlock()
+
+	// current reports whether the shadow is in place, unread and out of
+	// date, and the version of name it would be refreshed to. v.mu must be
+	// held.
+	current := func() (uint64, bool) {
+		e, ok := v.files[name]
+		if !ok || e.version == version || v.files[shadowName] != shadow || shadow.locked(sqlite3vfs.LockShared) {
+			return 0, false
+		}
+		return e.version, true
+	}
+	refresh := func() {
+		v.mu.Lock()
+		next, ok := current()
+		v.mu.Unlock()
+		if !ok {
+			return
+		}
+		data, err := v.image(name)
+		if err != nil {
+			return
+		}
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		// The image may be newer than next, which only means the next
+		// refresh copies it again.
+		if _, ok := current(); ok {
+			install(data)
+			version = next
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refresh()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var stopped bool
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		if !stopped {
+			stopped = true
+			close(done)
+		}
+	}, nil
+}
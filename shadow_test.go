@@ -0,0 +1,82 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestShadow(t *testing.T) {
+	dbName := "test-shadow.db"
+	shadowName := "test-shadow-ro.db"
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES ('first')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	stop, err := v.Shadow(dbName, shadowName, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Shadow error: %v", err)
+	}
+	defer v.Delete(shadowName, false)
+	defer stop()
+
+	if _, err := v.Shadow(dbName, shadowName, time.Second); err == nil {
+		t.Errorf("Expected error shadowing onto an existing name")
+	}
+	if _, err := v.Shadow(dbName, "test-shadow-zero.db", 0); err == nil {
+		t.Errorf("Expected error shadowing with a zero interval")
+	}
+
+	shadow, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", shadowName))
+	if err != nil {
+		t.Fatalf("Failed to open shadow: %v", err)
+	}
+	defer shadow.Close()
+
+	count := func() int {
+		var n int
+		if err := shadow.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+			t.Fatalf("Shadow query error: %v", err)
+		}
+		return n
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("Expected 1 row in shadow, got %d", n)
+	}
+
+	if _, err := shadow.Exec(`INSERT INTO demo(data) VALUES ('shadow')`); err == nil {
+		t.Errorf("Shadow should reject writes")
+	}
+
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES ('second')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for count() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Shadow was not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Closing every shadow connection keeps the shadow around.
+	shadow.Close()
+	if ok, _ := v.Access(shadowName, 0); !ok {
+		t.Errorf("Shadow deleted after its readers closed")
+	}
+}
@@ -0,0 +1,330 @@
+//go:build linux
+
+package memvfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// A shared-memory segment is a file under /dev/shm holding a header of four
+// 64-bit words followed by the database image:
+//
+//	magic   shmMagic
+//	seq     even while the image is stable, odd while it is being replaced
+//	readers number of attached handles currently reading the image
+//	size    length of the image
+//
+// Readers register in readers and then check that seq is even and
+// unchanged; the publisher makes seq odd and then waits for readers to drain
+// before touching the image. Either side backs off if it sees the other, so
+// an image is never replaced under a reader.
+const (
+	shmMagic      = 0x314d5346564d454d // "MEMVFSM1"
+	shmHeaderSize = 4 * 8
+
+	shmMagicWord   = 0
+	shmSeqWord     = 1
+	shmReadersWord = 2
+	shmSizeWord    = 3
+)
+
+// ShmDir is where shared-memory segments are created, as for shm_open(3).
+var ShmDir = "/dev/shm"
+
+type shmSegment struct {
+	mu  sync.Mutex
+	f   *os.File
+	mem []byte
+}
+
+func (s *shmSegment) word(i int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&s.mem[i*8]))
+}
+
+// remap maps the whole of the segment file. s.mu must be held.
+func (s *shmSegment) remap() error {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < shmHeaderSize {
+		return fmt.Errorf("memvfs: shm segment %s too small", s.f.Name())
+	}
+	mem, err := syscall.Mmap(int(s.f.Fd()), 0, int(fi.Size()), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	if s.mem != nil {
+		syscall.Munmap(s.mem)
+	}
+	s.mem = mem
+	return nil
+}
+
+func (s *shmSegment) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.mem != nil {
+		err = syscall.Munmap(s.mem)
+		s.mem = nil
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *shmSegment) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mem == nil {
+		return 0
+	}
+	return int64(atomic.LoadUint64(s.word(shmSizeWord)))
+}
+
+func (s *shmSegment) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mem == nil {
+		return 0, os.ErrClosed
+	}
+
+	size := int64(atomic.LoadUint64(s.word(shmSizeWord)))
+	// Reads outside a pin may see the size of an image the segment has
+	// grown for since we mapped it.
+	if shmHeaderSize+size > int64(len(s.mem)) {
+		if err := s.remap(); err != nil {
+			return 0, err
+		}
+		size = min(size, int64(len(s.mem))-shmHeaderSize)
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+	n := copy(p, s.mem[shmHeaderSize+off:shmHeaderSize+size])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Pin registers a reader, failing with SQLITE_BUSY while the publisher is
+// replacing the image so that SQLite's busy handler retries.
+func (s *shmSegment) Pin() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mem == nil {
+		return os.ErrClosed
+	}
+
+	seq := atomic.LoadUint64(s.word(shmSeqWord))
+	if seq&1 == 1 {
+		return sqlite3vfs.BusyError
+	}
+	atomic.AddUint64(s.word(shmReadersWord), 1)
+	if atomic.LoadUint64(s.word(shmSeqWord)) != seq {
+		atomic.AddUint64(s.word(shmReadersWord), ^uint64(0))
+		return sqlite3vfs.BusyError
+	}
+
+	// The publisher may have grown the segment since we mapped it.
+	if shmHeaderSize+atomic.LoadUint64(s.word(shmSizeWord)) > uint64(len(s.mem)) {
+		if err := s.remap(); err != nil {
+			atomic.AddUint64(s.word(shmReadersWord), ^uint64(0))
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shmSegment) Unpin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mem == nil {
+		return
+	}
+
+	atomic.AddUint64(s.word(shmReadersWord), ^uint64(0))
+}
+
+// PublishShm exposes name to other processes on the host through the
+// shared-memory segment called segment, refreshing it every interval once
+// name has changed. Other processes attach to it with AttachShm.
+//
+// A refresh waits up to interval for attached readers to finish their
+// current transaction, holding off new ones meanwhile, and is retried on the
+// next tick if they don't. stop ends publishing and removes the segment;
+// processes still attached keep the last image.
+func (v *MemVFS) PublishShm(name, segment string, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, errInterval
+	}
+	v.mu.Lock()
+	_, ok := v.files[name]
+	v.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	path := filepath.Join(ShmDir, segment)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &shmSegment{f: f}
+	if err := f.Truncate(shmHeaderSize); err == nil {
+		s.mu.Lock()
+		err = s.remap()
+		s.mu.Unlock()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	atomic.StoreUint64(s.word(shmMagicWord), shmMagic)
+
+	p := &shmPublisher{v: v, name: name, seg: s, timeout: interval}
+	p.publish()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.publish()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			s.Close()
+			os.Remove(path)
+		})
+	}, nil
+}
+
+type shmPublisher struct {
+	v         *MemVFS
+	name      string
+	seg       *shmSegment
+	timeout   time.Duration
+	version   uint64
+	published bool
+}
+
+func (p *shmPublisher) publish() {
+	v, s := p.v, p.seg
+
+	v.mu.Lock()
+	e, ok := v.files[p.name]
+	unchanged := !ok || p.published && e.version == p.version
+	v.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Hold off new readers and wait for current ones to drain. seq is made
+	// even again on every path out, published or not, looked up afresh as
+	// the segment may have been remapped.
+	atomic.AddUint64(s.word(shmSeqWord), 1)
+	defer func() { atomic.AddUint64(s.word(shmSeqWord), 1) }()
+
+	deadline := time.Now().Add(p.timeout)
+	for atomic.LoadUint64(s.word(shmReadersWord)) != 0 {
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok = v.files[p.name]
+	if !ok || e.locked(sqlite3vfs.LockExclusive) {
+		return
+	}
+	data, err := v.contents(e)
+	if err != nil {
+		return
+	}
+
+	if need := shmHeaderSize + len(data); need > len(s.mem) {
+		if err := s.f.Truncate(int64(need + need/4)); err != nil {
+			return
+		}
+		if err := s.remap(); err != nil {
+			return
+		}
+	}
+	copy(s.mem[shmHeaderSize:], data)
+	atomic.StoreUint64(s.word(shmSizeWord), uint64(len(data)))
+	p.version = e.version
+	p.published = true
+}
+
+// AttachShm makes the image published by another process under segment
+// available as the read-only file name. The image stays mapped until name
+// is deleted.
+func (v *MemVFS) AttachShm(segment, name string) error {
+	f, err := os.OpenFile(filepath.Join(ShmDir, segment), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	s := &shmSegment{f: f}
+	s.mu.Lock()
+	err = s.remap()
+	s.mu.Unlock()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if atomic.LoadUint64(s.word(shmMagicWord)) != shmMagic {
+		s.Close()
+		return fmt.Errorf("memvfs: %s is not a memvfs shm segment", segment)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.files[name]; ok {
+		s.Close()
+		return ErrExist
+	}
+	e := v.lookup(name, sqlite3vfs.OpenReadOnly|sqlite3vfs.OpenMainDB)
+	e.readOnly = true
+	e.retain = true
+	e.src = s
+	return nil
+}
@@ -0,0 +1,109 @@
+//go:build linux
+
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestSharedMemory(t *testing.T) {
+	dbName := "test-shm.db"
+	segment := fmt.Sprintf("memvfs-test-%d", time.Now().UnixNano())
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO demo(data) VALUES ('first')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+
+	stop, err := v.PublishShm(dbName, segment, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("PublishShm error: %v", err)
+	}
+	defer stop()
+
+	// A second MemVFS stands in for another process.
+	other := memvfs.New()
+	if err := other.Register("memvfs-shm-reader"); err != nil {
+		t.Fatalf("Failed to register VFS: %v", err)
+	}
+	if err := other.AttachShm(segment, dbName); err != nil {
+		t.Fatalf("AttachShm error: %v", err)
+	}
+	defer other.Delete(dbName, false)
+
+	reader, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs-shm-reader", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open attached DB: %v", err)
+	}
+	defer reader.Close()
+
+	count := func() int {
+		var n int
+		if err := reader.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+			t.Fatalf("Attached query error: %v", err)
+		}
+		return n
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("Expected 1 row, got %d", n)
+	}
+	if _, err := reader.Exec(`INSERT INTO demo(data) VALUES ('reader')`); err == nil {
+		t.Errorf("Attached segment should reject writes")
+	}
+
+	first, err := other.GetFile(dbName)
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+
+	for i := 0; i < 500; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	// Reads without a connection's pin see the segment grow too.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := other.GetFile(dbName)
+		if err != nil {
+			t.Fatalf("GetFile error: %v", err)
+		}
+		if len(data) > len(first) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Attached segment did not grow")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	deadline = time.Now().Add(2 * time.Second)
+	for count() != 501 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Attached segment was not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var check string
+	if err := reader.QueryRow(`PRAGMA integrity_check`).Scan(&check); err != nil || check != "ok" {
+		t.Errorf("integrity_check = %q, %v", check, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package memvfs
+
+import (
+	"errors"
+	"time"
+)
+
+// PublishShm is only supported on Linux.
+func (v *MemVFS) PublishShm(name, segment string, interval time.Duration) (stop func(), err error) {
+	return nil, errors.ErrUnsupported
+}
+
+// AttachShm is only supported on Linux.
+func (v *MemVFS) AttachShm(segment, name string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,406 @@
+package memvfs
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// snapshotBlockSize is the granularity at which snapshots share contents.
+const snapshotBlockSize = 4096
+
+// SnapshotID identifies a snapshot within a MemVFS.
+type SnapshotID uint64
+
+// Snapshot describes a point-in-time image of one or more files.
+type Snapshot struct {
+	ID      SnapshotID
+	Label   string
+	Created time.Time
+	Names   []string
+
+	// Size is the total size of the files in the snapshot. Unshared is the
+	// part held by this snapshot alone, which dropping it frees; the rest is
+	// shared with other snapshots.
+	Size     int64
+	Unshared int64
+}
+
+// snapshot is the stored state of a Snapshot.
+type snapshot struct {
+	Snapshot
+	images map[string]*image
+}
+
+// image is the immutable contents of a file in a snapshot, held in blocks of
+// snapshotBlockSize. Blocks equal to the previous snapshot's are shared with
+// it rather than copied.
+type image struct {
+	size   int64
+	blocks [][]byte
+
+	// version is the file's version when the image was taken.
+	version uint64
+}
+
+// ReadAt implements io.ReaderAt.
+func (img *image) ReadAt(p []byte, off int64) (int, error) {
+	if off >= img.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && off < img.size {
+		block := img.blocks[off/snapshotBlockSize]
+		c := copy(p[n:], block[off%snapshotBlockSize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// zeroBlock stands in for every all-zero block of every image, which are
+// common in freshly created or preallocated databases.
+var zeroBlock = make([]byte, snapshotBlockSize)
+
+// isZero reports whether block is all zeros.
+func isZero(block []byte) bool {
+	return bytes.Equal(block, zeroBlock[:len(block)])
+}
+
+// newImage captures data, sharing blocks with prev where they are equal and
+// representing all-zero blocks by zeroBlock.
+func newImage(data []byte, prev *image) *image {
+	img := &image{size: int64(len(data))}
+	for off := 0; off < len(data); off += snapshotBlockSize {
+		block := data[off:min(off+snapshotBlockSize, len(data))]
+		i := off / snapshotBlockSize
+		if isZero(block) {
+			img.blocks = append(img.blocks, zeroBlock[:len(block)])
+			continue
+		}
+		if prev != nil && i < len(prev.blocks) && bytes.Equal(prev.blocks[i], block) {
+			img.blocks = append(img.blocks, prev.blocks[i])
+			continue
+		}
+		img.blocks = append(img.blocks, bytes.Clone(block))
+	}
+	return img
+}
+
+// SnapshotGroup quiesces the named files together and snapshots them at a
+// single point, so that databases updated by the same transactions (a main
+// database and its ATTACHed ones) are mutually consistent in the snapshot.
+// It waits up to DefaultFreezeTimeout for in-flight write transactions.
+func (v *MemVFS) SnapshotGroup(names ...string) (Snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultFreezeTimeout)
+	defer cancel()
+
+	unfreeze, err := v.freezeFiles(ctx, names)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer unfreeze()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.snapSeq++
+	snap := &snapshot{
+		Snapshot: Snapshot{ID: SnapshotID(v.snapSeq), Created: time.Now()},
+		images:   make(map[string]*image),
+	}
+	for _, name := range names {
+		e, ok := v.files[name]
+		if !ok {
+			return Snapshot{}, fmt.Errorf("%w: %s", ErrNotFound, v.LogName(name))
+		}
+		data, err := v.contents(e)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		img := newImage(data, v.latestImage(name))
+		img.version = e.version
+		snap.images[name] = img
+		snap.Names = append(snap.Names, name)
+	}
+	sort.Strings(snap.Names)
+
+	if v.snapshots == nil {
+		v.snapshots = make(map[SnapshotID]*snapshot)
+	}
+	v.snapshots[snap.ID] = snap
+	v.retainSnapshots()
+	return v.describe(snap, v.blockRefs()), nil
+}
+
+// Snapshot snapshots the named file alone; see SnapshotGroup. Blocks the
+// file shares with its previous snapshot are not copied again, so each
+// snapshot only holds the memory of the blocks that changed. Taking one
+// still reads the whole file, comparing it block by block with the
+// previous snapshot with the store locked, so its time grows with the
+// file's size; the first snapshot of a file copies all of it.
+func (v *MemVFS) Snapshot(name string) (Snapshot, error) {
+	return v.SnapshotGroup(name)
+}
+
+// Restore rolls the named file back to its image in snapshot id. Only the
+// blocks that differ from the snapshot are written. It fails with ErrBusy
+// while a connection is using the file, as its page cache would otherwise
+// no longer match it.
+func (v *MemVFS) Restore(name string, id SnapshotID) (err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	img, err := v.snapshotImage(id, name)
+	if err != nil {
+		return err
+	}
+	e, ok := v.files[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := v.thaw(e); err != nil {
+		return err
+	}
+	if e.readOnly || e.src != nil && !e.sealed() || e.disk != nil {
+		return sqlite3vfs.ReadOnlyError
+	}
+	if e.locked(sqlite3vfs.LockShared) {
+		return fmt.Errorf("%w: %s", ErrBusy, v.LogName(name))
+	}
+	if err := v.unseal(e); err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, v.seal(e))
+	}()
+
+	e.own()
+	data := e.data
+	if int64(cap(data)) < img.size {
+		data = make([]byte, img.size)
+		copy(data, e.data)
+	}
+	data = data[:img.size]
+	for i, block := range img.blocks {
+		off := int64(i) * snapshotBlockSize
+		end := off + int64(len(block))
+		if end <= int64(len(e.data)) && bytes.Equal(data[off:end], block) {
+			continue
+		}
+		e.mutating(nil, off, end)
+		copy(data[off:end], block)
+	}
+	e.data = data
+	e.modified()
+	v.account(e)
+	return nil
+}
+
+// WithSnapshotRetention keeps at most n unlabeled snapshots, dropping the
+// oldest when a new one is taken. Labeled snapshots are kept until dropped.
+func WithSnapshotRetention(n int) Option {
+	return func(v *MemVFS) {
+		v.snapRetention = n
+	}
+}
+
+// retainSnapshots applies the retention limit. v.mu must be held.
+func (v *MemVFS) retainSnapshots() {
+	if v.snapRetention <= 0 {
+		return
+	}
+	var unlabeled []SnapshotID
+	for id, snap := range v.snapshots {
+		if snap.Label == "" {
+			unlabeled = append(unlabeled, id)
+		}
+	}
+	slices.Sort(unlabeled)
+	for _, id := range unlabeled[:max(len(unlabeled)-v.snapRetention, 0)] {
+		delete(v.snapshots, id)
+	}
+}
+
+// blockRefs counts the snapshots referencing each block. v.mu must be held.
+func (v *MemVFS) blockRefs() map[*byte]int {
+	refs := make(map[*byte]int)
+	for _, snap := range v.snapshots {
+		seen := make(map[*byte]bool)
+		for _, img := range snap.images {
+			for _, block := range img.blocks {
+				if !seen[&block[0]] {
+					seen[&block[0]] = true
+					refs[&block[0]]++
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// describe fills in the sizes of snap. v.mu must be held.
+func (v *MemVFS) describe(snap *snapshot, refs map[*byte]int) Snapshot {
+	s := snap.Snapshot
+	s.Names = slices.Clone(s.Names)
+	for _, img := range snap.images {
+		s.Size += img.size
+		for _, block := range img.blocks {
+			if refs[&block[0]] == 1 && &block[0] != &zeroBlock[0] {
+				s.Unshared += int64(len(block))
+			}
+		}
+	}
+	return s
+}
+
+// Snapshots lists the snapshots held by the store, oldest first.
+func (v *MemVFS) Snapshots() []Snapshot {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	refs := v.blockRefs()
+	snaps := make([]Snapshot, 0, len(v.snapshots))
+	for _, snap := range v.snapshots {
+		snaps = append(snaps, v.describe(snap, refs))
+	}
+	slices.SortFunc(snaps, func(a, b Snapshot) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return snaps
+}
+
+// LabelSnapshot sets the label of snapshot id. Labeled snapshots are exempt
+// from WithSnapshotRetention; an empty label removes the exemption.
+func (v *MemVFS) LabelSnapshot(id SnapshotID, label string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	snap, ok := v.snapshots[id]
+	if !ok {
+		return fmt.Errorf("%w: snapshot %d", ErrNotFound, id)
+	}
+	snap.Label = label
+	return nil
+}
+
+// DropSnapshot deletes snapshot id, freeing the blocks no other snapshot
+// shares.
+func (v *MemVFS) DropSnapshot(id SnapshotID) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.snapshots[id]; !ok {
+		return fmt.Errorf("%w: snapshot %d", ErrNotFound, id)
+	}
+	delete(v.snapshots, id)
+	return nil
+}
+
+// latestImage returns the most recent snapshot image of name, or nil. v.mu
+// must be held.
+func (v *MemVFS) latestImage(name string) *image {
+	var latest *snapshot
+	for _, snap := range v.snapshots {
+		if _, ok := snap.images[name]; ok && (latest == nil || snap.ID > latest.ID) {
+			latest = snap
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	return latest.images[name]
+}
+
+// snapshotImage returns the image of name in snapshot id. v.mu must be held.
+func (v *MemVFS) snapshotImage(id SnapshotID, name string) (*image, error) {
+	snap, ok := v.snapshots[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: snapshot %d", ErrNotFound, id)
+	}
+	img, ok := snap.images[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s in snapshot %d", ErrNotFound, v.LogName(name), id)
+	}
+	return img, nil
+}
+
+// ExportSnapshot writes the database of a single-file snapshot to w as a
+// standalone SQLite file. Use ExportSnapshotFile for snapshots taken with
+// SnapshotGroup over several files.
+func (v *MemVFS) ExportSnapshot(id SnapshotID, w io.Writer) (int64, error) {
+	v.mu.Lock()
+	snap, ok := v.snapshots[id]
+	if !ok {
+		v.mu.Unlock()
+		return 0, fmt.Errorf("%w: snapshot %d", ErrNotFound, id)
+	}
+	names := snap.Names
+	v.mu.Unlock()
+
+	if len(names) != 1 {
+		return 0, fmt.Errorf("memvfs: snapshot %d holds %d files", id, len(names))
+	}
+	return v.ExportSnapshotFile(id, names[0], w)
+}
+
+// ExportSnapshotFile writes the named file of snapshot id to w. The
+// snapshot's blocks are written as they are, without assembling the file in
+// memory, and the export is unaffected by the snapshot being dropped
+// meanwhile. A store created WithExportRedaction assembles and redacts a
+// copy instead.
+func (v *MemVFS) ExportSnapshotFile(id SnapshotID, name string, w io.Writer) (int64, error) {
+	if len(v.redactors) > 0 {
+		data, err := v.SnapshotFile(id, name)
+		if err == nil {
+			data, err = v.redact(name, data)
+		}
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(data)
+		return int64(n), err
+	}
+
+	v.mu.Lock()
+	img, err := v.snapshotImage(id, name)
+	v.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	for _, block := range img.blocks {
+		n, err := w.Write(block)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// SnapshotFile returns a copy of the named file as it was in snapshot id.
+func (v *MemVFS) SnapshotFile(id SnapshotID, name string) ([]byte, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	img, err := v.snapshotImage(id, name)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, img.size)
+	img.ReadAt(data, 0)
+	return data, nil
+}
@@ -0,0 +1,268 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestSnapshotGroup(t *testing.T) {
+	mainName := "test-snapshot-main.db"
+	otherName := "test-snapshot-other.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs", mainName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		fmt.Sprintf(`ATTACH DATABASE 'file:%s?vfs=memvfs' AS other`, otherName),
+		`CREATE TABLE main.counter (n INTEGER)`,
+		`CREATE TABLE other.counter (n INTEGER)`,
+		`INSERT INTO main.counter VALUES (0)`,
+		`INSERT INTO other.counter VALUES (0)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			tx, err := db.Begin()
+			if err != nil {
+				continue
+			}
+			tx.Exec(`UPDATE main.counter SET n = n + 1`)
+			tx.Exec(`UPDATE other.counter SET n = n + 1`)
+			tx.Commit()
+		}
+	}()
+
+	var snaps []memvfs.Snapshot
+	for ctx.Err() == nil {
+		snap, err := v.SnapshotGroup(mainName, otherName)
+		if err != nil {
+			t.Fatalf("SnapshotGroup error: %v", err)
+		}
+		snaps = append(snaps, snap)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	if _, err := v.SnapshotGroup(mainName, "test-snapshot-missing.db"); err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+
+	for i, snap := range snaps {
+		if len(snap.Names) != 2 {
+			t.Fatalf("Expected 2 files in snapshot, got %v", snap.Names)
+		}
+		var counts [2]int
+		for j, name := range []string{mainName, otherName} {
+			data, err := v.SnapshotFile(snap.ID, name)
+			if err != nil {
+				t.Fatalf("SnapshotFile error: %v", err)
+			}
+			restored := fmt.Sprintf("test-snapshot-restored-%d-%d.db", i, j)
+			err = v.Batch(func(tx *memvfs.AdminTx) error {
+				return tx.Put(restored, data)
+			})
+			if err != nil {
+				t.Fatalf("Put error: %v", err)
+			}
+			rdb, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs", restored))
+			if err != nil {
+				t.Fatalf("Failed to open DB: %v", err)
+			}
+			err = rdb.QueryRow(`SELECT n FROM counter`).Scan(&counts[j])
+			rdb.Close()
+			if err != nil {
+				t.Fatalf("Query snapshot %d: %v", snap.ID, err)
+			}
+		}
+		if counts[0] != counts[1] {
+			t.Errorf("Snapshot %d is inconsistent: %d != %d", snap.ID, counts[0], counts[1])
+		}
+	}
+}
+
+func TestSnapshotManagement(t *testing.T) {
+	v := memvfs.New(memvfs.WithSnapshotRetention(2))
+	name := "test-snapshot-manage.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	f.WriteAt(bytes.Repeat([]byte{'a'}, 3*4096), 0)
+
+	first, err := v.SnapshotGroup(name)
+	if err != nil {
+		t.Fatalf("SnapshotGroup error: %v", err)
+	}
+	if first.Size != 3*4096 || first.Unshared != 3*4096 {
+		t.Errorf("Expected a fully unshared first snapshot, got %+v", first)
+	}
+	if err := v.LabelSnapshot(first.ID, "baseline"); err != nil {
+		t.Fatalf("LabelSnapshot error: %v", err)
+	}
+
+	f.WriteAt(bytes.Repeat([]byte{'b'}, 4096), 4096)
+	var ids []memvfs.SnapshotID
+	for i := 0; i < 3; i++ {
+		snap, err := v.SnapshotGroup(name)
+		if err != nil {
+			t.Fatalf("SnapshotGroup error: %v", err)
+		}
+		ids = append(ids, snap.ID)
+	}
+
+	snaps := v.Snapshots()
+	if len(snaps) != 3 || snaps[0].ID != first.ID || snaps[0].Label != "baseline" || snaps[1].ID != ids[1] {
+		t.Fatalf("Expected baseline and the 2 latest snapshots, got %+v", snaps)
+	}
+	if snaps[0].Unshared != 4096 {
+		t.Errorf("Expected baseline to hold 1 block alone, got %d bytes", snaps[0].Unshared)
+	}
+	if snaps[1].Unshared != 0 {
+		t.Errorf("Expected identical snapshots to share everything, got %d bytes", snaps[1].Unshared)
+	}
+
+	if err := v.DropSnapshot(first.ID); err != nil {
+		t.Fatalf("DropSnapshot error: %v", err)
+	}
+	if err := v.DropSnapshot(first.ID); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if len(v.Snapshots()) != 2 {
+		t.Errorf("Expected 2 snapshots after drop")
+	}
+}
+
+func TestExportSnapshot(t *testing.T) {
+	dbName := "test-snapshot-export.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(100))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	snap, err := v.SnapshotGroup(dbName)
+	if err != nil {
+		t.Fatalf("SnapshotGroup error: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM demo`); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.db")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	n, err := v.ExportSnapshot(snap.ID, out)
+	out.Close()
+	if err != nil || n != snap.Size {
+		t.Fatalf("ExportSnapshot wrote %d of %d bytes: %v", n, snap.Size, err)
+	}
+
+	exported, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+	defer exported.Close()
+	var count int
+	if err := exported.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil || count != 100 {
+		t.Errorf("Expected 100 rows in export, got %d (%v)", count, err)
+	}
+}
+
+func TestSnapshotZeroBlocks(t *testing.T) {
+	v := memvfs.New()
+	name := "test-snapshot-zero.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	f.WriteAt(make([]byte, 3*4096), 0)
+	f.WriteAt([]byte("data"), 3*4096)
+
+	snap, err := v.SnapshotGroup(name)
+	if err != nil {
+		t.Fatalf("SnapshotGroup error: %v", err)
+	}
+	if snap.Unshared != 4 {
+		t.Errorf("Expected only the non-zero tail to take memory, got %d bytes", snap.Unshared)
+	}
+	want, _ := v.GetFile(name)
+	got, err := v.SnapshotFile(snap.ID, name)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Snapshot contents differ from the file (%v)", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dbName := "test-snapshot-restore.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	insert := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500)); err != nil {
+				t.Fatalf("Insert error: %v", err)
+			}
+		}
+	}
+	insert(10)
+	snap, err := v.Snapshot(dbName)
+	if err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+	defer v.DropSnapshot(snap.ID)
+
+	insert(50)
+	if err := v.Restore(dbName, snap.ID); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if n := countRows(t, dbName); n != 10 {
+		t.Errorf("Expected 10 rows after restore, got %d", n)
+	}
+	if err := v.Restore(dbName, snap.ID+1000); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing snapshot, got %v", err)
+	}
+}
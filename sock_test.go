@@ -0,0 +1,66 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestSocket(t *testing.T) {
+	dbName := "test-socket.db"
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "memvfs.sock"))
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	defer l.Close()
+	go v.Serve(l)
+
+	client, err := memvfs.DialSocket(l.Addr().String())
+	if err != nil {
+		t.Fatalf("DialSocket error: %v", err)
+	}
+	defer client.Close()
+	if err := sqlite3vfs.RegisterVFS("memvfs-socket", client); err != nil {
+		t.Fatalf("Failed to register VFS: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs-socket&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&total); err != nil {
+		t.Fatalf("Count query error: %v", err)
+	}
+	if total != 100 {
+		t.Errorf("Expected 100 rows, got %d", total)
+	}
+
+	// The data lives in the serving store.
+	info, err := v.Stat(dbName)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if info.Size < 100*500 {
+		t.Errorf("Expected served file to hold the rows, size %d", info.Size)
+	}
+}
@@ -0,0 +1,208 @@
+package memvfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// SocketVFS is a sqlite3vfs.VFS whose files live in a MemVFS in another
+// process, reached over the socket served by MemVFS.Serve. Register it with
+// sqlite3vfs.RegisterVFS like any other VFS.
+//
+// Requests are made one at a time over a single connection. If the
+// connection fails, every operation returns SQLITE_IOERR.
+type SocketVFS struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	req  []byte
+	resp []byte
+}
+
+// DialSocket connects to a MemVFS serving on the unix socket at path.
+func DialSocket(path string) (*SocketVFS, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &SocketVFS{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+	}, nil
+}
+
+// Close closes the connection, which closes any handles still open on it.
+func (c *SocketVFS) Close() error {
+	return c.conn.Close()
+}
+
+// call sends the request built by build and passes the decoded response to
+// read. It returns the SQLite error reported by the server, if any.
+func (c *SocketVFS) call(build func(req []byte) []byte, read func(d *decoder)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.req = build(c.req[:0])
+	if err := writeFrame(c.conn, c.req); err != nil {
+		return sqlite3vfs.IOError
+	}
+	resp, err := readFrame(c.r, c.resp)
+	if err != nil {
+		return sqlite3vfs.IOError
+	}
+	c.resp = resp
+
+	d := &decoder{b: resp}
+	code := d.u32()
+	if read != nil {
+		read(d)
+	}
+	if d.err != nil {
+		return sqlite3vfs.IOError
+	}
+	return codeErr(code)
+}
+
+func (c *SocketVFS) Open(name string, flags sqlite3vfs.OpenFlag) (sqlite3vfs.File, sqlite3vfs.OpenFlag, error) {
+	var id uint64
+	var outFlags sqlite3vfs.OpenFlag
+	err := c.call(func(req []byte) []byte {
+		req = append(req, opOpen)
+		req = binary.BigEndian.AppendUint32(req, uint32(flags))
+		return append(req, name...)
+	}, func(d *decoder) {
+		id = d.u64()
+		outFlags = sqlite3vfs.OpenFlag(d.u32())
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &socketFile{c: c, id: id}, outFlags, nil
+}
+
+func (c *SocketVFS) Delete(name string, syncDir bool) error {
+	return c.call(func(req []byte) []byte {
+		req = appendBool(append(req, opDelete), syncDir)
+		return append(req, name...)
+	}, nil)
+}
+
+func (c *SocketVFS) Access(name string, flags sqlite3vfs.AccessFlag) (bool, error) {
+	var ok bool
+	err := c.call(func(req []byte) []byte {
+		req = binary.BigEndian.AppendUint32(append(req, opAccess), uint32(flags))
+		return append(req, name...)
+	}, func(d *decoder) {
+		ok = d.bool()
+	})
+	return ok, err
+}
+
+func (c *SocketVFS) FullPathname(name string) string {
+	return name
+}
+
+// socketFile is a handle opened through a SocketVFS.
+type socketFile struct {
+	c  *SocketVFS
+	id uint64
+}
+
+// simple performs op on the handle with optional trailing fields.
+func (f *socketFile) simple(op byte, fields func(req []byte) []byte, read func(d *decoder)) error {
+	return f.c.call(func(req []byte) []byte {
+		req = binary.BigEndian.AppendUint64(append(req, op), f.id)
+		if fields != nil {
+			req = fields(req)
+		}
+		return req
+	}, read)
+}
+
+func (f *socketFile) Close() error {
+	return f.simple(opClose, nil, nil)
+}
+
+func (f *socketFile) ReadAt(p []byte, off int64) (int, error) {
+	err := f.simple(opReadAt, func(req []byte) []byte {
+		req = binary.BigEndian.AppendUint64(req, uint64(off))
+		return binary.BigEndian.AppendUint32(req, uint32(len(p)))
+	}, func(d *decoder) {
+		copy(p, d.next(len(p)))
+	})
+	if err == sqlite3vfs.IOError {
+		return 0, err
+	}
+	return len(p), err
+}
+
+func (f *socketFile) WriteAt(p []byte, off int64) (int, error) {
+	err := f.simple(opWriteAt, func(req []byte) []byte {
+		req = binary.BigEndian.AppendUint64(req, uint64(off))
+		return append(req, p...)
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (f *socketFile) Truncate(size int64) error {
+	return f.simple(opTruncate, func(req []byte) []byte {
+		return binary.BigEndian.AppendUint64(req, uint64(size))
+	}, nil)
+}
+
+func (f *socketFile) Sync(flags sqlite3vfs.SyncType) error {
+	return f.simple(opSync, func(req []byte) []byte {
+		return binary.BigEndian.AppendUint32(req, uint32(flags))
+	}, nil)
+}
+
+func (f *socketFile) FileSize() (int64, error) {
+	var size int64
+	err := f.simple(opFileSize, nil, func(d *decoder) {
+		size = d.i64()
+	})
+	return size, err
+}
+
+func (f *socketFile) Lock(lockType sqlite3vfs.LockType) error {
+	return f.simple(opLock, func(req []byte) []byte {
+		return binary.BigEndian.AppendUint32(req, uint32(lockType))
+	}, nil)
+}
+
+func (f *socketFile) Unlock(lockType sqlite3vfs.LockType) error {
+	return f.simple(opUnlock, func(req []byte) []byte {
+		return binary.BigEndian.AppendUint32(req, uint32(lockType))
+	}, nil)
+}
+
+func (f *socketFile) CheckReservedLock() (bool, error) {
+	var ok bool
+	err := f.simple(opCheckReserved, nil, func(d *decoder) {
+		ok = d.bool()
+	})
+	return ok, err
+}
+
+func (f *socketFile) SectorSize() int64 {
+	var size int64
+	if err := f.simple(opSectorSize, nil, func(d *decoder) { size = d.i64() }); err != nil {
+		return 512
+	}
+	return size
+}
+
+func (f *socketFile) DeviceCharacteristics() sqlite3vfs.DeviceCharacteristic {
+	var dc sqlite3vfs.DeviceCharacteristic
+	f.simple(opCharacteristics, nil, func(d *decoder) {
+		dc = sqlite3vfs.DeviceCharacteristic(d.u32())
+	})
+	return dc
+}
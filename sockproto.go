@@ -0,0 +1,156 @@
+package memvfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// The socket protocol exchanges length-prefixed frames: a big-endian uint32
+// length followed by that many bytes. A request frame starts with an op
+// byte, a response frame with a uint32 SQLite result code (0 for success);
+// the fields that follow are listed per op below.
+const (
+	opOpen            byte = iota + 1 // flags u32, name -> handle u64, flags u32
+	opClose                           // handle
+	opReadAt                          // handle, off i64, n u32 -> data
+	opWriteAt                         // handle, off i64, data
+	opTruncate                        // handle, size i64
+	opSync                            // handle, flags u32
+	opFileSize                        // handle -> size i64
+	opLock                            // handle, lock u32
+	opUnlock                          // handle, lock u32
+	opCheckReserved                   // handle -> bool u8
+	opDelete                          // syncDir u8, name
+	opAccess                          // flags u32, name -> bool u8
+	opSectorSize                      // handle -> size i64
+	opCharacteristics                 // handle -> characteristics u32
+)
+
+// maxFrame bounds frames so a corrupt length cannot exhaust memory. It
+// comfortably fits SQLite's largest page.
+const maxFrame = 1 << 20
+
+func writeFrame(w io.Writer, frame []byte) error {
+	if len(frame) > maxFrame {
+		return fmt.Errorf("memvfs: frame of %d bytes too large", len(frame))
+	}
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(frame)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader, buf []byte) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > maxFrame {
+		return nil, fmt.Errorf("memvfs: frame of %d bytes too large", n)
+	}
+	if cap(buf) < int(n) {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+// sqliteErrors maps SQLite result codes to the errors that carry them, so
+// that errors cross the socket with their code intact.
+var sqliteErrors = map[uint32]error{
+	1:   sqlite3vfs.GenericError,
+	2:   sqlite3vfs.InternalError,
+	3:   sqlite3vfs.PermError,
+	4:   sqlite3vfs.AbortError,
+	5:   sqlite3vfs.BusyError,
+	6:   sqlite3vfs.LockedError,
+	7:   sqlite3vfs.NoMemError,
+	8:   sqlite3vfs.ReadOnlyError,
+	9:   sqlite3vfs.InterruptError,
+	10:  sqlite3vfs.IOError,
+	11:  sqlite3vfs.CorruptError,
+	12:  sqlite3vfs.NotFoundError,
+	13:  sqlite3vfs.FullError,
+	14:  sqlite3vfs.CantOpenError,
+	15:  sqlite3vfs.ProtocolError,
+	16:  sqlite3vfs.EmptyError,
+	17:  sqlite3vfs.SchemaError,
+	18:  sqlite3vfs.TooBigError,
+	19:  sqlite3vfs.ConstraintError,
+	20:  sqlite3vfs.MismatchError,
+	21:  sqlite3vfs.MisuseError,
+	22:  sqlite3vfs.NoLFSError,
+	23:  sqlite3vfs.AuthError,
+	24:  sqlite3vfs.FormatError,
+	25:  sqlite3vfs.RangeError,
+	26:  sqlite3vfs.NotaDBError,
+	27:  sqlite3vfs.NoticeError,
+	28:  sqlite3vfs.WarningError,
+	266: sqlite3vfs.IOErrorRead,
+	522: sqlite3vfs.IOErrorShortRead,
+	778: sqlite3vfs.IOErrorWrite,
+}
+
+// errCode returns the SQLite result code for err. Errors that are not
+// SQLite errors are reported as SQLITE_IOERR.
+func errCode(err error) uint32 {
+	if err == nil {
+		return 0
+	}
+	for code, e := range sqliteErrors {
+		if errors.Is(err, e) {
+			return code
+		}
+	}
+	return 10
+}
+
+func codeErr(code uint32) error {
+	if code == 0 {
+		return nil
+	}
+	if err, ok := sqliteErrors[code]; ok {
+		return err
+	}
+	return sqlite3vfs.IOError
+}
+
+// decoder reads big-endian fields from a frame, remembering if it ran out.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		d.b = nil
+		return make([]byte, n)
+	}
+	p := d.b[:n]
+	d.b = d.b[n:]
+	return p
+}
+
+func (d *decoder) u8() byte     { return d.next(1)[0] }
+func (d *decoder) u32() uint32  { return binary.BigEndian.Uint32(d.next(4)) }
+func (d *decoder) u64() uint64  { return binary.BigEndian.Uint64(d.next(8)) }
+func (d *decoder) i64() int64   { return int64(d.u64()) }
+func (d *decoder) rest() []byte { p := d.b; d.b = nil; return p }
+func (d *decoder) bool() bool   { return d.u8() != 0 }
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
@@ -0,0 +1,154 @@
+package memvfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// Serve accepts connections on l and serves v's files to them until l is
+// closed. It is meant for sidecars on the same host reaching the store over
+// a unix socket with DialSocket. Handles left open by a client are closed
+// when its connection ends.
+func (v *MemVFS) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go v.serveConn(conn)
+	}
+}
+
+func (v *MemVFS) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	s := &sockSession{
+		v:       v,
+		handles: make(map[uint64]sqlite3vfs.File),
+	}
+	defer func() {
+		for _, f := range s.handles {
+			f.Close()
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var req, resp []byte
+	for {
+		var err error
+		req, err = readFrame(r, req)
+		if err != nil || len(req) == 0 {
+			return
+		}
+		resp = s.handle(req, resp[:0])
+		if err := writeFrame(w, resp); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// sockSession is the state of one client connection.
+type sockSession struct {
+	v       *MemVFS
+	handles map[uint64]sqlite3vfs.File
+	nextID  uint64
+	buf     []byte
+}
+
+// handle executes req and appends the response to resp.
+func (s *sockSession) handle(req []byte, resp []byte) []byte {
+	resp = append(resp, 0, 0, 0, 0)
+	fail := func(err error) []byte {
+		binary.BigEndian.PutUint32(resp[:4], errCode(err))
+		return resp
+	}
+
+	d := &decoder{b: req[1:]}
+	op := req[0]
+
+	switch op {
+	case opOpen:
+		flags := sqlite3vfs.OpenFlag(d.u32())
+		name := string(d.rest())
+		f, outFlags, err := s.v.Open(name, flags)
+		if err != nil {
+			return fail(err)
+		}
+		s.nextID++
+		s.handles[s.nextID] = f
+		resp = binary.BigEndian.AppendUint64(resp, s.nextID)
+		return binary.BigEndian.AppendUint32(resp, uint32(outFlags))
+	case opDelete:
+		syncDir := d.bool()
+		return fail(s.v.Delete(string(d.rest()), syncDir))
+	case opAccess:
+		flags := sqlite3vfs.AccessFlag(d.u32())
+		ok, err := s.v.Access(string(d.rest()), flags)
+		if err != nil {
+			return fail(err)
+		}
+		return appendBool(resp, ok)
+	}
+
+	id := d.u64()
+	f, ok := s.handles[id]
+	if !ok || d.err != nil {
+		return fail(sqlite3vfs.MisuseError)
+	}
+
+	switch op {
+	case opClose:
+		delete(s.handles, id)
+		return fail(f.Close())
+	case opReadAt:
+		off := d.i64()
+		n := d.u32()
+		if n > maxFrame-4 {
+			return fail(sqlite3vfs.TooBigError)
+		}
+		if cap(s.buf) < int(n) {
+			s.buf = make([]byte, n)
+		}
+		p := s.buf[:n]
+		_, err := f.ReadAt(p, off)
+		resp = fail(err)
+		return append(resp, p...)
+	case opWriteAt:
+		off := d.i64()
+		_, err := f.WriteAt(d.rest(), off)
+		return fail(err)
+	case opTruncate:
+		return fail(f.Truncate(d.i64()))
+	case opSync:
+		return fail(f.Sync(sqlite3vfs.SyncType(d.u32())))
+	case opFileSize:
+		size, err := f.FileSize()
+		resp = fail(err)
+		return binary.BigEndian.AppendUint64(resp, uint64(size))
+	case opLock:
+		return fail(f.Lock(sqlite3vfs.LockType(d.u32())))
+	case opUnlock:
+		return fail(f.Unlock(sqlite3vfs.LockType(d.u32())))
+	case opCheckReserved:
+		ok, err := f.CheckReservedLock()
+		resp = fail(err)
+		return appendBool(resp, ok)
+	case opSectorSize:
+		return binary.BigEndian.AppendUint64(resp, uint64(f.SectorSize()))
+	case opCharacteristics:
+		return binary.BigEndian.AppendUint32(resp, uint32(f.DeviceCharacteristics()))
+	default:
+		return fail(sqlite3vfs.MisuseError)
+	}
+}
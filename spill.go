@@ -0,0 +1,118 @@
+package memvfs
+
+import (
+	"errors"
+	"os"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// WithTempBudget caps the size of each temporary file SQLite creates for a
+// sort, index build or statement journal at limit bytes. A temporary file
+// growing past limit is moved to a file in spillDir, or in one of the
+// directories given with WithSpillDirs, and continues there; if there is no
+// such directory its writes fail instead, failing the statement rather
+// than exhausting memory. SQLite reports that failure as a disk I/O error;
+// Stats counts both outcomes.
+func WithTempBudget(limit int64, spillDir string) Option {
+	return func(v *MemVFS) {
+		v.tempLimit = limit
+		if spillDir != "" {
+			v.spillDirs = append(v.spillDirs, spillDir)
+		}
+	}
+}
+
+var errNoSpillDir = errors.New("memvfs: no spill directory configured")
+
+// temporary reports whether e is one of SQLite's temporary files.
+func (e *entry) temporary() bool {
+	switch e.role {
+	case RoleTempDB, RoleTempJournal, RoleTransientDB, RoleSubjournal:
+		return true
+	default:
+		return false
+	}
+}
+
+// overBudget reports whether growing e, named name, to size exceeds the
+// temp budget, spilling e to disk when that is configured. e must be
+// locked.
+func (v *MemVFS) overBudget(e *entry, name string, size int64) bool {
+	if v.tempLimit <= 0 || e.disk != nil || !e.temporary() || size <= v.tempLimit {
+		return false
+	}
+	if len(v.spillDirs) == 0 {
+		v.tempRejected.Add(1)
+		return true
+	}
+
+	f, err := v.spillFile(name)
+	if err != nil {
+		v.tempRejected.Add(1)
+		return true
+	}
+	if _, err := f.WriteAt(e.data, 0); err != nil {
+		f.Close()
+		v.tempRejected.Add(1)
+		return true
+	}
+	e.disk = f
+	e.diskSize = int64(len(e.data))
+	e.data = nil
+	e.guarded = false
+	v.tempSpilled.Add(1)
+	return false
+}
+
+// spillFile creates a file to hold the contents of name in one of the spill
+// directories, chosen by v's placement. The file is already unlinked.
+func (v *MemVFS) spillFile(name string) (*os.File, error) {
+	if len(v.spillDirs) == 0 {
+		return nil, errNoSpillDir
+	}
+	placement := v.placement
+	if placement == nil {
+		placement = HashPlacement{}
+	}
+	dir := v.spillDirs[placement.Place(name, len(v.spillDirs))]
+	f, err := os.CreateTemp(dir, "memvfs-spill-*")
+	if err != nil {
+		return nil, err
+	}
+	// The file is only reachable through the handle from now on.
+	os.Remove(f.Name())
+	return f, nil
+}
+
+// writeDisk writes p at off to a spilled file. e must be locked.
+func (e *entry) writeDisk(p []byte, off int64) (int, error) {
+	n, err := e.disk.WriteAt(p, off)
+	if err != nil {
+		return n, sqlite3vfs.IOErrorWrite
+	}
+	e.diskSize = max(e.diskSize, off+int64(n))
+	return n, nil
+}
+
+// truncateDisk truncates a spilled file. e must be locked.
+func (e *entry) truncateDisk(size int64) error {
+	if err := e.disk.Truncate(size); err != nil {
+		return sqlite3vfs.IOError
+	}
+	e.diskSize = size
+	return nil
+}
+
+// release frees resources held outside memory once e is removed. v.mu must
+// be held.
+func (e *entry) release() error {
+	var errs []error
+	if e.src != nil {
+		errs = append(errs, e.src.Close())
+	}
+	if e.disk != nil {
+		errs = append(errs, e.disk.Close())
+	}
+	return errors.Join(errs...)
+}
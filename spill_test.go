@@ -0,0 +1,65 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestTempBudget(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		spill bool
+	}{
+		{"spill", true},
+		{"fail", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spillDir := ""
+			if tc.spill {
+				spillDir = t.TempDir()
+			}
+			tv := memvfs.New(memvfs.WithTempBudget(256<<10, spillDir))
+			vfsName := "memvfs-temp-" + tc.name
+			if err := tv.Register(vfsName); err != nil {
+				t.Fatalf("Register error: %v", err)
+			}
+			db, err := sql.Open("sqlite3", fmt.Sprintf("file:test-temp-budget.db?vfs=%s&cache=shared", vfsName))
+			if err != nil {
+				t.Fatalf("Failed to open DB: %v", err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			for _, stmt := range []string{
+				`PRAGMA cache_size = 10`,
+				`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`,
+				`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 5000)
+				 INSERT INTO demo(data) SELECT hex(randomblob(200)) FROM n`,
+			} {
+				if _, err := db.Exec(stmt); err != nil {
+					t.Fatalf("%s: %v", stmt, err)
+				}
+			}
+
+			rows, err := db.Query(`SELECT data FROM demo ORDER BY data`)
+			if err == nil {
+				for rows.Next() {
+				}
+				err = rows.Err()
+				rows.Close()
+			}
+
+			s := tv.Stats()
+			if tc.spill {
+				if err != nil || s.TempSpilled == 0 {
+					t.Errorf("Expected sort to spill to disk, got %v with %d spilled", err, s.TempSpilled)
+				}
+			} else if err == nil || s.TempRejected == 0 {
+				t.Errorf("Expected sort over budget to fail, got %v with %d rejected", err, s.TempRejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package memvfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"syscall"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// sqliteErr translates err, returned by a VFS method, into the most precise
+// code the binding can pass to SQLite. The binding only recognizes its own
+// errors, unwrapped, and reports anything else as SQLITE_ERROR, which
+// SQLite describes as a "SQL logic error". So errors carrying a SQLite code
+// keep it even when wrapped, the store's own errors and common system errors map
+// to their SQLite equivalent, and anything else is an I/O error. io.EOF is
+// kept for the binding to report a short read.
+//
+// The binding has no way to return the other extended I/O error codes, such
+// as SQLITE_IOERR_ACCESS or SQLITE_IOERR_NOMEM, so those failures report
+// the nearest primary code.
+func sqliteErr(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	for _, code := range sqliteErrors {
+		if errors.Is(err, code) {
+			return code
+		}
+	}
+	switch {
+	case errors.Is(err, ErrBusy):
+		return sqlite3vfs.BusyError
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrExist), errors.Is(err, fs.ErrNotExist):
+		return sqlite3vfs.CantOpenError
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.EROFS):
+		return sqlite3vfs.ReadOnlyError
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return sqlite3vfs.FullError
+	case errors.Is(err, syscall.ENOMEM):
+		return sqlite3vfs.NoMemError
+	case errors.Is(err, context.Canceled):
+		return sqlite3vfs.InterruptError
+	default:
+		return sqlite3vfs.IOError
+	}
+}
+
+// translateErr applies sqliteErr to *err, for deferring in VFS methods.
+func translateErr(err *error) {
+	*err = sqliteErr(*err)
+}
@@ -0,0 +1,82 @@
+package memvfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestErrorTranslation(t *testing.T) {
+	var truncateErr, readErr atomic.Pointer[error]
+	ev := memvfs.New(memvfs.WithHooks(memvfs.Hooks{
+		OnTruncate: func(name string, size int64) error {
+			return *truncateErr.Load()
+		},
+		OnRead: func(name string, off int64, n int) error {
+			if err := readErr.Load(); err != nil {
+				return *err
+			}
+			return nil
+		},
+	}))
+
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	f, _, err := ev.Open("translated.db", flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+
+	for _, tc := range []struct {
+		err  error
+		want error
+	}{
+		{fmt.Errorf("quota: %w", sqlite3vfs.FullError), sqlite3vfs.FullError},
+		{fmt.Errorf("admin: %w", memvfs.ErrBusy), sqlite3vfs.BusyError},
+		{fmt.Errorf("missing: %w", memvfs.ErrNotFound), sqlite3vfs.CantOpenError},
+		{&fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}, sqlite3vfs.ReadOnlyError},
+		{fmt.Errorf("spill: %w", syscall.ENOSPC), sqlite3vfs.FullError},
+		{context.Canceled, sqlite3vfs.InterruptError},
+		{errors.New("backend exploded"), sqlite3vfs.IOError},
+	} {
+		truncateErr.Store(&tc.err)
+		if err := f.Truncate(0); err != tc.want {
+			t.Errorf("Expected %v to reach SQLite as %v, got %v", tc.err, tc.want, err)
+		}
+	}
+
+	// Unless translated, a plain error reaches SQLite as SQLITE_ERROR and
+	// is reported as a "SQL logic error".
+	if err := ev.Register("memvfs-errors"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := ev.OpenDB("translated-sql.db", memvfs.ProfileTest)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	plain := errors.New("backend exploded")
+	readErr.Store(&plain)
+	// A fresh connection has to read the schema from the file.
+	db.SetMaxIdleConns(0)
+	conn, err := db.Conn(context.Background())
+	if err == nil {
+		err = conn.QueryRowContext(context.Background(), `SELECT count(*) FROM demo`).Scan(new(int))
+		conn.Close()
+	}
+	readErr.Store(nil)
+	if err == nil || !strings.Contains(err.Error(), "disk I/O error") {
+		t.Errorf("Expected a failed read to be a disk I/O error, got %v", err)
+	}
+}
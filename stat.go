@@ -0,0 +1,206 @@
+package memvfs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// Role is the part a file plays for SQLite, derived from the flags it was
+// opened with.
+//
+// https://www.sqlite.org/c3ref/c_open_autoproxy.html
+type Role int
+
+const (
+	RoleUnknown Role = iota
+	RoleMainDB
+	RoleMainJournal
+	RoleWAL
+	RoleTempDB
+	RoleTempJournal
+	RoleTransientDB
+	RoleSubjournal
+	RoleSuperJournal
+
+	numRoles
+)
+
+func roleFromFlags(flags sqlite3vfs.OpenFlag) Role {
+	switch {
+	case flags&sqlite3vfs.OpenMainDB != 0:
+		return RoleMainDB
+	case flags&sqlite3vfs.OpenMainJournal != 0:
+		return RoleMainJournal
+	case flags&sqlite3vfs.OpenWAL != 0:
+		return RoleWAL
+	case flags&sqlite3vfs.OpenTempDB != 0:
+		return RoleTempDB
+	case flags&sqlite3vfs.OpenTempJournal != 0:
+		return RoleTempJournal
+	case flags&sqlite3vfs.OpenTransientDB != 0:
+		return RoleTransientDB
+	case flags&sqlite3vfs.OpenSubJournal != 0:
+		return RoleSubjournal
+	case flags&sqlite3vfs.OpenSuperJournal != 0:
+		return RoleSuperJournal
+	default:
+		return RoleUnknown
+	}
+}
+
+// sideFile reports whether fileName is named as a journal or WAL.
+func sideFile(fileName string) bool {
+	return strings.HasSuffix(fileName, "-journal") || strings.HasSuffix(fileName, "-wal")
+}
+
+// ownerName returns the main database a journal or WAL named fileName belongs
+// to, following SQLite's fixed suffixes. It returns "" for other roles.
+func ownerName(fileName string, role Role) string {
+	switch role {
+	case RoleMainJournal:
+		return strings.TrimSuffix(fileName, "-journal")
+	case RoleWAL:
+		return strings.TrimSuffix(fileName, "-wal")
+	default:
+		return ""
+	}
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleUnknown:
+		return "unknown"
+	case RoleMainDB:
+		return "main-db"
+	case RoleMainJournal:
+		return "main-journal"
+	case RoleWAL:
+		return "wal"
+	case RoleTempDB:
+		return "temp-db"
+	case RoleTempJournal:
+		return "temp-journal"
+	case RoleTransientDB:
+		return "transient-db"
+	case RoleSubjournal:
+		return "subjournal"
+	case RoleSuperJournal:
+		return "super-journal"
+	default:
+		return fmt.Sprintf("Role<%d>", int(r))
+	}
+}
+
+// FileInfo describes a file held by a MemVFS.
+type FileInfo struct {
+	Name    string
+	UUID    UUID
+	Size    int64
+	ModTime time.Time
+
+	// Flags are the SQLITE_OPEN_* flags the file was created with.
+	Flags sqlite3vfs.OpenFlag
+	Role  Role
+
+	// IOStats counts the IO performed on the file since it was created,
+	// and SideIO the IO on the journals and WAL files of a main database.
+	IOStats
+	SideIO IOStats
+
+	// CommittedBytes sums, over the transactions on a main database, the
+	// bytes each one changed, counting bytes written twice once.
+	// WriteAmplification is the number of bytes SQLite wrote to the
+	// database and its journal or WAL per committed byte; comparing two
+	// readings of the counters gives the amplification of the traffic in
+	// between, to weigh journal modes and page sizes against each other.
+	CommittedBytes     int64
+	WriteAmplification float64
+
+	// Degraded reports that an operation on the file ran past the deadline
+	// set with WithIODeadline. CircuitOpen reports that reads currently
+	// bypass the file's backend; see WithCircuitBreaker.
+	Degraded    bool
+	CircuitOpen bool
+
+	// Cache describes the file's block cache if it is backend-backed.
+	Cache CacheStats
+
+	// Opens and Closes count the handles opened and closed on the file.
+	// Churn is set, to a diagnosis and remedy, when connections to it are
+	// opened and closed so often that they hardly serve a query each.
+	Opens  int64
+	Closes int64
+	Churn  string
+
+	// IdleSince is when the file was last closed or modified if no handle
+	// has it open, and zero otherwise.
+	IdleSince time.Time
+
+	// Pinned counts the bytes of the file currently kept hot with Pin.
+	Pinned int64
+
+	// StorageClass is where the file's contents are kept; see
+	// ApplyTiering.
+	StorageClass StorageClass
+}
+
+// Stat returns information about the named file.
+func (v *MemVFS) Stat(name string) (FileInfo, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return FileInfo{}, ErrNotFound
+	}
+
+	return v.fileInfo(name, e), nil
+}
+
+// ListFiles returns information about every file whose name starts with
+// prefix, sorted by name; "" lists the whole store, including journals, WAL
+// files and temporary files.
+func (v *MemVFS) ListFiles(prefix string) []FileInfo {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var files []FileInfo
+	for name, e := range v.files {
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, v.fileInfo(name, e))
+		}
+	}
+	slices.SortFunc(files, func(a, b FileInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return files
+}
+
+// fileInfo describes e, stored as name. v.mu must be held.
+func (v *MemVFS) fileInfo(name string, e *entry) FileInfo {
+	return FileInfo{
+		Name:               name,
+		UUID:               e.uuid,
+		Size:               e.size(),
+		ModTime:            e.modTime,
+		Flags:              e.flags,
+		Role:               e.role,
+		IOStats:            e.io.load(),
+		SideIO:             e.sideIO.load(),
+		CommittedBytes:     e.committed,
+		WriteAmplification: e.writeAmplification(),
+		Degraded:           e.degraded,
+		CircuitOpen:        v.circuitOpen(e),
+		Cache:              e.cacheStats(),
+		Opens:              e.opens,
+		Closes:             e.closes,
+		Churn:              e.churn(),
+		IdleSince:          e.idleSince(),
+		Pinned:             e.pinned().bytes(),
+		StorageClass:       e.class(),
+	}
+}
@@ -0,0 +1,183 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestStat(t *testing.T) {
+	dbName := "test-stat.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	info, err := v.Stat(dbName)
+	if err != nil {
+		t.Fatalf("Stat %v: %v", dbName, err)
+	}
+	if info.Role != memvfs.RoleMainDB {
+		t.Errorf("Expected role %v, got %v", memvfs.RoleMainDB, info.Role)
+	}
+	if info.Flags&sqlite3vfs.OpenMainDB == 0 {
+		t.Errorf("Expected OpenMainDB in flags %#x", info.Flags)
+	}
+	if info.Size == 0 {
+		t.Errorf("Expected non-zero size for %v", dbName)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin error: %v", err)
+	}
+	_, err = tx.Exec(`INSERT INTO demo(data) VALUES ('journaled')`)
+	if err != nil {
+		t.Fatalf("Insert error: %v", err)
+	}
+	info, err = v.Stat(dbName + "-journal")
+	if err != nil {
+		t.Fatalf("Stat journal: %v", err)
+	}
+	if info.Role != memvfs.RoleMainJournal {
+		t.Errorf("Expected role %v, got %v", memvfs.RoleMainJournal, info.Role)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit error: %v", err)
+	}
+
+	_, err = v.Stat("test-stat-missing.db")
+	if !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	v := memvfs.New()
+	err := v.Batch(func(tx *memvfs.AdminTx) error {
+		for _, name := range []string{"tenant-b.db", "tenant-a.db", "other.db"} {
+			if err := tx.Put(name, []byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+
+	if files := v.ListFiles(""); len(files) != 3 {
+		t.Errorf("Expected 3 files, got %+v", files)
+	}
+	files := v.ListFiles("tenant-")
+	if len(files) != 2 || files[0].Name != "tenant-a.db" || files[1].Name != "tenant-b.db" {
+		t.Fatalf("Expected the tenant files in order, got %+v", files)
+	}
+	if files[0].Size != int64(len("tenant-a.db")) {
+		t.Errorf("Expected size %d, got %d", len("tenant-a.db"), files[0].Size)
+	}
+}
+
+func TestChurn(t *testing.T) {
+	// Pulled files outlive their handles, like the databases churning
+	// callers reopen.
+	tv := memvfs.New()
+	err := tv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("template.db", make([]byte, 4096))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	v := memvfs.New()
+	name := "test-churn.db"
+	if _, err := tv.CopyTo(context.Background(), "template.db", v, name); err != nil {
+		t.Fatalf("CopyTo error: %v", err)
+	}
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	keep, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer keep.Close()
+
+	for i := 0; i < 150; i++ {
+		f, _, err := v.Open(name, flags)
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		f.ReadAt(make([]byte, 100), 0)
+		f.Close()
+	}
+
+	fi, err := v.Stat(name)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if fi.Opens != 151 || fi.Closes != 150 {
+		t.Errorf("Expected 151 opens and 150 closes, got %d and %d", fi.Opens, fi.Closes)
+	}
+	if !strings.Contains(fi.Churn, "SetMaxIdleConns") {
+		t.Errorf("Expected churn with a remedy, got %q", fi.Churn)
+	}
+	if s := v.Stats(); s.Churning != 1 {
+		t.Errorf("Expected 1 churning file, got %d", s.Churning)
+	}
+
+	for i := 0; i < 150*50; i++ {
+		keep.ReadAt(make([]byte, 100), 0)
+	}
+	if fi, _ := v.Stat(name); fi.Churn != "" {
+		t.Errorf("Expected a busy long-lived connection to offset the churn, got %q", fi.Churn)
+	}
+}
+
+func TestWriteAmplification(t *testing.T) {
+	amplification := func(name, journalMode string) memvfs.FileInfo {
+		db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", name))
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec(`PRAGMA journal_mode = ` + journalMode); err != nil {
+			t.Fatalf("Pragma error: %v", err)
+		}
+		if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+		for i := 0; i < 50; i++ {
+			if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(100)); err != nil {
+				t.Fatalf("Insert error: %v", err)
+			}
+		}
+		fi, err := v.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat error: %v", err)
+		}
+		return fi
+	}
+
+	mem := amplification("test-writeamp-memory.db", "MEMORY")
+	del := amplification("test-writeamp-delete.db", "DELETE")
+	if mem.CommittedBytes == 0 || mem.CommittedBytes > mem.BytesWritten {
+		t.Errorf("Expected committed bytes within the %d written, got %d", mem.BytesWritten, mem.CommittedBytes)
+	}
+	if mem.SideIO.BytesWritten != 0 || del.SideIO.BytesWritten == 0 {
+		t.Errorf("Expected journal writes only with journal_mode=DELETE, got %d and %d", mem.SideIO.BytesWritten, del.SideIO.BytesWritten)
+	}
+	if mem.WriteAmplification < 1 || del.WriteAmplification <= mem.WriteAmplification {
+		t.Errorf("Expected a rollback journal to amplify writes, got %.2f with it and %.2f without", del.WriteAmplification, mem.WriteAmplification)
+	}
+}
@@ -0,0 +1,290 @@
+package memvfs
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// IOStats counts IO operations issued by SQLite.
+type IOStats struct {
+	Reads        int64
+	Writes       int64
+	Truncates    int64
+	Syncs        int64
+	BytesRead    int64
+	BytesWritten int64
+}
+
+func (s *IOStats) add(o IOStats) {
+	s.Reads += o.Reads
+	s.Writes += o.Writes
+	s.Truncates += o.Truncates
+	s.Syncs += o.Syncs
+	s.BytesRead += o.BytesRead
+	s.BytesWritten += o.BytesWritten
+}
+
+// CacheStats describes how well the block cache of a backend-backed file,
+// such as a mounted backup, serves reads. Hits and Misses count blocks read
+// from the cache and fetched from the backend; CachedBytes is the size of
+// the blocks held.
+type CacheStats struct {
+	Hits         int64
+	Misses       int64
+	BytesFetched int64
+	CachedBytes  int64
+}
+
+func (s *CacheStats) add(o CacheStats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.BytesFetched += o.BytesFetched
+	s.CachedBytes += o.CachedBytes
+}
+
+// HitRatio returns the fraction of block reads served from the cache, or 0
+// if there were none.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cacheStatser is implemented by sources that cache their backend.
+type cacheStatser interface {
+	cacheStats() CacheStats
+}
+
+// cacheStats returns the cache statistics of e's source, if it has any.
+func (e *entry) cacheStats() CacheStats {
+	if c, ok := e.src.(cacheStatser); ok {
+		return c.cacheStats()
+	}
+	return CacheStats{}
+}
+
+// RoleStats is the usage attributed to files of a single Role.
+type RoleStats struct {
+	// Files and Bytes describe the files of this role currently stored.
+	Files int
+	Bytes int64
+
+	// IOStats is cumulative and includes files that have been deleted, so
+	// short-lived journals and temp spills remain visible.
+	IOStats
+}
+
+// Stats summarizes the memory usage and IO of a MemVFS.
+type Stats struct {
+	// Bytes is the size of the files held in memory, which is what counts
+	// against MemoryBudget; see WithMemoryBudget.
+	Files        int
+	Bytes        int64
+	MemoryBudget int64
+	IOStats
+
+	ByRole map[Role]RoleStats
+
+	// ByLabel is the IO of handles annotated with each label; see Annotate.
+	ByLabel map[string]IOStats
+
+	// TempSpilled and TempRejected count the temporary files that exceeded
+	// the budget set with WithTempBudget and were moved to disk or failed.
+	TempSpilled  int64
+	TempRejected int64
+
+	// Degraded and CircuitOpen count the files in those states; see
+	// FileInfo.
+	Degraded    int
+	CircuitOpen int
+
+	// Churning counts the files with FileInfo.Churn set.
+	Churning int
+
+	// Compressed counts the warm files, whose compressed size is what Bytes
+	// includes for them. Cold counts the files moved to disk by tiering;
+	// see StorageCold.
+	Compressed int
+	Cold       int
+
+	// Handles counts the handles SQLite has open on the store's files.
+	Handles int
+
+	// Evicted counts the files dropped by eviction; see WithEviction.
+	Evicted int64
+
+	// GrowthRate is how fast the files are growing, in bytes per second,
+	// and BudgetLeft how long the memory budget lasts at that rate, or 0
+	// without a budget or growth; see Forecast.
+	GrowthRate float64
+	BudgetLeft time.Duration
+
+	// Cache sums the block caches of backend-backed files.
+	Cache CacheStats
+}
+
+// Stats returns a snapshot of the store's usage, broken down by file role.
+// Roles that have never been used are omitted from ByRole.
+func (v *MemVFS) Stats() Stats {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var byRole [numRoles]RoleStats
+	var degraded, circuitOpen, churning, compressed, cold, handles int
+	var cache CacheStats
+	var growth float64
+	now := time.Now()
+	for _, e := range v.files {
+		cache.add(e.cacheStats())
+		handles += len(e.handles)
+		growth += e.growth.rate(now, e.size())
+		if e.churn() != "" {
+			churning++
+		}
+		if e.compressed != nil {
+			compressed++
+		}
+		if e.cold {
+			cold++
+		}
+		if e.degraded {
+			degraded++
+		}
+		if v.circuitOpen(e) {
+			circuitOpen++
+		}
+		byRole[e.role].Files++
+		byRole[e.role].Bytes += e.footprint()
+	}
+
+	s := Stats{
+		ByRole:       make(map[Role]RoleStats),
+		MemoryBudget: v.memLimit,
+		TempSpilled:  v.tempSpilled.Load(),
+		TempRejected: v.tempRejected.Load(),
+		Degraded:     degraded,
+		CircuitOpen:  circuitOpen,
+		Churning:     churning,
+		Compressed:   compressed,
+		Cold:         cold,
+		Handles:      handles,
+		Evicted:      v.evicted.Load(),
+		GrowthRate:   growth,
+		Cache:        cache,
+	}
+	for r := range byRole {
+		rs := byRole[r]
+		rs.IOStats = v.roleIO[r].load()
+		if rs.Files == 0 && rs.IOStats == (IOStats{}) {
+			continue
+		}
+		s.Files += rs.Files
+		s.Bytes += rs.Bytes
+		s.IOStats.add(rs.IOStats)
+		s.ByRole[Role(r)] = rs
+	}
+
+	if v.memLimit > 0 && growth > 0 {
+		s.BudgetLeft = time.Duration(float64(max(v.memLimit-s.Bytes, 0)) / growth * float64(time.Second))
+	}
+
+	if len(v.labelIO) > 0 {
+		s.ByLabel = make(map[string]IOStats, len(v.labelIO))
+		for label, io := range v.labelIO {
+			s.ByLabel[label] = io.load()
+		}
+	}
+	return s
+}
+
+// The count helpers record an operation through f against the file, its
+// role and f's label. They need f.mu but not v.mu.
+
+func (v *MemVFS) countRead(f *MemFile, e *entry, n int) {
+	v.count(f, e, IOStats{Reads: 1, BytesRead: int64(n)})
+}
+
+func (v *MemVFS) countWrite(f *MemFile, e *entry, n int) {
+	v.count(f, e, IOStats{Writes: 1, BytesWritten: int64(n)})
+}
+
+func (v *MemVFS) countTruncate(f *MemFile, e *entry) {
+	v.count(f, e, IOStats{Truncates: 1})
+}
+
+func (v *MemVFS) countSync(f *MemFile, e *entry) {
+	v.count(f, e, IOStats{Syncs: 1})
+}
+
+func (v *MemVFS) count(f *MemFile, e *entry, op IOStats) {
+	e.io.add(op)
+	v.roleIO[e.role].add(op)
+	if e.owner != nil {
+		e.owner.sideIO.add(op)
+	}
+	if f.labelIO != nil {
+		f.labelIO.add(op)
+	}
+}
+
+// ioCounters accumulates IOStats from many goroutines at once. Updates go to
+// one of GOMAXPROCS cache-line sized shards picked at random, so concurrent
+// readers of a hot file do not contend on a single counter; load sums the
+// shards. The zero value is ready to use and allocates on first update.
+type ioCounters struct {
+	shards atomic.Pointer[[]ioShard]
+}
+
+type ioShard struct {
+	reads, writes, truncates, syncs atomic.Int64
+	bytesRead, bytesWritten         atomic.Int64
+	_                               [16]byte
+}
+
+func (c *ioCounters) add(op IOStats) {
+	shards := c.shards.Load()
+	if shards == nil {
+		s := make([]ioShard, runtime.GOMAXPROCS(0))
+		if !c.shards.CompareAndSwap(nil, &s) {
+			shards = c.shards.Load()
+		} else {
+			shards = &s
+		}
+	}
+	sh := &(*shards)[rand.Uint32()%uint32(len(*shards))]
+	if op.Reads != 0 {
+		sh.reads.Add(op.Reads)
+		sh.bytesRead.Add(op.BytesRead)
+	}
+	if op.Writes != 0 {
+		sh.writes.Add(op.Writes)
+		sh.bytesWritten.Add(op.BytesWritten)
+	}
+	if op.Truncates != 0 {
+		sh.truncates.Add(op.Truncates)
+	}
+	if op.Syncs != 0 {
+		sh.syncs.Add(op.Syncs)
+	}
+}
+
+func (c *ioCounters) load() IOStats {
+	var s IOStats
+	shards := c.shards.Load()
+	if shards == nil {
+		return s
+	}
+	for i := range *shards {
+		sh := &(*shards)[i]
+		s.Reads += sh.reads.Load()
+		s.Writes += sh.writes.Load()
+		s.Truncates += sh.truncates.Load()
+		s.Syncs += sh.syncs.Load()
+		s.BytesRead += sh.bytesRead.Load()
+		s.BytesWritten += sh.bytesWritten.Load()
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestStatsByRole(t *testing.T) {
+	v := memvfs.New()
+	if err := sqlite3vfs.RegisterVFS("memvfs-stats", v); err != nil {
+		t.Fatalf("Failed to register VFS: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:test-stats.db?vfs=memvfs-stats&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, fmt.Sprintf("row %d", i))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	s := v.Stats()
+	main, ok := s.ByRole[memvfs.RoleMainDB]
+	if !ok {
+		t.Fatalf("No stats for %v: %+v", memvfs.RoleMainDB, s)
+	}
+	if main.Files != 1 || main.Bytes == 0 || main.Writes == 0 {
+		t.Errorf("Unexpected main-db stats: %+v", main)
+	}
+
+	// Journals are deleted after every commit but their IO is still counted.
+	journal, ok := s.ByRole[memvfs.RoleMainJournal]
+	if !ok {
+		t.Fatalf("No stats for %v: %+v", memvfs.RoleMainJournal, s)
+	}
+	if journal.Files != 0 || journal.BytesWritten == 0 {
+		t.Errorf("Unexpected main-journal stats: %+v", journal)
+	}
+
+	if s.Bytes != main.Bytes || s.BytesWritten != main.BytesWritten+journal.BytesWritten {
+		t.Errorf("Totals do not add up: %+v", s)
+	}
+}
+
+func TestStatsConcurrentReads(t *testing.T) {
+	v := memvfs.New()
+	f, _, err := v.Open("test-stats-concurrent.db", sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(make([]byte, 4096), 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+
+	const goroutines, reads = 8, 1000
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 512)
+			for i := 0; i < reads; i++ {
+				f.ReadAt(buf, int64(i%8)*512)
+			}
+		}()
+	}
+	wg.Wait()
+
+	info, err := v.Stat("test-stats-concurrent.db")
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if info.Reads != goroutines*reads || info.BytesRead != goroutines*reads*512 {
+		t.Errorf("Expected %d reads, got %+v", goroutines*reads, info.IOStats)
+	}
+	if got := v.Stats().ByRole[memvfs.RoleMainDB].Reads; got != goroutines*reads {
+		t.Errorf("Expected %d main-db reads, got %d", goroutines*reads, got)
+	}
+}
@@ -0,0 +1,230 @@
+package memvfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// BackingStore keeps databases outside the store, as whole files by name,
+// so that they outlive the process: on disk, in object storage or in
+// another database. Load returns an error wrapping ErrNotFound for a name
+// it does not hold, and Delete succeeds for one. Unlike a BackupStore, read
+// block by block by MountBackup, a BackingStore is read and written whole.
+//
+// Store must not keep data once it returns.
+type BackingStore interface {
+	Load(ctx context.Context, name string) ([]byte, error)
+	Store(ctx context.Context, name string, data []byte) error
+	Delete(ctx context.Context, name string) error
+
+	// List returns the names held starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+var errNoBackingStore = errors.New("memvfs: no backing store")
+
+// WithBackingStore hydrates the store from backing and persists to it: a
+// main database missing from the store is loaded from backing when it is
+// opened, Hydrate loads them ahead of time, Flush and AutoFlush store
+// modified databases to it unless given another destination, and deleting
+// a main database deletes it from backing.
+//
+// The store reads and writes whole databases, so backing suits databases
+// that are small next to the time between flushes. See WithWriteThrough to
+// store on every commit instead.
+func WithBackingStore(backing BackingStore) Option {
+	return func(v *MemVFS) {
+		v.store = backing
+	}
+}
+
+// Hydrate loads every database the backing store lists under prefix that is
+// not in the store yet, and returns the names loaded. It stops at the first
+// database a validator set with WithValidators rejects.
+func (v *MemVFS) Hydrate(ctx context.Context, prefix string) ([]string, error) {
+	if v.store == nil {
+		return nil, errNoBackingStore
+	}
+	names, err := v.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var loaded []string
+	for _, name := range names {
+		if _, err := v.Stat(name); err == nil {
+			continue
+		}
+		data, err := v.store.Load(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %w", v.LogName(name), err)
+		}
+		if err := v.Validate(name, data); err != nil {
+			return loaded, err
+		}
+		v.mu.Lock()
+		if _, ok := v.files[name]; !ok {
+			v.hydrate(name, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite, data)
+			loaded = append(loaded, name)
+		}
+		v.mu.Unlock()
+	}
+	if len(loaded) > 0 {
+		v.log(slog.LevelInfo, "hydrated", "files", len(loaded))
+	}
+	return loaded, nil
+}
+
+// load fetches name from the backing store ahead of opening it with flags,
+// if it is a main database the store does not hold, and reports whether
+// it was found. A database the validators reject fails to load.
+func (v *MemVFS) load(name string, flags sqlite3vfs.OpenFlag) ([]byte, bool, error) {
+	if v.store == nil || name == "" || roleFromFlags(flags) != RoleMainDB || v.backed(flags) {
+		return nil, false, nil
+	}
+	v.mu.RLock()
+	_, ok := v.files[name]
+	v.mu.RUnlock()
+	if ok {
+		return nil, false, nil
+	}
+	data, err := v.store.Load(context.Background(), name)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		v.log(slog.LevelError, "loading from backing store failed", "file", v.LogName(name), "error", err)
+		return nil, false, err
+	}
+	if err := v.Validate(name, data); err != nil {
+		v.log(slog.LevelError, "backing store image rejected", "file", v.LogName(name), "error", err)
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// hydrate creates the entry of name, opened with flags, holding data as
+// loaded from the backing store. v.mu must be held.
+func (v *MemVFS) hydrate(name string, flags sqlite3vfs.OpenFlag, data []byte) *entry {
+	e := v.lookup(name, flags)
+	e.data = data
+	e.modified()
+	e.written.Store(e.version)
+	e.flushed = e.version
+	v.account(e)
+	return e
+}
+
+// DirStore is a BackingStore holding each database as a file in a
+// directory, under its name with slashes as path separators. Files are
+// replaced by writing a temporary file and renaming it over, so each is
+// always a whole database.
+type DirStore string
+
+func (d DirStore) Load(ctx context.Context, name string) ([]byte, error) {
+	path, err := dirPath(string(d), name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return data, err
+}
+
+func (d DirStore) Store(ctx context.Context, name string, data []byte) error {
+	path, err := dirPath(string(d), name)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (d DirStore) Delete(ctx context.Context, name string) error {
+	path, err := dirPath(string(d), name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (d DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(string(d), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	slices.Sort(names)
+	return names, err
+}
+
+// dirPath returns the path of the file keeping name in dir.
+func dirPath(dir, name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("memvfs: %q cannot be kept as a file", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// tempPrefix starts the names of the temporary files of writeFileAtomic.
+const tempPrefix = ".memvfs-"
+
+// writeFileAtomic replaces the file at path with data, durably.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err := errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,170 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+// mapStore is a BackingStore in a map.
+type mapStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *mapStore) Load(ctx context.Context, name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", memvfs.ErrNotFound, name)
+	}
+	return slices.Clone(data), nil
+}
+
+func (s *mapStore) Store(ctx context.Context, name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = slices.Clone(data)
+	return nil
+}
+
+func (s *mapStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, name)
+	return nil
+}
+
+func (s *mapStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func TestBackingStore(t *testing.T) {
+	backing := &mapStore{files: make(map[string][]byte)}
+	first := memvfs.New(memvfs.WithBackingStore(backing), memvfs.WithRetainOnClose())
+	if err := first.Register("memvfs-store-first"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	for _, name := range []string{"app/a.db", "app/b.db", "other.db"} {
+		db, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-store-first")
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		if _, err := db.Exec(`CREATE TABLE demo (name TEXT); INSERT INTO demo VALUES (?)`, name); err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+		db.Close()
+	}
+	names, err := first.Flush(nil, nil)
+	if err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if len(names) != 3 || len(backing.files) != 3 {
+		t.Fatalf("Expected 3 databases stored, flushed %v", names)
+	}
+
+	// A second store opens them from the backing store, or hydrates them
+	// ahead of time.
+	second := memvfs.New(memvfs.WithBackingStore(backing))
+	if err := second.Register("memvfs-store-second"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	loaded, err := second.Hydrate(context.Background(), "app/")
+	if err != nil {
+		t.Fatalf("Hydrate error: %v", err)
+	}
+	if want := []string{"app/a.db", "app/b.db"}; !slices.Equal(loaded, want) {
+		t.Errorf("Hydrated %v, want %v", loaded, want)
+	}
+	db, err := sql.Open("sqlite3", "file:other.db?vfs=memvfs-store-second")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	var got string
+	if err := db.QueryRow(`SELECT name FROM demo`).Scan(&got); err != nil || got != "other.db" {
+		t.Errorf("Expected other.db loaded from the backing store, got %q, %v", got, err)
+	}
+	db.Close()
+
+	if err := second.Delete("app/a.db", false); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, ok := backing.files["app/a.db"]; ok {
+		t.Errorf("Expected app/a.db deleted from the backing store")
+	}
+}
+
+func TestBackingStoreValidation(t *testing.T) {
+	backing := &mapStore{files: map[string][]byte{"garbage.db": []byte("not a database at all")}}
+	sv := memvfs.New(memvfs.WithBackingStore(backing), memvfs.WithValidators(memvfs.ValidateHeader()))
+	if err := sv.Register("memvfs-store-validate"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if _, err := sv.Hydrate(context.Background(), ""); !errors.Is(err, memvfs.ErrInvalidImage) {
+		t.Errorf("Expected Hydrate to reject the image, got %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:garbage.db?vfs=memvfs-store-validate")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err == nil {
+		t.Errorf("Expected opening a rejected image to fail")
+	}
+	if _, err := sv.Stat("garbage.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected the rejected image not to be loaded, got %v", err)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	store := memvfs.DirStore(dir)
+	ctx := context.Background()
+	for _, name := range []string{"b.db", "a/x.db", "a/y.db"} {
+		if err := store.Store(ctx, name, []byte(name)); err != nil {
+			t.Fatalf("Store error: %v", err)
+		}
+	}
+	// Leftovers of an interrupted store are not listed.
+	if err := os.WriteFile(filepath.Join(dir, "a", ".memvfs-123"), nil, 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	names, err := store.List(ctx, "a/")
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if want := []string{"a/x.db", "a/y.db"}; !slices.Equal(names, want) {
+		t.Errorf("Listed %v, want %v", names, want)
+	}
+	if data, err := store.Load(ctx, "a/x.db"); err != nil || string(data) != "a/x.db" {
+		t.Errorf("Load returned %q, %v", data, err)
+	}
+	if err := store.Delete(ctx, "a/x.db"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := store.Load(ctx, "a/x.db"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if _, err := store.Load(ctx, "../escape.db"); err == nil {
+		t.Errorf("Expected a name outside the directory to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package memvfs
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"unsafe"
+)
+
+// WriteFileTo writes the named file to w while it is frozen, so that the
+// export is a consistent image, and returns the number of bytes written.
+// Unlike GetFile it never copies the file: in-memory contents are written
+// from the file's own buffer, which administrative changes such as Put or
+// ApplyPatch replace rather than modify in place until the export is done,
+// and backend-backed files are streamed from their backend.
+func (v *MemVFS) WriteFileTo(name string, w io.Writer) (int64, error) {
+	unfreeze, err := v.Freeze(name)
+	if err != nil {
+		return 0, err
+	}
+	defer unfreeze()
+
+	v.mu.Lock()
+	e, ok := v.files[name]
+	if !ok {
+		v.mu.Unlock()
+		return 0, ErrNotFound
+	}
+	if err := v.thaw(e); err != nil {
+		v.mu.Unlock()
+		return 0, err
+	}
+	src, size, data := e.reader(), e.size(), e.data
+	if src == nil {
+		e.exports = append(e.exports, data)
+	}
+	v.mu.Unlock()
+
+	if src != nil {
+		return io.Copy(w, io.NewSectionReader(src, 0, size))
+	}
+	defer func() {
+		v.mu.RLock()
+		e.mu.Lock()
+		e.exports = slices.DeleteFunc(e.exports, func(b []byte) bool {
+			return sameBuffer(b, data)
+		})
+		v.unlockEntry(e)
+	}()
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// exporting reports whether WriteFileTo is writing out e's buffer. e must
+// be locked.
+func (e *entry) exporting() bool {
+	return slices.ContainsFunc(e.exports, func(b []byte) bool {
+		return sameBuffer(b, e.data)
+	})
+}
+
+// own gives e a buffer of its own before an administrative change modifies
+// it in place, if WriteFileTo is writing out the current one. e must be
+// locked.
+func (e *entry) own() {
+	if e.exporting() {
+		e.data = bytes.Clone(e.data)
+	}
+}
+
+// sameBuffer reports whether a and b share their backing array.
+func sameBuffer(a, b []byte) bool {
+	return cap(a) > 0 && cap(b) > 0 && unsafe.SliceData(a) == unsafe.SliceData(b)
+}
+
+// ReadFileFrom stores the contents of r, read until io.EOF, as the main
+// database name, like Put. The contents are read into the buffer that
+// becomes the file's, so a new file is not held twice. It fails with
+// ErrBusy if a connection is using name, and with ErrInvalidImage if a
+// validator set with WithValidators rejects the contents.
+func (v *MemVFS) ReadFileFrom(name string, r io.Reader) (int64, error) {
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	if err := v.Validate(name, buf.Bytes()); err != nil {
+		return n, err
+	}
+	return n, v.Batch(func(tx *AdminTx) error {
+		return tx.put(name, buf.Bytes(), true)
+	})
+}
@@ -0,0 +1,88 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestWriteFileTo(t *testing.T) {
+	dbName := "test-stream-src.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", dbName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := v.WriteFileTo(dbName, &buf)
+	if err != nil {
+		t.Fatalf("WriteFileTo error: %v", err)
+	}
+	want, _ := v.GetFile(dbName)
+	if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Exported %d bytes differing from the %d byte file", n, len(want))
+	}
+
+	copyName := "test-stream-copy.db"
+	if _, err := v.ReadFileFrom(copyName, &buf); err != nil {
+		t.Fatalf("ReadFileFrom error: %v", err)
+	}
+	defer v.Delete(copyName, false)
+	if n := countRows(t, copyName); n != 100 {
+		t.Errorf("Expected 100 rows in the imported copy, got %d", n)
+	}
+	if _, err := v.WriteFileTo("test-stream-missing.db", &buf); err == nil {
+		t.Errorf("Expected an error exporting a missing file")
+	}
+}
+
+// putDuringWrite replaces a file while it is being written out.
+type putDuringWrite struct {
+	bytes.Buffer
+	v    *memvfs.MemVFS
+	name string
+	data []byte
+	err  error
+}
+
+func (w *putDuringWrite) Write(p []byte) (int, error) {
+	w.err = w.v.PutFile(w.name, w.data)
+	return w.Buffer.Write(p)
+}
+
+func TestWriteFileToPutDuringExport(t *testing.T) {
+	sv := memvfs.New()
+	name := "test-stream-put.db"
+	before, after := bytes.Repeat([]byte{'a'}, 8192), bytes.Repeat([]byte{'b'}, 8192)
+	if err := sv.PutFile(name, before); err != nil {
+		t.Fatalf("PutFile error: %v", err)
+	}
+
+	w := &putDuringWrite{v: sv, name: name, data: after}
+	if _, err := sv.WriteFileTo(name, w); err != nil {
+		t.Fatalf("WriteFileTo error: %v", err)
+	}
+	if w.err != nil {
+		t.Fatalf("PutFile during export error: %v", w.err)
+	}
+	if !bytes.Equal(w.Bytes(), before) {
+		t.Errorf("Export changed by a Put made while it was written")
+	}
+	if got, err := sv.GetFile(name); err != nil || !bytes.Equal(got, after) {
+		t.Errorf("Expected the Put to apply after the export, got %v", err)
+	}
+}
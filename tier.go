@@ -0,0 +1,327 @@
+package memvfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// StorageClass is where a file's contents are kept while it is not in use.
+type StorageClass int
+
+const (
+	// StorageAuto leaves a file to the TieringPolicy; it is only meaningful
+	// to SetStorageClass.
+	StorageAuto StorageClass = iota
+
+	// StorageHot files are held in memory as is.
+	StorageHot
+
+	// StorageWarm files are held in memory compressed with zstd; see
+	// CompressIdle.
+	StorageWarm
+
+	// StorageCold files are moved to a file in one of the directories set
+	// with WithSpillDirs or WithTempBudget.
+	StorageCold
+)
+
+func (c StorageClass) String() string {
+	switch c {
+	case StorageAuto:
+		return "auto"
+	case StorageHot:
+		return "hot"
+	case StorageWarm:
+		return "warm"
+	case StorageCold:
+		return "cold"
+	default:
+		return fmt.Sprintf("StorageClass<%d>", int(c))
+	}
+}
+
+// TieringPolicy moves files to colder storage classes as they sit idle.
+// Zero durations disable the transition.
+type TieringPolicy struct {
+	WarmAfter time.Duration
+	ColdAfter time.Duration
+}
+
+// class returns the storage class e is in.
+func (e *entry) class() StorageClass {
+	switch {
+	case e.cold:
+		return StorageCold
+	case e.compressed != nil:
+		return StorageWarm
+	default:
+		return StorageHot
+	}
+}
+
+// tierable reports whether e may change storage class: it has been idle
+// for olderThan and is held by the store itself rather than a backend, a
+// temp spill or crash simulation, and is not pinned. v.mu must be held.
+func (e *entry) tierable(olderThan time.Duration, now time.Time) bool {
+	return e.src == nil && (e.disk == nil || e.cold) && e.undo == nil &&
+		len(e.pins) == 0 && e.idle(olderThan, now)
+}
+
+// tierTarget returns the storage class p, or SetStorageClass, assigns e.
+// Automatic transitions only ever move files to colder classes; opening a
+// file brings it back. v.mu must be held.
+func (e *entry) tierTarget(p TieringPolicy, now time.Time) StorageClass {
+	to := e.fixedClass
+	if to == StorageAuto {
+		switch {
+		case p.ColdAfter > 0 && e.idle(p.ColdAfter, now):
+			to = StorageCold
+		case p.WarmAfter > 0 && e.idle(p.WarmAfter, now):
+			to = StorageWarm
+		default:
+			return e.class()
+		}
+		if to < e.class() {
+			return e.class()
+		}
+	}
+	if to == StorageWarm && (e.size() < compressMinSize || e.compressTried == e.version+1) {
+		return e.class()
+	}
+	return to
+}
+
+// compressMinSize is the smallest file worth compressing.
+const compressMinSize = 16 << 10
+
+// zstdEncoder and zstdDecoder compress and decompress warm files. Both are
+// safe for concurrent use and configured with valid options, so creating
+// them cannot fail.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// footprint returns the bytes e holds in memory.
+func (e *entry) footprint() int64 {
+	return int64(len(e.data) + len(e.compressed))
+}
+
+// thaw brings e back into memory as is if it is warm or cold. v.mu must
+// be held exclusively.
+func (v *MemVFS) thaw(e *entry) error {
+	var data []byte
+	switch {
+	case e.compressed != nil:
+		var err error
+		data, err = zstdDecoder.DecodeAll(e.compressed, make([]byte, 0, e.rawSize))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != e.rawSize {
+			return io.ErrUnexpectedEOF
+		}
+		e.compressed = nil
+	case e.cold:
+		data = make([]byte, e.diskSize)
+		if _, err := io.ReadFull(io.NewSectionReader(e.disk, 0, e.diskSize), data); err != nil {
+			return err
+		}
+		e.disk.Close()
+		e.disk = nil
+		e.cold = false
+	default:
+		return nil
+	}
+	e.data = data
+	e.setGuard()
+	v.account(e)
+	return nil
+}
+
+// demote moves e, stored as name, out of memory into the class to,
+// StorageWarm or StorageCold, if ok still allows it once the contents are
+// encoded. The encoding runs without holding up the store; a file opened
+// or modified meanwhile is left alone. It reports whether e was moved.
+func (v *MemVFS) demote(name string, e *entry, to StorageClass, ok func(*entry) bool) (FileInfo, bool, error) {
+	v.mu.Lock()
+	if v.files[name] != e || e.class() == to || !ok(e) {
+		v.mu.Unlock()
+		return FileInfo{}, false, nil
+	}
+	if err := v.thaw(e); err != nil {
+		v.mu.Unlock()
+		return FileInfo{}, false, err
+	}
+	data, version := bytes.Clone(e.data), e.version
+	v.mu.Unlock()
+
+	var compressed []byte
+	var disk *os.File
+	switch to {
+	case StorageWarm:
+		compressed = zstdEncoder.EncodeAll(data, nil)
+	case StorageCold:
+		f, err := v.spillFile(name)
+		if err != nil {
+			return FileInfo{}, false, err
+		}
+		if _, err := f.WriteAt(data, 0); err != nil {
+			f.Close()
+			return FileInfo{}, false, err
+		}
+		disk = f
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	switch {
+	case v.files[name] != e, e.version != version, e.class() != StorageHot, !ok(e):
+		if disk != nil {
+			disk.Close()
+		}
+		return FileInfo{}, false, nil
+	case to == StorageWarm && len(compressed) >= len(data)*9/10:
+		// Not worth it; wait for the file to change before trying again.
+		e.compressTried = e.version + 1
+		return FileInfo{}, false, nil
+	case to == StorageWarm:
+		e.compressed = slices.Clip(compressed)
+		e.rawSize = int64(len(e.data))
+	case to == StorageCold:
+		e.disk = disk
+		e.diskSize = int64(len(e.data))
+		e.cold = true
+	}
+	e.data = nil
+	e.guarded = false
+	v.account(e)
+	return v.fileInfo(name, e), true, nil
+}
+
+// ApplyTiering moves the idle files of the store to the storage classes p,
+// or SetStorageClass, assigns them and returns what the moved files are
+// now. Files served by a backend or pinned with Pin stay where they are.
+// The returned error joins the errors of the moves that failed, such as
+// cold files with no spill directory to go to.
+func (v *MemVFS) ApplyTiering(p TieringPolicy) ([]FileInfo, error) {
+	type move struct {
+		name string
+		e    *entry
+		to   StorageClass
+	}
+	v.mu.Lock()
+	now := time.Now()
+	var moves []move
+	var infos []FileInfo
+	var errs []error
+	for name, e := range v.files {
+		if !e.tierable(0, now) {
+			continue
+		}
+		switch to := e.tierTarget(p, now); {
+		case to == e.class():
+		case to == StorageHot:
+			if err := v.thaw(e); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", v.LogName(name), err))
+				continue
+			}
+			infos = append(infos, v.fileInfo(name, e))
+		default:
+			moves = append(moves, move{name, e, to})
+		}
+	}
+	v.mu.Unlock()
+
+	for _, m := range moves {
+		info, ok, err := v.demote(m.name, m.e, m.to, func(e *entry) bool {
+			return e.tierable(0, now) && e.tierTarget(p, now) == m.to
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.LogName(m.name), err))
+		}
+		if ok {
+			infos = append(infos, info)
+		}
+	}
+	slices.SortFunc(infos, func(a, b FileInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return infos, errors.Join(errs...)
+}
+
+// AutoTier runs ApplyTiering(p) every interval until stop is called.
+// interval must be positive.
+func (v *MemVFS) AutoTier(p TieringPolicy, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, errInterval
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				infos, err := v.ApplyTiering(p)
+				if len(infos) > 0 {
+					v.log(slog.LevelInfo, "files tiered", "files", len(infos))
+				}
+				if err != nil {
+					v.log(slog.LevelError, "tiering failed", "error", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}, nil
+}
+
+// SetStorageClass fixes the storage class of name, overriding the
+// TieringPolicy; StorageAuto hands it back to the policy. An idle file is
+// moved right away, a file in use when it is next idle and tiered by
+// ApplyTiering or AutoTier. Opening a warm or cold file always brings it
+// back into memory while it is in use. StorageCold fails unless the store
+// has spill directories.
+func (v *MemVFS) SetStorageClass(name string, class StorageClass) error {
+	if class == StorageCold && len(v.spillDirs) == 0 {
+		return errNoSpillDir
+	}
+	v.mu.Lock()
+	e, ok := v.files[name]
+	if !ok {
+		v.mu.Unlock()
+		return ErrNotFound
+	}
+	e.fixedClass = class
+	now := time.Now()
+	if class == StorageAuto || !e.tierable(0, now) {
+		v.mu.Unlock()
+		return nil
+	}
+	if class == StorageHot {
+		defer v.mu.Unlock()
+		return v.thaw(e)
+	}
+	v.mu.Unlock()
+
+	_, _, err := v.demote(name, e, class, func(e *entry) bool {
+		return e.tierable(0, now) && e.fixedClass == class
+	})
+	return err
+}
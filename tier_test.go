@@ -0,0 +1,119 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestStorageClasses(t *testing.T) {
+	ctx := context.Background()
+	tv := memvfs.New()
+	if err := tv.Register("memvfs-tier-src"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	src, err := tv.OpenDB("template.db", memvfs.ProfileNone)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := src.Exec(`INSERT INTO demo(data) VALUES (?)`, strings.Repeat("tier ", 40)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	sv := memvfs.New(memvfs.WithSpillDirs(nil, dir))
+	if err := sv.Register("memvfs-tier"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	for _, name := range []string{"a.db", "b.db"} {
+		if _, err := tv.CopyTo(ctx, "template.db", sv, name); err != nil {
+			t.Fatalf("CopyTo error: %v", err)
+		}
+	}
+	want, _ := sv.GetFile("a.db")
+	want = bytes.Clone(want)
+	class := func(name string) memvfs.StorageClass {
+		info, err := sv.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat error: %v", err)
+		}
+		return info.StorageClass
+	}
+	if c := class("a.db"); c != memvfs.StorageHot {
+		t.Errorf("Expected a new file to be hot, got %v", c)
+	}
+
+	if err := sv.SetStorageClass("b.db", memvfs.StorageHot); err != nil {
+		t.Fatalf("SetStorageClass error: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	files, err := sv.ApplyTiering(memvfs.TieringPolicy{WarmAfter: 10 * time.Millisecond, ColdAfter: time.Hour})
+	if err != nil || len(files) != 1 || files[0].Name != "a.db" || files[0].StorageClass != memvfs.StorageWarm {
+		t.Fatalf("Expected only a.db to turn warm, got %+v, %v", files, err)
+	}
+	files, err = sv.ApplyTiering(memvfs.TieringPolicy{WarmAfter: 10 * time.Millisecond, ColdAfter: 10 * time.Millisecond})
+	if err != nil || len(files) != 1 || files[0].StorageClass != memvfs.StorageCold {
+		t.Fatalf("Expected a.db to turn cold, got %+v, %v", files, err)
+	}
+	if c := class("b.db"); c != memvfs.StorageHot {
+		t.Errorf("Expected b.db to stay hot as set, got %v", c)
+	}
+	stats := sv.Stats()
+	if stats.Cold != 1 || stats.Compressed != 0 || stats.Bytes >= 2*int64(len(want)) {
+		t.Errorf("Expected one cold file out of memory, got %+v", stats)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected cold files not to be visible in the spill directory, found %d", len(entries))
+	}
+
+	got, err := sv.GetFile("a.db")
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Expected GetFile to bring back the contents, got %d bytes, %v", len(got), err)
+	}
+	if c := class("a.db"); c != memvfs.StorageHot {
+		t.Errorf("Expected a.db to be hot after a read, got %v", c)
+	}
+
+	if err := sv.SetStorageClass("b.db", memvfs.StorageCold); err != nil {
+		t.Fatalf("SetStorageClass error: %v", err)
+	}
+	if c := class("b.db"); c != memvfs.StorageCold {
+		t.Errorf("Expected an idle file to move at once, got %v", c)
+	}
+	db, err := sv.OpenDB("b.db", memvfs.ProfileNone)
+	if err != nil {
+		t.Fatalf("OpenDB error: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 500 {
+		t.Errorf("Expected 500 rows in a cold file, got %d, %v", n, err)
+	}
+	if c := class("b.db"); c != memvfs.StorageHot {
+		t.Errorf("Expected an open file to be hot, got %v", c)
+	}
+	db.Close()
+	if _, err := sv.ApplyTiering(memvfs.TieringPolicy{}); err != nil {
+		t.Fatalf("ApplyTiering error: %v", err)
+	}
+	if c := class("b.db"); c != memvfs.StorageCold {
+		t.Errorf("Expected b.db to return to its fixed class, got %v", c)
+	}
+
+	if err := sv.SetStorageClass("missing.db", memvfs.StorageWarm); err != memvfs.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := tv.SetStorageClass("template.db", memvfs.StorageCold); err == nil {
+		t.Errorf("Expected StorageCold to fail without spill directories")
+	}
+}
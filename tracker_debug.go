@@ -0,0 +1,66 @@
+//go:build memvfsdebug
+
+package memvfs
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// unlockedRead is a ReadAt copying from a file's buffer after releasing
+// its lock.
+type unlockedRead struct {
+	f        *MemFile
+	off, end int64
+}
+
+// reads tracks the unlocked reads in progress on each entry. The race
+// detector only sees the slice a reader copies from, not that a writer may
+// mutate it in place as long as SQLite's locks keep the two apart; an
+// overlap means the lock protocol was broken, and the report names both
+// handles.
+var reads struct {
+	mu     sync.Mutex
+	active map[*entry][]unlockedRead
+}
+
+// beginRead records that f is about to copy [off, end) of e's buffer
+// without holding its lock. e must be locked.
+func (e *entry) beginRead(f *MemFile, off, end int64) {
+	reads.mu.Lock()
+	defer reads.mu.Unlock()
+	if reads.active == nil {
+		reads.active = make(map[*entry][]unlockedRead)
+	}
+	reads.active[e] = append(reads.active[e], unlockedRead{f: f, off: off, end: end})
+}
+
+// endRead records that f's unlocked read of e's buffer has finished.
+func (e *entry) endRead(f *MemFile) {
+	reads.mu.Lock()
+	defer reads.mu.Unlock()
+	active := reads.active[e]
+	if i := slices.IndexFunc(active, func(r unlockedRead) bool { return r.f == f }); i >= 0 {
+		reads.active[e] = slices.Delete(active, i, i+1)
+	}
+}
+
+// mutating panics if f, or an administrative call if f is nil, is about to
+// modify [off, end) of e's buffer in place while an unlocked read of it is
+// in progress. e must be locked.
+func (e *entry) mutating(f *MemFile, off, end int64) {
+	reads.mu.Lock()
+	defer reads.mu.Unlock()
+	for _, r := range reads.active[e] {
+		if r.off >= end || off >= r.end {
+			continue
+		}
+		writer := "an administrative call"
+		if f != nil {
+			writer = fmt.Sprintf("handle %d", f.id)
+		}
+		panic(fmt.Sprintf("memvfs: %s: %s wrote [%d, %d) while handle %d was reading [%d, %d) without a lock that excludes it",
+			r.f.fileName, writer, off, end, r.f.id, r.off, r.end))
+	}
+}
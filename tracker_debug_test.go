@@ -0,0 +1,65 @@
+//go:build memvfsdebug
+
+package memvfs_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestTrackerUnlockedWrite(t *testing.T) {
+	v := memvfs.New()
+	name := "test-tracker-unlocked-write.db"
+	flags := sqlite3vfs.OpenMainDB | sqlite3vfs.OpenReadWrite | sqlite3vfs.OpenCreate
+	reader, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer reader.Close()
+	writer, _, err := v.Open(name, flags)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer writer.Close()
+
+	page := make([]byte, 1<<20)
+	if _, err := writer.WriteAt(page, 0); err != nil {
+		t.Fatalf("WriteAt error: %v", err)
+	}
+
+	// Neither handle takes a lock, so nothing keeps the writer away from
+	// pages the reader is copying.
+	done := make(chan struct{})
+	go func() {
+		buf := make([]byte, len(page))
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				reader.ReadAt(buf, 0)
+			}
+		}
+	}()
+	defer close(done)
+
+	report := make(chan string, 1)
+	go func() {
+		defer func() {
+			msg, _ := recover().(string)
+			report <- msg
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			writer.WriteAt(page[:4096], 0)
+		}
+	}()
+
+	if msg := <-report; !strings.Contains(msg, "without a lock that excludes it") {
+		t.Errorf("Expected an unlocked write to be reported, got %q", msg)
+	}
+}
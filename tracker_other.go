@@ -0,0 +1,12 @@
+//go:build !memvfsdebug
+
+package memvfs
+
+// Unlocked reads are only tracked in memvfsdebug builds; see
+// tracker_debug.go.
+
+func (e *entry) beginRead(f *MemFile, off, end int64) {}
+
+func (e *entry) endRead(f *MemFile) {}
+
+func (e *entry) mutating(f *MemFile, off, end int64) {}
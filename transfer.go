@@ -0,0 +1,391 @@
+package memvfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/psanford/sqlite3vfs"
+)
+
+// DefaultChunkSize is the chunk size used by manifests when none is given.
+const DefaultChunkSize = 1 << 20
+
+// ErrChunkMismatch is returned by Pull when a fetched chunk does not match
+// the manifest, typically because the source changed mid-transfer. Pulling
+// again fetches a fresh manifest and keeps the chunks that still match.
+var ErrChunkMismatch = errors.New("memvfs: chunk does not match manifest")
+
+// Manifest lists the content hashes of a file's chunks so that a transfer
+// can verify each chunk and skip the ones it already has. UUID is the
+// file's identity, which the pulled copy takes on.
+type Manifest struct {
+	UUID      UUID                `json:"uuid"`
+	Size      int64               `json:"size"`
+	ChunkSize int                 `json:"chunk_size"`
+	Chunks    [][sha256.Size]byte `json:"chunks"`
+}
+
+// chunk returns the bounds of chunk i.
+func (m Manifest) chunk(i int) (start, end int64) {
+	start = int64(i) * int64(m.ChunkSize)
+	return start, min(start+int64(m.ChunkSize), m.Size)
+}
+
+// Manifest returns the manifest of the named file, computed while the file
+// is frozen. chunkSize <= 0 selects DefaultChunkSize.
+func (v *MemVFS) Manifest(name string, chunkSize int) (Manifest, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	unfreeze, err := v.Freeze(name)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer unfreeze()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return Manifest{}, ErrNotFound
+	}
+	data, err := v.contents(e)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m := Manifest{UUID: e.uuid, Size: int64(len(data)), ChunkSize: chunkSize}
+	for off := 0; off < len(data); off += chunkSize {
+		m.Chunks = append(m.Chunks, sha256.Sum256(data[off:min(off+chunkSize, len(data))]))
+	}
+	return m, nil
+}
+
+// ChunkSource is the sending side of a Pull.
+type ChunkSource interface {
+	Manifest(ctx context.Context) (Manifest, error)
+	// Chunk returns chunk i of the file described by the last manifest.
+	Chunk(ctx context.Context, m Manifest, i int) ([]byte, error)
+}
+
+// TransferProgress reports how far a Pull got.
+type TransferProgress struct {
+	Chunks        int
+	ChunksPresent int // already held from an earlier attempt
+	ChunksReused  int // copied from another local copy of the file
+	ChunksFetched int
+	BytesFetched  int64
+}
+
+// Pull fetches the file described by src into name, chunk by chunk, and
+// replaces name with it once every chunk has been verified against the
+// manifest. Chunks are collected in name+"-partial"; if Pull fails, calling
+// it again only fetches the chunks that are missing or changed. Chunks
+// found in name or in another file with the manifest's UUID are copied
+// locally rather than fetched.
+//
+// Replacing name fails with ErrBusy while a connection is using it, and
+// with ErrInvalidImage if a validator set with WithValidators rejects the
+// pulled file, which is kept in name+"-partial".
+func (v *MemVFS) Pull(ctx context.Context, name string, src ChunkSource) (TransferProgress, error) {
+	var p TransferProgress
+
+	m, err := src.Manifest(ctx)
+	if err != nil {
+		return p, err
+	}
+	if m.ChunkSize <= 0 || int64(len(m.Chunks)) != (m.Size+int64(m.ChunkSize)-1)/int64(m.ChunkSize) {
+		return p, errors.New("memvfs: malformed manifest")
+	}
+	p.Chunks = len(m.Chunks)
+
+	partial := name + "-partial"
+	v.mu.Lock()
+	e := v.lookup(partial, sqlite3vfs.OpenMainDB|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	e.retain = true
+	if m.UUID != (UUID{}) {
+		e.uuid = m.UUID
+	}
+	err = v.thaw(e)
+	if err == nil {
+		// A partial file left by an earlier pull may have been opened, and
+		// so sealed; it is sealed again once renamed into place.
+		err = v.unseal(e)
+	}
+	if err != nil {
+		v.mu.Unlock()
+		return p, err
+	}
+	if int64(len(e.data)) != m.Size {
+		data := make([]byte, m.Size)
+		copy(data, e.data)
+		e.data = data
+		v.account(e)
+	}
+	local := v.localChunks(m, name, e)
+	v.mu.Unlock()
+
+	for i, sum := range m.Chunks {
+		start, end := m.chunk(i)
+
+		v.mu.Lock()
+		// A pull stalled on its source leaves the partial file idle, so
+		// it may have been compressed meanwhile.
+		if err := v.thaw(e); err != nil {
+			v.mu.Unlock()
+			return p, err
+		}
+		have := sha256.Sum256(e.data[start:end]) == sum
+		reused := false
+		if ref, ok := local[sum]; !have && ok && ref.off+end-start <= int64(len(ref.e.data)) {
+			chunk := ref.e.data[ref.off : ref.off+end-start]
+			if sha256.Sum256(chunk) == sum {
+				e.own()
+				copy(e.data[start:end], chunk)
+				reused = true
+			}
+		}
+		v.mu.Unlock()
+		if have {
+			p.ChunksPresent++
+			continue
+		}
+		if reused {
+			p.ChunksReused++
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return p, err
+		}
+		data, err := src.Chunk(ctx, m, i)
+		if err != nil {
+			return p, err
+		}
+		if int64(len(data)) != end-start || sha256.Sum256(data) != sum {
+			return p, fmt.Errorf("%w: chunk %d", ErrChunkMismatch, i)
+		}
+
+		v.mu.Lock()
+		err = v.thaw(e)
+		if err == nil {
+			e.own()
+			copy(e.data[start:end], data)
+		}
+		v.mu.Unlock()
+		if err != nil {
+			return p, err
+		}
+		p.ChunksFetched++
+		p.BytesFetched += int64(len(data))
+	}
+
+	if len(v.validators) > 0 {
+		data, err := v.copyFile(partial)
+		if err != nil {
+			return p, err
+		}
+		if err := v.Validate(name, data); err != nil {
+			return p, err
+		}
+	}
+	return p, v.Batch(func(tx *AdminTx) error {
+		return tx.Rename(partial, name)
+	})
+}
+
+// chunkRef locates a chunk in a file of the store.
+type chunkRef struct {
+	e   *entry
+	off int64
+}
+
+// localChunks indexes the chunks of m that the store already holds in name
+// or in other files with m's UUID, so Pull can copy them instead of
+// fetching them. partial is left out. v.mu must be held.
+func (v *MemVFS) localChunks(m Manifest, name string, partial *entry) map[[sha256.Size]byte]chunkRef {
+	wanted := make(map[[sha256.Size]byte]bool, len(m.Chunks))
+	for _, sum := range m.Chunks {
+		wanted[sum] = true
+	}
+	local := make(map[[sha256.Size]byte]chunkRef)
+	for fileName, e := range v.files {
+		if e == partial || e.src != nil || e.disk != nil {
+			continue
+		}
+		if fileName != name && (m.UUID == (UUID{}) || e.uuid != m.UUID) {
+			continue
+		}
+		for off := 0; off < len(e.data); off += m.ChunkSize {
+			sum := sha256.Sum256(e.data[off:min(off+m.ChunkSize, len(e.data))])
+			if wanted[sum] {
+				local[sum] = chunkRef{e: e, off: int64(off)}
+			}
+		}
+	}
+	return local
+}
+
+// CopyTo copies the named file of v into dst as dstName, typically between
+// two stores of the same process. It is a Pull from v, so chunks dst
+// already holds in dstName or in an earlier copy of the file are not
+// copied again, and fanning a template out to many names costs little more
+// than the first copy.
+func (v *MemVFS) CopyTo(ctx context.Context, name string, dst *MemVFS, dstName string) (TransferProgress, error) {
+	return dst.Pull(ctx, dstName, FileChunkSource{V: v, Name: name})
+}
+
+// FileChunkSource serves the named file of a MemVFS as a ChunkSource.
+type FileChunkSource struct {
+	V         *MemVFS
+	Name      string
+	ChunkSize int
+}
+
+func (s FileChunkSource) Manifest(ctx context.Context) (Manifest, error) {
+	return s.V.Manifest(s.Name, s.ChunkSize)
+}
+
+func (s FileChunkSource) Chunk(ctx context.Context, m Manifest, i int) ([]byte, error) {
+	s.V.mu.Lock()
+	defer s.V.mu.Unlock()
+
+	e, ok := s.V.files[s.Name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := s.V.thaw(e); err != nil {
+		return nil, err
+	}
+	start, end := m.chunk(i)
+	if i < 0 || i >= len(m.Chunks) || end > e.size() {
+		return nil, fmt.Errorf("%w: chunk %d", ErrChunkMismatch, i)
+	}
+	if src := e.reader(); src != nil {
+		chunk := make([]byte, end-start)
+		if _, err := src.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return chunk, nil
+	}
+	return bytes.Clone(e.data[start:end]), nil
+}
+
+// ChunkHandler serves src over HTTP for HTTPChunkSource: the manifest as
+// JSON at the handler's path, and chunk i at ?chunk=i of the manifest given
+// by ?size= and ?chunk_size=. Chunk requests are checked against src's own
+// manifest, the last one served or a fresh one: a chunk outside it fails
+// with 400, and a size or chunk size that no longer matches with 409.
+func ChunkHandler(src ChunkSource) http.Handler {
+	var (
+		mu   sync.Mutex
+		last Manifest
+	)
+	manifest := func(ctx context.Context) (Manifest, error) {
+		m, err := src.Manifest(ctx)
+		if err == nil {
+			mu.Lock()
+			last = m
+			mu.Unlock()
+		}
+		return m, err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has("chunk") {
+			m, err := manifest(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(m)
+			return
+		}
+
+		i, err1 := strconv.Atoi(q.Get("chunk"))
+		size, err2 := strconv.ParseInt(q.Get("size"), 10, 64)
+		chunkSize, err3 := strconv.Atoi(q.Get("chunk_size"))
+		if err := errors.Join(err1, err2, err3); err != nil || chunkSize <= 0 {
+			http.Error(w, "bad chunk request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		m := last
+		mu.Unlock()
+		if m.Size != size || m.ChunkSize != chunkSize {
+			var err error
+			if m, err = manifest(r.Context()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if m.Size != size || m.ChunkSize != chunkSize {
+				http.Error(w, ErrChunkMismatch.Error(), http.StatusConflict)
+				return
+			}
+		}
+		if i < 0 || i >= len(m.Chunks) {
+			http.Error(w, "chunk out of range", http.StatusBadRequest)
+			return
+		}
+		data, err := src.Chunk(r.Context(), m, i)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	})
+}
+
+// HTTPChunkSource pulls from a ChunkHandler at URL.
+type HTTPChunkSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s HTTPChunkSource) get(ctx context.Context, url string) ([]byte, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("memvfs: %s: %s", url, resp.Status)
+	}
+	return body, nil
+}
+
+func (s HTTPChunkSource) Manifest(ctx context.Context) (Manifest, error) {
+	body, err := s.get(ctx, s.URL)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var m Manifest
+	err = json.Unmarshal(body, &m)
+	return m, err
+}
+
+func (s HTTPChunkSource) Chunk(ctx context.Context, m Manifest, i int) ([]byte, error) {
+	return s.get(ctx, fmt.Sprintf("%s?chunk=%d&size=%d&chunk_size=%d", s.URL, i, m.Size, m.ChunkSize))
+}
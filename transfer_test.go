@@ -0,0 +1,157 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+// flakySource fails every chunk request after the first budget ones.
+type flakySource struct {
+	memvfs.ChunkSource
+	budget int
+}
+
+var errFlaky = errors.New("connection reset")
+
+func (s *flakySource) Chunk(ctx context.Context, m memvfs.Manifest, i int) ([]byte, error) {
+	if s.budget == 0 {
+		return nil, errFlaky
+	}
+	s.budget--
+	return s.ChunkSource.Chunk(ctx, m, i)
+}
+
+func TestPull(t *testing.T) {
+	srcName := "test-transfer-src.db"
+	dstName := "test-transfer-dst.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", srcName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		_, err = db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(500))
+		if err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	// The destination is a separate store, as the source itself would
+	// otherwise be reused as a local copy.
+	dv := memvfs.New()
+	if err := dv.Register("memvfs-transfer-dst"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	srv := httptest.NewServer(memvfs.ChunkHandler(memvfs.FileChunkSource{V: v, Name: srcName, ChunkSize: 8192}))
+	defer srv.Close()
+
+	// Chunk requests are checked against the file, not trusted.
+	info, _ := v.Stat(srcName)
+	for query, want := range map[string]int{
+		"chunk=0&size=-1&chunk_size=8192":                           http.StatusConflict,
+		"chunk=0&size=1099511627776&chunk_size=1":                   http.StatusConflict,
+		"chunk=0&size=100&chunk_size=0":                             http.StatusBadRequest,
+		fmt.Sprintf("chunk=-1&size=%d&chunk_size=8192", info.Size):  http.StatusBadRequest,
+		fmt.Sprintf("chunk=999&size=%d&chunk_size=8192", info.Size): http.StatusBadRequest,
+	} {
+		resp, err := http.Get(srv.URL + "?" + query)
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Expected %d for ?%s, got %d", want, query, resp.StatusCode)
+		}
+	}
+	src := &flakySource{ChunkSource: memvfs.HTTPChunkSource{URL: srv.URL}, budget: 3}
+
+	p, err := dv.Pull(context.Background(), dstName, src)
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("Expected interrupted pull, got %v", err)
+	}
+	if p.ChunksFetched != 3 {
+		t.Errorf("Expected 3 chunks fetched before interruption, got %d", p.ChunksFetched)
+	}
+
+	src.budget = -1
+	p, err = dv.Pull(context.Background(), dstName, src)
+	if err != nil {
+		t.Fatalf("Pull error: %v", err)
+	}
+	if p.ChunksPresent < 3 || p.ChunksPresent+p.ChunksFetched != p.Chunks {
+		t.Errorf("Expected resumed pull, got %+v", p)
+	}
+
+	srcInfo, _ := v.Stat(srcName)
+	if dstInfo, _ := dv.Stat(dstName); dstInfo.UUID != srcInfo.UUID {
+		t.Errorf("Expected pulled file to keep UUID %v, got %v", srcInfo.UUID, dstInfo.UUID)
+	}
+
+	want, _ := v.GetFile(srcName)
+	got, err := dv.GetFile(dstName)
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Pulled file differs from source")
+	}
+	if _, err := dv.Stat(dstName + "-partial"); !errors.Is(err, memvfs.ErrNotFound) {
+		t.Errorf("Expected partial file to be gone, got %v", err)
+	}
+
+	dst, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs-transfer-dst&cache=shared", dstName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer dst.Close()
+	var count int
+	if err := dst.QueryRow(`SELECT count(*) FROM demo`).Scan(&count); err != nil || count != 200 {
+		t.Errorf("Expected 200 rows, got %d (%v)", count, err)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	tv := memvfs.New()
+	err := tv.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("template.db", bytes.Repeat([]byte(randSeq(4096)), 64))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+
+	dv := memvfs.New()
+	ctx := context.Background()
+	p, err := tv.CopyTo(ctx, "template.db", dv, "tenant-a.db")
+	if err != nil {
+		t.Fatalf("CopyTo error: %v", err)
+	}
+	if p.ChunksFetched != p.Chunks {
+		t.Errorf("Expected first copy to fetch every chunk, got %+v", p)
+	}
+
+	p, err = tv.CopyTo(ctx, "template.db", dv, "tenant-b.db")
+	if err != nil {
+		t.Fatalf("CopyTo error: %v", err)
+	}
+	if p.ChunksReused != p.Chunks || p.ChunksFetched != 0 {
+		t.Errorf("Expected second copy to reuse every chunk, got %+v", p)
+	}
+	want, _ := tv.GetFile("template.db")
+	if got, _ := dv.GetFile("tenant-b.db"); !bytes.Equal(got, want) {
+		t.Errorf("Copied file differs from template")
+	}
+}
@@ -0,0 +1,63 @@
+package memvfs
+
+import "sort"
+
+// span is a half-open byte range [start, end).
+type span struct {
+	start, end int64
+}
+
+// spans is a sorted set of disjoint, non-adjacent byte ranges.
+type spans []span
+
+// add merges [start, end) into s.
+func (s spans) add(start, end int64) spans {
+	if start >= end {
+		return s
+	}
+	i := sort.Search(len(s), func(i int) bool { return s[i].end >= start })
+	j := i
+	for j < len(s) && s[j].start <= end {
+		start = min(start, s[j].start)
+		end = max(end, s[j].end)
+		j++
+	}
+	return append(s[:i], append(spans{{start, end}}, s[j:]...)...)
+}
+
+// clip drops everything at or beyond size.
+func (s spans) clip(size int64) spans {
+	for i := len(s) - 1; i >= 0 && s[i].end > size; i-- {
+		if s[i].start >= size {
+			s = s[:i]
+		} else {
+			s[i].end = size
+		}
+	}
+	return s
+}
+
+func (s spans) bytes() int64 {
+	var n int64
+	for _, sp := range s {
+		n += sp.end - sp.start
+	}
+	return n
+}
+
+// UnsyncedBytes returns how many bytes of the named file have been written
+// since SQLite last synced it. Bytes written more than once count once.
+//
+// SQLite only relies on synced data for durability, so a consistent image of
+// a database is one taken while this is zero, as it is between transactions
+// unless synchronous=OFF.
+func (v *MemVFS) UnsyncedBytes(name string) (int64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e, ok := v.files[name]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return e.unsynced.bytes(), nil
+}
@@ -0,0 +1,64 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+	"github.com/psanford/sqlite3vfs"
+)
+
+func TestUnsyncedBytes(t *testing.T) {
+	v := memvfs.New()
+	name := "test-unsynced.db"
+	f, _, err := v.Open(name, sqlite3vfs.OpenMainJournal|sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer f.Close()
+
+	f.WriteAt(make([]byte, 100), 0)
+	f.WriteAt(make([]byte, 100), 50)
+	f.WriteAt(make([]byte, 10), 300)
+	if n, _ := v.UnsyncedBytes(name); n != 160 {
+		t.Errorf("Expected 160 unsynced bytes, got %d", n)
+	}
+	f.Truncate(120)
+	if n, _ := v.UnsyncedBytes(name); n != 120 {
+		t.Errorf("Expected 120 unsynced bytes after truncate, got %d", n)
+	}
+	f.Sync(sqlite3vfs.SyncNormal)
+	if n, _ := v.UnsyncedBytes(name); n != 0 {
+		t.Errorf("Expected no unsynced bytes after sync, got %d", n)
+	}
+}
+
+func TestUnsyncedBytesSynchronous(t *testing.T) {
+	for _, tc := range []struct {
+		sync     string
+		unsynced bool
+	}{
+		{"NORMAL", false},
+		{"OFF", true},
+	} {
+		dbName := fmt.Sprintf("test-unsynced-%s.db", tc.sync)
+		db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&_sync=%s", dbName, tc.sync))
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		defer db.Close()
+
+		_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+		if err != nil {
+			t.Fatalf("Create table error: %v", err)
+		}
+		n, err := v.UnsyncedBytes(dbName)
+		if err != nil {
+			t.Fatalf("UnsyncedBytes error: %v", err)
+		}
+		if (n > 0) != tc.unsynced {
+			t.Errorf("synchronous=%s: got %d unsynced bytes", tc.sync, n)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package memvfs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// UUID is the identity of a stored file. It is assigned when the file is
+// created and kept when it is renamed, replaced with Put, handed off or
+// pulled, so copies of a file can be matched up regardless of their names.
+type UUID [16]byte
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() UUID {
+	var u UUID
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// ParseUUID parses a UUID in the canonical form returned by String.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("memvfs: invalid UUID %q", s)
+	}
+	hexStr := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(hexStr)); err != nil {
+		return u, fmt.Errorf("memvfs: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns u as xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx.
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// ErrUUIDInUse is returned by SetUUID when another file already has the
+// UUID.
+var ErrUUIDInUse = errors.New("memvfs: UUID belongs to another file")
+
+// FileByUUID returns the name of the file whose UUID is id. It fails with
+// ErrAmbiguous if several files have it, as after pulling a file into the
+// store it came from.
+func (v *MemVFS) FileByUUID(id UUID) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var found string
+	for name, e := range v.files {
+		if e.uuid != id {
+			continue
+		}
+		if found != "" {
+			return "", fmt.Errorf("%w: %s and %s", ErrAmbiguous, v.LogName(found), v.LogName(name))
+		}
+		found = name
+	}
+	if found == "" {
+		return "", ErrNotFound
+	}
+	return found, nil
+}
+
+// SetUUID gives name the identity id, typically that of the file it was
+// imported from. It fails with ErrUUIDInUse if another file has id.
+func (tx *AdminTx) SetUUID(name string, id UUID) error {
+	e, ok := tx.get(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, tx.v.LogName(name))
+	}
+	taken := func(other string) bool {
+		oe, ok := tx.get(other)
+		return ok && oe != e && tx.uuidOf(oe) == id
+	}
+	for other := range tx.v.files {
+		if taken(other) {
+			return fmt.Errorf("%w: %s", ErrUUIDInUse, other)
+		}
+	}
+	for other := range tx.staged {
+		if taken(other) {
+			return fmt.Errorf("%w: %s", ErrUUIDInUse, other)
+		}
+	}
+	tx.uuids[e] = id
+	return nil
+}
+
+// uuidOf returns the UUID e will have once the transaction is applied.
+func (tx *AdminTx) uuidOf(e *entry) UUID {
+	if id, ok := tx.uuids[e]; ok {
+		return id
+	}
+	return e.uuid
+}
@@ -0,0 +1,67 @@
+package memvfs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestUUID(t *testing.T) {
+	v := memvfs.New()
+	err := v.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("test-uuid-a.db", []byte("a"))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	info, err := v.Stat("test-uuid-a.db")
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	id := info.UUID
+	if parsed, err := memvfs.ParseUUID(id.String()); err != nil || parsed != id {
+		t.Errorf("ParseUUID(%v) = %v, %v", id, parsed, err)
+	}
+
+	// Renaming and replacing the contents keep the identity.
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		if err := tx.Rename("test-uuid-a.db", "test-uuid-b.db"); err != nil {
+			return err
+		}
+		return tx.Put("test-uuid-b.db", []byte("b"))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	if name, err := v.FileByUUID(id); err != nil || name != "test-uuid-b.db" {
+		t.Errorf("FileByUUID = %q, %v, want test-uuid-b.db", name, err)
+	}
+
+	// An imported copy can take on the identity it was exported with, but
+	// not one held by another file.
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		if err := tx.Put("test-uuid-c.db", []byte("c")); err != nil {
+			return err
+		}
+		return tx.SetUUID("test-uuid-c.db", id)
+	})
+	if !errors.Is(err, memvfs.ErrUUIDInUse) {
+		t.Errorf("Expected ErrUUIDInUse, got %v", err)
+	}
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		if err := tx.Delete("test-uuid-b.db"); err != nil {
+			return err
+		}
+		if err := tx.Put("test-uuid-c.db", []byte("c")); err != nil {
+			return err
+		}
+		return tx.SetUUID("test-uuid-c.db", id)
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	if info, _ := v.Stat("test-uuid-c.db"); info.UUID != id {
+		t.Errorf("Expected imported file to have UUID %v, got %v", id, info.UUID)
+	}
+}
@@ -0,0 +1,166 @@
+package memvfs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidImage is returned when a validator set with WithValidators
+// rejects a database image.
+var ErrInvalidImage = errors.New("memvfs: invalid database image")
+
+// Validator checks the database image data about to be stored as name,
+// returning an error to reject it. data must not be modified or kept.
+type Validator func(name string, data []byte) error
+
+// WithValidators runs validators, in order, on every database image
+// imported into v with PutFile, ReadFileFrom, ImportArchive or Pull, or
+// loaded from its BackingStore, so that malformed or malicious images are
+// rejected before any connection opens them. A rejected import fails with
+// an error wrapping ErrInvalidImage and the validator's error, and leaves
+// the store unchanged; a rejected load fails the open with SQLITE_CANTOPEN.
+// Validators run before the store is locked, so a slow check does not
+// stall other connections.
+//
+// AdminTx.Put does not run them, as Batch holds the lock; pass images
+// staged there to Validate first.
+func WithValidators(validators ...Validator) Option {
+	return func(v *MemVFS) {
+		v.validators = append(v.validators, validators...)
+	}
+}
+
+// Validate runs the validators set with WithValidators on data, about to be
+// stored as name, and returns an error wrapping ErrInvalidImage if one
+// rejects it.
+func (v *MemVFS) Validate(name string, data []byte) error {
+	for _, validate := range v.validators {
+		if err := validate(name, data); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidImage, v.LogName(name), err)
+		}
+	}
+	return nil
+}
+
+// ValidateHeader rejects images that do not start with a SQLite database
+// header or whose size does not match it. Empty images, which SQLite opens
+// as new databases, pass.
+func ValidateHeader() Validator {
+	return func(name string, data []byte) error {
+		if len(data) == 0 {
+			return nil
+		}
+		pageSize := headerPageSize(data)
+		if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+			return errors.New("not a SQLite database")
+		}
+		if len(data)%pageSize != 0 {
+			return fmt.Errorf("size %d is not a multiple of the page size %d", len(data), pageSize)
+		}
+		// The page count in the header is only valid if written by the
+		// same change as the version-valid-for number.
+		if bytes.Equal(data[24:28], data[92:96]) {
+			if pages := binary.BigEndian.Uint32(data[28:32]); int(pages) != len(data)/pageSize {
+				return fmt.Errorf("header counts %d pages, found %d", pages, len(data)/pageSize)
+			}
+		}
+		return nil
+	}
+}
+
+// ValidateMaxSize rejects images larger than limit bytes.
+func ValidateMaxSize(limit int64) Validator {
+	return func(name string, data []byte) error {
+		if int64(len(data)) > limit {
+			return fmt.Errorf("size %d exceeds the limit of %d bytes", len(data), limit)
+		}
+		return nil
+	}
+}
+
+// ValidateQuickCheck rejects images that fail SQLite's PRAGMA quick_check,
+// run on a private copy.
+func ValidateQuickCheck() Validator {
+	return func(name string, data []byte) error {
+		return inspectImage(data, func(db *sql.DB) error {
+			rows, err := db.Query(`PRAGMA quick_check`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			var problems []string
+			for rows.Next() {
+				var line string
+				if err := rows.Scan(&line); err != nil {
+					return err
+				}
+				if line != "ok" {
+					problems = append(problems, line)
+				}
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("quick_check: %s", strings.Join(problems, "; "))
+			}
+			return nil
+		})
+	}
+}
+
+// ValidateSchema rejects images whose schema holds an object that allow
+// does not accept, such as a table outside an allowlist or a trigger.
+// SQLite's internal objects are not passed to allow.
+func ValidateSchema(allow func(SchemaObject) bool) Validator {
+	return func(name string, data []byte) error {
+		return inspectImage(data, func(db *sql.DB) error {
+			objects, err := schemaObjects(context.Background(), db)
+			if err != nil {
+				return err
+			}
+			for _, o := range objects {
+				if !allow(o) {
+					return fmt.Errorf("%s %s is not allowed", o.Type, o.Name)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// inspector is the private store validators and redactors open images in.
+var inspector struct {
+	once sync.Once
+	v    *MemVFS
+	err  error
+}
+
+// privateStore returns the inspector, registering it on first use.
+func privateStore() (*MemVFS, error) {
+	inspector.once.Do(func() {
+		inspector.v = New()
+		inspector.err = inspector.v.Register("memvfs-validate")
+	})
+	return inspector.v, inspector.err
+}
+
+// inspectImage runs fn on a connection to a private copy of data.
+func inspectImage(data []byte, fn func(db *sql.DB) error) error {
+	p, err := privateStore()
+	if err != nil {
+		return err
+	}
+	db, done, err := p.openScratch("image", bytes.Clone(data))
+	if err != nil {
+		return err
+	}
+	defer done()
+	return fn(db)
+}
@@ -0,0 +1,82 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestValidators(t *testing.T) {
+	src, err := sql.Open("sqlite3", "file:test-validate-src.db?vfs=memvfs&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := src.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(200)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+	data, err := v.GetFile("test-validate-src.db")
+	if err != nil {
+		t.Fatalf("GetFile error: %v", err)
+	}
+	good := bytes.Clone(data)
+
+	vv := memvfs.New(memvfs.WithValidators(
+		memvfs.ValidateMaxSize(int64(len(good))+8192),
+		memvfs.ValidateHeader(),
+		memvfs.ValidateQuickCheck(),
+		memvfs.ValidateSchema(func(o memvfs.SchemaObject) bool { return o.Table == "demo" }),
+	))
+	put := func(data []byte) error {
+		return vv.PutFile("imported.db", data)
+	}
+	if err := put(good); err != nil {
+		t.Fatalf("Expected a sound image to pass, got %v", err)
+	}
+	if err := put(nil); err != nil {
+		t.Errorf("Expected an empty image to pass, got %v", err)
+	}
+
+	corrupt := bytes.Clone(good)
+	// Scramble the leaf pages of demo, past the schema page.
+	for i := 4096 + 8; i < len(corrupt); i += 7 {
+		corrupt[i] ^= 0x5a
+	}
+	oversized := append(bytes.Clone(good), make([]byte, 16384)...)
+	for name, data := range map[string][]byte{
+		"garbage":   []byte("definitely not a database, not at all"),
+		"truncated": good[:len(good)-100],
+		"oversized": oversized,
+		"corrupt":   corrupt,
+	} {
+		if err := put(data); !errors.Is(err, memvfs.ErrInvalidImage) {
+			t.Errorf("Expected a %s image to be rejected, got %v", name, err)
+		}
+	}
+	if _, err := vv.ReadFileFrom("streamed.db", bytes.NewReader(corrupt)); !errors.Is(err, memvfs.ErrInvalidImage) {
+		t.Errorf("Expected ReadFileFrom to reject a corrupt image, got %v", err)
+	}
+	if _, err := vv.Stat("streamed.db"); err != memvfs.ErrNotFound {
+		t.Errorf("Expected a rejected import to leave the store unchanged, got %v", err)
+	}
+
+	if _, err := src.Exec(`CREATE TABLE secrets (data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	_, err = vv.Pull(context.Background(), "pulled.db", memvfs.FileChunkSource{V: v, Name: "test-validate-src.db"})
+	if !errors.Is(err, memvfs.ErrInvalidImage) {
+		t.Errorf("Expected Pull to reject a disallowed schema, got %v", err)
+	}
+	if _, err := vv.Stat("pulled.db"); err != memvfs.ErrNotFound {
+		t.Errorf("Expected a rejected pull not to replace the file, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package memvfs
+
+import "github.com/psanford/sqlite3vfs"
+
+// ViewPolicy is the policy a view registered with RegisterView applies on
+// top of the store.
+type ViewPolicy struct {
+	// ReadOnly views open every file read-only and can neither create nor
+	// delete files, apart from the temporary files queries need.
+	ReadOnly bool
+
+	// Match, if set, limits the view to the databases it accepts by name,
+	// together with their journals. Other files look absent.
+	Match func(name string) bool
+}
+
+// RegisterView registers v with SQLite under vfsName as well, applying
+// policy to the connections opened through that name. The same files can
+// so be exposed with different capabilities to different parts of an
+// application, such as "memvfs-ro" for reporting next to a read-write
+// "memvfs". Helpers such as OpenDB keep using the name given to Register.
+func (v *MemVFS) RegisterView(vfsName string, policy ViewPolicy, opts ...sqlite3vfs.Option) error {
+	return sqlite3vfs.RegisterVFS(vfsName, &view{v: v, policy: policy}, opts...)
+}
+
+// view is a MemVFS seen through a ViewPolicy.
+type view struct {
+	v      *MemVFS
+	policy ViewPolicy
+}
+
+// visible reports whether the view shows name, a database or the journal
+// or WAL of one.
+func (w *view) visible(name string) bool {
+	if w.policy.Match == nil || w.policy.Match(name) {
+		return true
+	}
+	for _, role := range []Role{RoleMainJournal, RoleWAL} {
+		if owner := ownerName(name, role); owner != name && w.policy.Match(owner) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *view) Open(name string, flags sqlite3vfs.OpenFlag) (sqlite3vfs.File, sqlite3vfs.OpenFlag, error) {
+	// Temporary files are private to the connection, so every view may
+	// create them.
+	if name == "" {
+		return w.v.Open(name, flags)
+	}
+	if !w.visible(name) {
+		return nil, 0, sqlite3vfs.CantOpenError
+	}
+	if w.policy.ReadOnly {
+		if ok, _ := w.v.Access(name, sqlite3vfs.AccessExists); !ok {
+			return nil, 0, sqlite3vfs.CantOpenError
+		}
+		flags = flags&^(sqlite3vfs.OpenReadWrite|sqlite3vfs.OpenCreate|sqlite3vfs.OpenExclusive) | sqlite3vfs.OpenReadOnly
+	}
+	return w.v.Open(name, flags)
+}
+
+func (w *view) Delete(name string, syncDir bool) error {
+	if w.policy.ReadOnly {
+		return sqlite3vfs.ReadOnlyError
+	}
+	if !w.visible(name) {
+		return nil
+	}
+	return w.v.Delete(name, syncDir)
+}
+
+func (w *view) Access(name string, flag sqlite3vfs.AccessFlag) (bool, error) {
+	if !w.visible(name) {
+		return false, nil
+	}
+	if w.policy.ReadOnly && flag == sqlite3vfs.AccessReadWrite {
+		return false, nil
+	}
+	return w.v.Access(name, flag)
+}
+
+func (w *view) FullPathname(name string) string {
+	return w.v.FullPathname(name)
+}
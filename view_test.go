@@ -0,0 +1,67 @@
+package memvfs_test
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestRegisterView(t *testing.T) {
+	if err := v.RegisterView("memvfs-ro", memvfs.ViewPolicy{ReadOnly: true}); err != nil {
+		t.Fatalf("RegisterView error: %v", err)
+	}
+	scoped := memvfs.ViewPolicy{Match: func(name string) bool {
+		return strings.HasPrefix(name, "test-view-")
+	}}
+	if err := v.RegisterView("memvfs-scoped", scoped); err != nil {
+		t.Fatalf("RegisterView error: %v", err)
+	}
+
+	// An overlay outlives its connections, so the read-only view can
+	// close its own without deleting the database.
+	name := "test-view-main.db"
+	if err := v.Overlay(bytes.NewReader(nil), 0, name); err != nil {
+		t.Fatalf("Overlay error: %v", err)
+	}
+	defer v.Delete(name, false)
+
+	rw, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer rw.Close()
+	if _, err := rw.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := rw.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(20)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	ro, err := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-ro")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer ro.Close()
+	var n int
+	if err := ro.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 10 {
+		t.Fatalf("Expected 10 rows through the read-only view, got %d, %v", n, err)
+	}
+	if _, err := ro.Exec(`INSERT INTO demo(data) VALUES ('x')`); err == nil {
+		t.Errorf("Expected a write through the read-only view to fail")
+	}
+
+	sc, _ := sql.Open("sqlite3", "file:"+name+"?vfs=memvfs-scoped")
+	defer sc.Close()
+	if _, err := sc.Exec(`INSERT INTO demo(data) VALUES ('x')`); err != nil {
+		t.Errorf("Insert through the scoped view error: %v", err)
+	}
+
+	if err := rw.QueryRow(`SELECT count(*) FROM demo`).Scan(&n); err != nil || n != 11 {
+		t.Errorf("Expected 11 rows after writing through the scoped view, got %d, %v", n, err)
+	}
+}
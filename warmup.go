@@ -0,0 +1,97 @@
+package memvfs
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// WarmupItem is a database to hydrate during Warmup.
+type WarmupItem struct {
+	Name   string
+	Source ChunkSource
+}
+
+// WarmupPlan describes a Warmup.
+type WarmupPlan struct {
+	// Items are pulled in order, so list the most important first.
+	Items []WarmupItem
+
+	// Parallelism is the number of concurrent pulls; 0 means 1.
+	Parallelism int
+
+	// FailFast stops the warmup at the first failure, cancelling the pulls
+	// in progress. Otherwise every item is attempted.
+	FailFast bool
+
+	// Ready, if set, is called as each item finishes, successfully or not,
+	// so that a service can start serving a database before the rest are
+	// loaded. Calls may be concurrent.
+	Ready func(WarmupResult)
+}
+
+// WarmupResult is the outcome for one WarmupItem.
+type WarmupResult struct {
+	Name     string
+	Progress TransferProgress
+	Err      error
+}
+
+// Warmup hydrates the databases of plan with Pull and returns a result per
+// item, in plan order. Items that were never started because the warmup was
+// cancelled report the cancellation. The returned error joins the errors of
+// all failed items.
+func (v *MemVFS) Warmup(ctx context.Context, plan WarmupPlan) ([]WarmupResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parallelism := max(plan.Parallelism, 1)
+	results := make([]WarmupResult, len(plan.Items))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+	for range parallelism {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				item := plan.Items[i]
+				if err := ctx.Err(); err != nil {
+					results[i] = WarmupResult{Name: item.Name, Err: err}
+					continue
+				}
+				p, err := v.Pull(ctx, item.Name, item.Source)
+				results[i] = WarmupResult{Name: item.Name, Progress: p, Err: err}
+				if err != nil && plan.FailFast {
+					cancel()
+				}
+				if plan.Ready != nil {
+					plan.Ready(results[i])
+				}
+			}
+		}()
+	}
+
+	i := 0
+feed:
+	for ; i < len(plan.Items); i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for j := range results {
+		if j >= i {
+			results[j] = WarmupResult{Name: plan.Items[j].Name, Err: ctx.Err()}
+		}
+		if results[j].Err != nil {
+			errs = append(errs, results[j].Err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
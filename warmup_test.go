@@ -0,0 +1,83 @@
+package memvfs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestWarmup(t *testing.T) {
+	srcName := "test-warmup-src.db"
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?vfs=memvfs&cache=shared", srcName))
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`)
+	if err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+
+	// The failing source serves a different file, so its chunks are not
+	// found in the copies made from good.
+	err = v.Batch(func(tx *memvfs.AdminTx) error {
+		return tx.Put("test-warmup-other.db", []byte("other"))
+	})
+	if err != nil {
+		t.Fatalf("Batch error: %v", err)
+	}
+	good := memvfs.FileChunkSource{V: v, Name: srcName}
+	bad := &flakySource{ChunkSource: memvfs.FileChunkSource{V: v, Name: "test-warmup-other.db"}}
+	plan := memvfs.WarmupPlan{
+		Items: []memvfs.WarmupItem{
+			{Name: "test-warmup-a.db", Source: good},
+			{Name: "test-warmup-b.db", Source: bad},
+			{Name: "test-warmup-c.db", Source: good},
+		},
+		Parallelism: 2,
+	}
+	// Warm up a separate store, as the source itself would otherwise be
+	// reused as a local copy.
+	wv := memvfs.New()
+	var mu sync.Mutex
+	var ready []string
+	plan.Ready = func(r memvfs.WarmupResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		ready = append(ready, r.Name)
+	}
+
+	results, err := wv.Warmup(context.Background(), plan)
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("Expected the failed pull's error, got %v", err)
+	}
+	if len(ready) != 3 {
+		t.Errorf("Expected 3 readiness calls, got %v", ready)
+	}
+	for i, r := range results {
+		if (r.Err != nil) != (i == 1) {
+			t.Errorf("Unexpected result for %s: %v", r.Name, r.Err)
+		}
+	}
+	if _, err := wv.Stat("test-warmup-c.db"); err != nil {
+		t.Errorf("Stat of warmed up file: %v", err)
+	}
+
+	plan.Items = []memvfs.WarmupItem{
+		{Name: "test-warmup-d.db", Source: bad},
+		{Name: "test-warmup-e.db", Source: good},
+	}
+	plan.Parallelism = 1
+	plan.FailFast = true
+	plan.Ready = nil
+	results, _ = wv.Warmup(context.Background(), plan)
+	if !errors.Is(results[1].Err, context.Canceled) {
+		t.Errorf("Expected second item to be cancelled, got %v", results[1].Err)
+	}
+}
@@ -0,0 +1,15 @@
+package memvfs
+
+// commitWrites ends the current transaction on a main database, counting the
+// bytes it changed. e must be locked.
+func (e *entry) commitWrites() {
+	e.committed += e.txWritten.bytes()
+	e.txWritten = nil
+}
+
+// writeAmplification returns the bytes written to e and its side files per
+// byte committed, or 0 before the first commit. e must be locked.
+func (e *entry) writeAmplification() float64 {
+	written := e.io.load().BytesWritten + e.sideIO.load().BytesWritten
+	return ratio(written, e.committed)
+}
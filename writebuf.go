@@ -0,0 +1,63 @@
+package memvfs
+
+import "github.com/psanford/sqlite3vfs"
+
+// maxPendingBytes bounds the page writes a handle buffers before publishing
+// them early.
+const maxPendingBytes = 8 << 20
+
+// pendingWrite is a main database write buffered by the handle that holds
+// the write lock.
+type pendingWrite struct {
+	off  int64
+	data []byte
+}
+
+// buffers reports whether writes through f to e are buffered until the
+// transaction syncs or unlocks. Only the main database is buffered, and only
+// while f holds at least RESERVED, so that a transaction's pages land in
+// e.data together and readers outside SQLite's locking, such as shadows and
+// exports, never see half a commit. e must be locked.
+func (f *MemFile) buffers(e *entry) bool {
+	return e.role == RoleMainDB && f.lockLevel >= sqlite3vfs.LockReserved && e.src == nil
+}
+
+// buffer queues a write of p at off. f.mu must be held.
+func (f *MemFile) buffer(p []byte, off int64) {
+	data := make([]byte, len(p))
+	copy(data, p)
+	f.pending = append(f.pending, pendingWrite{off: off, data: data})
+	f.pendingBytes += int64(len(p))
+	f.pendingEnd = max(f.pendingEnd, off+int64(len(p)))
+}
+
+// publish applies f's buffered writes to e in one step. f.mu must be held
+// and e locked.
+func (f *MemFile) publish(e *entry) {
+	if len(f.pending) == 0 {
+		return
+	}
+	for _, w := range f.pending {
+		f.store.saveUndo(e, w.off, w.off+int64(len(w.data)))
+	}
+	if f.pendingEnd > int64(len(e.data)) {
+		grown := make([]byte, f.pendingEnd)
+		copy(grown, e.data)
+		e.data = grown
+	}
+	for _, w := range f.pending {
+		e.mutating(f, w.off, w.off+int64(len(w.data)))
+		copy(e.data[w.off:], w.data)
+	}
+	e.modified()
+	f.store.account(e)
+	f.discard()
+}
+
+// discard drops f's buffered writes. f.mu must be held.
+func (f *MemFile) discard() {
+	clear(f.pending)
+	f.pending = f.pending[:0]
+	f.pendingBytes = 0
+	f.pendingEnd = 0
+}
@@ -0,0 +1,49 @@
+package memvfs
+
+import (
+	"bytes"
+	"context"
+)
+
+// WithWriteThrough is WithBackingStore(DirStore(dir)) storing each main
+// database every time SQLite syncs it as well: as the last step of each
+// commit in rollback journal modes, and on checkpoint in WAL mode. A main
+// database missing from the store is loaded from dir when opened, so after
+// a restart the store picks up where the last sync left off.
+//
+// The whole database is written on each sync and the commit waits for it,
+// which DirStore keeps atomic. Files changed other than through SQLite,
+// with Put for instance, are written on their next sync.
+func WithWriteThrough(dir string) Option {
+	return func(v *MemVFS) {
+		v.store = DirStore(dir)
+		v.storeOnSync = true
+	}
+}
+
+// writeThroughImage returns a copy of e and its version if e is to be
+// stored on sync, having changed since it last was. e must be locked.
+func (v *MemVFS) writeThroughImage(e *entry) ([]byte, uint64, bool) {
+	if !v.storeOnSync || e.role != RoleMainDB || e.reader() != nil || e.written.Load() == e.version {
+		return nil, 0, false
+	}
+	return bytes.Clone(e.data), e.version, true
+}
+
+// writeThrough stores image, version of e taken by writeThroughImage, in the
+// backing store as name, unless a later version was stored meanwhile. e
+// must not be locked: stores of the same file are serialized by e.storing
+// rather than by the file's lock.
+func (v *MemVFS) writeThrough(name string, e *entry, image []byte, version uint64) error {
+	e.storing.Lock()
+	defer e.storing.Unlock()
+
+	if e.written.Load() >= version {
+		return nil
+	}
+	if err := v.store.Store(context.Background(), name, image); err != nil {
+		return err
+	}
+	e.written.Store(version)
+	return nil
+}
@@ -0,0 +1,77 @@
+package memvfs_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hleng1/memvfs"
+)
+
+func TestWriteThrough(t *testing.T) {
+	dir := t.TempDir()
+	wv := memvfs.New(memvfs.WithWriteThrough(dir))
+	if err := wv.Register("memvfs-writethrough"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:app/test.db?vfs=memvfs-writethrough")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE demo (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatalf("Create table error: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := db.Exec(`INSERT INTO demo(data) VALUES (?)`, randSeq(100)); err != nil {
+			t.Fatalf("Insert error: %v", err)
+		}
+	}
+
+	// The copy is a whole database as of the last commit.
+	path := filepath.Join(dir, "app", "test.db")
+	disk, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		t.Fatalf("Failed to open disk DB: %v", err)
+	}
+	var n int
+	if err := disk.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+		t.Fatalf("Count on disk error: %v", err)
+	}
+	disk.Close()
+	if n != 20 {
+		t.Errorf("Expected 20 rows on disk, got %d", n)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "app"))
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the database on disk, got %v", entries)
+	}
+
+	// A fresh store loads the copy.
+	restarted := memvfs.New(memvfs.WithWriteThrough(dir))
+	if err := restarted.Register("memvfs-writethrough-restarted"); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	db2, err := sql.Open("sqlite3", "file:app/test.db?vfs=memvfs-writethrough-restarted")
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	if err := db2.QueryRow(`SELECT COUNT(*) FROM demo`).Scan(&n); err != nil {
+		t.Fatalf("Count error: %v", err)
+	}
+	db2.Close()
+	if n != 20 {
+		t.Errorf("Expected 20 rows after a restart, got %d", n)
+	}
+
+	if err := restarted.Delete("app/test.db", false); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the copy to be deleted, got %v", err)
+	}
+}